@@ -1,10 +1,18 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/subhammahanty235/medai/internal/config"
 	"github.com/subhammahanty235/medai/internal/db"
+	"github.com/subhammahanty235/medai/internal/logging"
 	"github.com/subhammahanty235/medai/internal/shared"
 )
 
@@ -12,18 +20,126 @@ func main() {
 	// Load configuration
 	cfg := config.Load()
 
+	// Everything, including the standard library's log package used by
+	// dependencies, goes through the redacting JSON logger
+	level, levelErr := logging.ParseLevel(cfg.LogLevel)
+	logger := logging.New(os.Stdout, level)
+	slog.SetDefault(logger)
+	if levelErr != nil {
+		logger.Warn("Falling back to info logging", "error", levelErr)
+	}
+
+	shutdownTracing, err := shared.SetupTracing(context.Background(), cfg)
+	if err != nil {
+		logger.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+
 	// Initialize database
-	database, err := db.Initialize(cfg.MongoURI, cfg.DatabaseName)
+	database, err := db.Initialize(cfg.MongoURI, cfg.DatabaseName, cfg.DBTimeout)
 	if err != nil {
-		log.Fatal("Failed to initialize database:", err)
+		logger.Error("Failed to initialize database", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("Connected to MongoDB")
+
+	var exitCode int
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		exitCode = runMigrate(database, cfg, logger, os.Args[2:])
+	} else {
+		exitCode = serve(database, cfg, logger)
 	}
 
+	// Export the spans still buffered
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("Error flushing traces", "error", err)
+	}
+	cancel()
+
+	os.Exit(exitCode)
+}
+
+// serve runs the API until SIGINT or SIGTERM, then lets in-flight requests
+// finish, stops background work and closes the clients in dependency order.
+// It returns the exit code.
+func serve(database *db.Database, cfg *config.Config, logger *slog.Logger) int {
+	defer func() {
+		if err := database.Close(); err != nil {
+			logger.Error("Error closing database", "error", err)
+		}
+	}()
+
 	// Initialize router
-	router := shared.SetupRouter(database, cfg)
+	app, err := shared.SetupRouter(database, cfg, logger)
+	if err != nil {
+		logger.Error("Failed to start", "error", err)
+		return 1
+	}
+
+	server := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           app.Router,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		ReadHeaderTimeout: cfg.HTTPReadTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	// Start server
-	log.Printf("Server starting on port %s", cfg.Port)
-	if err := router.Run(":" + cfg.Port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("Server starting", "port", cfg.Port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	// Migrate while liveness is already served; readiness waits for it
+	startErr := make(chan error, 1)
+	go func() {
+		startErr <- app.Start(ctx)
+	}()
+
+	exitCode := 0
+wait:
+	for {
+		select {
+		case err := <-serverErr:
+			logger.Error("Server failed", "error", err)
+			exitCode = 1
+			break wait
+		case err := <-startErr:
+			if err != nil {
+				logger.Error("Failed to start", "error", err)
+				exitCode = 1
+				break wait
+			}
+			logger.Info("Startup complete, ready for traffic")
+		case <-ctx.Done():
+			logger.Info("Shutting down")
+			break wait
+		}
 	}
+	stop()
+
+	// Fail readiness first and keep serving for a moment so that load
+	// balancers stop sending new requests before the listener closes
+	app.Health.MarkShuttingDown()
+	if exitCode == 0 {
+		time.Sleep(cfg.ShutdownDrainDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Warn("Requests did not finish before shutdown", "error", err)
+		server.Close()
+	}
+
+	app.Close(shutdownCtx)
+
+	return exitCode
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/subhammahanty235/medai/internal/config"
+	"github.com/subhammahanty235/medai/internal/db"
+	"github.com/subhammahanty235/medai/internal/shared"
+)
+
+const migrateUsage = `usage: medai migrate <command>
+
+commands:
+  list      show every migration and when it was applied
+  apply     apply pending migrations
+  dry-run   show the migrations apply would run, without running them`
+
+// runMigrate handles the migrate subcommand and returns the exit code.
+func runMigrate(database *db.Database, cfg *config.Config, logger *slog.Logger, args []string) int {
+	defer database.Close()
+
+	if len(args) != 1 {
+		fmt.Println(migrateUsage)
+		return 2
+	}
+
+	blobStorage, err := shared.NewStorage(cfg)
+	if err != nil {
+		logger.Error("Failed to initialize storage", "error", err)
+		return 1
+	}
+
+	migrator, err := shared.NewMigrator(database, blobStorage, cfg, logger)
+	if err != nil {
+		logger.Error("Invalid migrations", "error", err)
+		return 1
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "list":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			logger.Error("Failed to read migrations", "error", err)
+			return 1
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.Applied != nil {
+				applied = "applied " + status.Applied.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%4d  %-40s %s\n", status.Migration.Version, status.Migration.Name, applied)
+		}
+
+	case "dry-run":
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			logger.Error("Failed to read migrations", "error", err)
+			return 1
+		}
+		if len(pending) == 0 {
+			fmt.Println("No pending migrations")
+		}
+		for _, migration := range pending {
+			fmt.Printf("would apply %4d  %s\n", migration.Version, migration.Name)
+		}
+
+	case "apply":
+		applied, err := migrator.Apply(ctx)
+		for _, migration := range applied {
+			fmt.Printf("applied %4d  %s\n", migration.Version, migration.Name)
+		}
+		if err != nil {
+			logger.Error("Migration failed", "error", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("No pending migrations")
+		}
+
+	default:
+		fmt.Println(migrateUsage)
+		return 2
+	}
+
+	return 0
+}
@@ -1,11 +1,40 @@
 package config
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	Port         string
+	Port string
+	// HTTP server timeouts. WriteTimeout must leave room for AI replies,
+	// and ShutdownTimeout bounds how long in-flight requests and
+	// background work may take to finish on SIGTERM.
+	HTTPReadTimeout  time.Duration
+	HTTPWriteTimeout time.Duration
+	HTTPIdleTimeout  time.Duration
+	ShutdownTimeout  time.Duration
+	// On SIGTERM readiness fails for ShutdownDrainDelay before the server
+	// stops accepting requests, giving load balancers time to notice
+	ShutdownDrainDelay time.Duration
+	HealthCheckTimeout time.Duration
+	// Deadlines of single operations, unless the request's own deadline is
+	// sooner; zero means no limit. StorageTimeout applies to S3, local disk
+	// is not bounded.
+	DBTimeout      time.Duration
+	LLMTimeout     time.Duration
+	StorageTimeout time.Duration
+
+	// How long the request answering a chat message holds its reply before
+	// another request may retry it. The last 15s of it are kept for storing
+	// the reply, so it must be longer than that.
+	ReplyLease time.Duration
+
 	MongoURI     string
 	DatabaseName string
 	JWTSecret    string
@@ -14,25 +43,264 @@ type Config struct {
 	AWSAccessKey string
 	AWSSecretKey string
 	S3Bucket     string
+
+	// Blob storage for uploads: s3 (also S3-compatible services) or local.
+	// StorageSigningKey signs local download links; it defaults to a key
+	// derived from JWTSecret, never the secret itself.
+	StorageBackend    string
+	S3Endpoint        string
+	S3ForcePathStyle  bool
+	LocalStorageDir   string
+	PublicBaseURL     string
+	StorageSigningKey string
+	SignedURLTTL      time.Duration
+
+	// Largest accepted upload, in bytes, the number of files that can be
+	// sent with one message and how many images have their thumbnails
+	// generated at once
+	UploadMaxSize  int64
+	UploadMaxFiles int
+	ImageWorkers   int
+
+	// Login brute-force protection
+	LoginMaxAttempts     int
+	LoginLockoutDuration time.Duration
+	LoginBackoffBase     time.Duration
+	LoginBackoffMax      time.Duration
+	LoginIPMaxAttempts   int
+	LoginIPWindow        time.Duration
+
+	// Request rate limits per route group, as scope=rate lists such as
+	// "ip=60/m,user=20/m,admin=off" (see ratelimit.ParsePolicy), and daily
+	// quotas per role such as "user=100,doctor=off". Client IPs are taken
+	// from X-Forwarded-For only when the request comes from TrustedProxies.
+	RateLimitPublic string
+	RateLimitAuth   string
+	RateLimitAPI    string
+	RateLimitChat   string
+	AIMessageQuota  string
+	UploadQuota     string
+	TrustedProxies  []string
+
+	// LLM cost accounting. LLMPrices lists model=prompt/completion prices in
+	// USD per million tokens; LLMMonthlyBudget is the default budget of a
+	// user in USD per calendar month, zero for none.
+	LLMPrices        string
+	LLMMonthlyBudget float64
+
+	// LLM resilience. LLMModels and LLMVisionModels list provider:model
+	// targets in the order they are tried; failed calls are retried with
+	// jittered backoff and a provider's breaker opens after
+	// LLMBreakerFailures transient failures in a row.
+	LLMModels          string
+	LLMVisionModels    string
+	LLMMaxRetries      int
+	LLMRetryBaseDelay  time.Duration
+	LLMRetryMaxDelay   time.Duration
+	LLMBreakerFailures int
+	LLMBreakerCooldown time.Duration
+
+	// Two-factor authentication
+	MFAIssuer        string
+	MFARequiredRoles []string
+
+	// OpenID Connect sign-in. The provider name is appended to
+	// OIDCRedirectBaseURL to form each provider's redirect URI.
+	OIDCRedirectBaseURL string
+	GoogleClientID      string
+	GoogleClientSecret  string
+	OIDCProviderName    string
+	OIDCIssuer          string
+	OIDCClientID        string
+	OIDCClientSecret    string
+
+	// Account management. MailBackend is log to only log outgoing mail
+	// during development; without it, features that send mail are off.
+	MailBackend          string
+	AppBaseURL           string
+	AppointmentRetention string // delete or anonymize
+
+	// Schema migrations. Replicas wait up to MigrationLockTimeout for
+	// another replica that is already migrating.
+	MigrateOnStartup     bool
+	MigrationLockTimeout time.Duration
+
+	// Tracing. TracingExporter is none or otlp; OTLPEndpoint is a host:port
+	// receiving OTLP over HTTP. TracingSampleRatio applies to new traces,
+	// incoming traces keep the caller's decision.
+	TracingExporter    string
+	TracingServiceName string
+	OTLPEndpoint       string
+	OTLPInsecure       bool
+	TracingSampleRatio float64
+
+	// Logs are written as JSON to stdout; LogLevel is debug, info, warn or
+	// error
+	LogLevel string
 }
 
 func Load() *Config {
+	jwtSecret := getEnv("JWT_SECRET", "your-secret-key")
+
+	// Without AWS credentials default to local disk so the server runs
+	// on a laptop or in CI without extra setup
+	defaultStorageBackend := "local"
+	if os.Getenv("AWS_ACCESS_KEY") != "" {
+		defaultStorageBackend = "s3"
+	}
+
 	return &Config{
-		Port:         getEnv("PORT", "8080"),
+		Port:             getEnv("PORT", "8080"),
+		HTTPReadTimeout:  getEnvDuration("HTTP_READ_TIMEOUT", 30*time.Second),
+		HTTPWriteTimeout: getEnvDuration("HTTP_WRITE_TIMEOUT", 2*time.Minute),
+		HTTPIdleTimeout:  getEnvDuration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+		ShutdownTimeout:  getEnvDuration("SHUTDOWN_TIMEOUT", 30*time.Second),
+
+		ShutdownDrainDelay: getEnvDuration("SHUTDOWN_DRAIN_DELAY", 5*time.Second),
+		HealthCheckTimeout: getEnvDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+
+		DBTimeout:      getEnvDuration("DB_TIMEOUT", 10*time.Second),
+		LLMTimeout:     getEnvDuration("LLM_TIMEOUT", 60*time.Second),
+		StorageTimeout: getEnvDuration("STORAGE_TIMEOUT", 30*time.Second),
+		ReplyLease:     getEnvDuration("REPLY_LEASE", 2*time.Minute),
+
 		MongoURI:     getEnv("MONGO_URI", "mongodb://localhost:27017"),
 		DatabaseName: getEnv("DATABASE_NAME", "ai_doctor_db"),
-		JWTSecret:    getEnv("JWT_SECRET", "your-secret-key"),
+		JWTSecret:    jwtSecret,
 		GeminiAPIKey: getEnv("GEMINI_API_KEY", ""),
 		AWSRegion:    getEnv("AWS_REGION", "us-east-1"),
 		AWSAccessKey: getEnv("AWS_ACCESS_KEY", ""),
 		AWSSecretKey: getEnv("AWS_SECRET_KEY", ""),
 		S3Bucket:     getEnv("S3_BUCKET", "ai-doctor-images"),
+
+		StorageBackend:    getEnv("STORAGE_BACKEND", defaultStorageBackend),
+		S3Endpoint:        getEnv("S3_ENDPOINT", ""),
+		S3ForcePathStyle:  getEnvBool("S3_FORCE_PATH_STYLE", false),
+		LocalStorageDir:   getEnv("LOCAL_STORAGE_DIR", "./data/uploads"),
+		PublicBaseURL:     getEnv("PUBLIC_BASE_URL", "http://localhost:8080"),
+		StorageSigningKey: getEnv("STORAGE_SIGNING_KEY", deriveKey(jwtSecret, "storage-signing-key")),
+		SignedURLTTL:      getEnvDuration("SIGNED_URL_TTL", 15*time.Minute),
+
+		UploadMaxSize:  int64(getEnvInt("UPLOAD_MAX_SIZE_MB", 10)) << 20,
+		UploadMaxFiles: getEnvInt("UPLOAD_MAX_FILES", 5),
+		ImageWorkers:   getEnvInt("IMAGE_WORKERS", 2),
+
+		LoginMaxAttempts:     getEnvInt("LOGIN_MAX_ATTEMPTS", 5),
+		LoginLockoutDuration: getEnvDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		LoginBackoffBase:     getEnvDuration("LOGIN_BACKOFF_BASE", time.Second),
+		LoginBackoffMax:      getEnvDuration("LOGIN_BACKOFF_MAX", 30*time.Second),
+		LoginIPMaxAttempts:   getEnvInt("LOGIN_IP_MAX_ATTEMPTS", 20),
+		LoginIPWindow:        getEnvDuration("LOGIN_IP_WINDOW", 15*time.Minute),
+
+		RateLimitPublic: getEnv("RATE_LIMIT_PUBLIC", "ip=120/m"),
+		RateLimitAuth:   getEnv("RATE_LIMIT_AUTH", "ip=20/m"),
+		RateLimitAPI:    getEnv("RATE_LIMIT_API", "ip=300/m,user=120/m"),
+		RateLimitChat:   getEnv("RATE_LIMIT_CHAT", "ip=30/m,user=10/m"),
+		AIMessageQuota:  getEnv("AI_MESSAGE_QUOTA", "user=100"),
+		UploadQuota:     getEnv("UPLOAD_QUOTA", "user=50"),
+		TrustedProxies:  getEnvList("TRUSTED_PROXIES", []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "127.0.0.0/8", "::1"}),
+
+		LLMPrices:        getEnv("LLM_PRICES", "gemini-pro=0.5/1.5,gemini-pro-vision=0.5/1.5,gemini-1.5-flash=0.075/0.3"),
+		LLMMonthlyBudget: getEnvFloat("LLM_MONTHLY_BUDGET_USD", 0),
+
+		LLMModels:          getEnv("LLM_MODELS", "gemini:gemini-pro,gemini:gemini-1.5-flash"),
+		LLMVisionModels:    getEnv("LLM_VISION_MODELS", "gemini:gemini-pro-vision,gemini:gemini-1.5-flash"),
+		LLMMaxRetries:      getEnvInt("LLM_MAX_RETRIES", 2),
+		LLMRetryBaseDelay:  getEnvDuration("LLM_RETRY_BASE_DELAY", 500*time.Millisecond),
+		LLMRetryMaxDelay:   getEnvDuration("LLM_RETRY_MAX_DELAY", 4*time.Second),
+		LLMBreakerFailures: getEnvInt("LLM_BREAKER_FAILURES", 5),
+		LLMBreakerCooldown: getEnvDuration("LLM_BREAKER_COOLDOWN", 30*time.Second),
+
+		MFAIssuer:        getEnv("MFA_ISSUER", "MedAI"),
+		MFARequiredRoles: getEnvList("MFA_REQUIRED_ROLES", []string{"doctor", "admin"}),
+
+		OIDCRedirectBaseURL: getEnv("OIDC_REDIRECT_BASE_URL", "http://localhost:3000/auth/callback"),
+		GoogleClientID:      getEnv("GOOGLE_CLIENT_ID", ""),
+		GoogleClientSecret:  getEnv("GOOGLE_CLIENT_SECRET", ""),
+		OIDCProviderName:    getEnv("OIDC_PROVIDER_NAME", "oidc"),
+		OIDCIssuer:          getEnv("OIDC_ISSUER", ""),
+		OIDCClientID:        getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret:    getEnv("OIDC_CLIENT_SECRET", ""),
+
+		MailBackend:          getEnv("MAIL_BACKEND", "none"),
+		AppBaseURL:           getEnv("APP_BASE_URL", "http://localhost:3000"),
+		AppointmentRetention: getEnv("APPOINTMENT_RETENTION", "anonymize"),
+
+		MigrateOnStartup:     getEnvBool("MIGRATE_ON_STARTUP", true),
+		MigrationLockTimeout: getEnvDuration("MIGRATION_LOCK_TIMEOUT", 5*time.Minute),
+
+		TracingExporter:    getEnv("TRACING_EXPORTER", "none"),
+		TracingServiceName: getEnv("TRACING_SERVICE_NAME", "medai"),
+		OTLPEndpoint:       getEnv("OTLP_ENDPOINT", "localhost:4318"),
+		OTLPInsecure:       getEnvBool("OTLP_INSECURE", false),
+		TracingSampleRatio: getEnvFloat("TRACING_SAMPLE_RATIO", 1),
+
+		LogLevel: getEnv("LOG_LEVEL", "info"),
 	}
 }
 
+// deriveKey derives a key for label from secret, so that one configured
+// secret can back several keys without a leak of one exposing the others.
+func deriveKey(secret, label string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(label))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
@@ -2,11 +2,11 @@ package db
 
 import (
 	"context"
-	"log"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
 )
 
 type Database struct {
@@ -14,11 +14,17 @@ type Database struct {
 	DB     *mongo.Database
 }
 
-func Initialize(mongoURI, dbName string) (*Database, error) {
+// Initialize connects to MongoDB. Operations whose context has no deadline
+// are given opTimeout.
+func Initialize(mongoURI, dbName string, opTimeout time.Duration) (*Database, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
+	// Commands are traced as children of the span in their context
+	client, err := mongo.Connect(ctx, options.Client().
+		ApplyURI(mongoURI).
+		SetTimeout(opTimeout).
+		SetMonitor(otelmongo.NewMonitor()))
 	if err != nil {
 		return nil, err
 	}
@@ -28,26 +34,22 @@ func Initialize(mongoURI, dbName string) (*Database, error) {
 		return nil, err
 	}
 
-	log.Println("Connected to MongoDB")
-
-	db := client.Database(dbName)
-
-	// Initialize collections and seed data
-	database := &Database{
+	// Collections, indexes and seed data are set up by migrations
+	return &Database{
 		Client: client,
-		DB:     db,
-	}
-
-	// Seed initial data
-	database.seedData()
-
-	return database, nil
+		DB:     client.Database(dbName),
+	}, nil
 }
 
 func (d *Database) GetCollection(name string) *mongo.Collection {
 	return d.DB.Collection(name)
 }
 
+// Ping checks that the server can be reached.
+func (d *Database) Ping(ctx context.Context) error {
+	return d.Client.Ping(ctx, nil)
+}
+
 func (d *Database) Close() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/subhammahanty235/medai/internal/middleware"
+	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountHandler struct {
+	accountService *service.AccountService
+}
+
+func NewAccountHandler(accountService *service.AccountService) *AccountHandler {
+	return &AccountHandler{
+		accountService: accountService,
+	}
+}
+
+func (h *AccountHandler) UpdateProfile(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		respondError(c, errUnauthenticated)
+		return
+	}
+
+	var req models.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindingError(err))
+		return
+	}
+
+	user, err := h.accountService.UpdateProfile(c.Request.Context(), userID, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+func (h *AccountHandler) ChangePassword(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		respondError(c, errUnauthenticated)
+		return
+	}
+
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindingError(err))
+		return
+	}
+
+	response, err := h.accountService.ChangePassword(c.Request.Context(), userID, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func (h *AccountHandler) RequestEmailChange(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		respondError(c, errUnauthenticated)
+		return
+	}
+
+	var req models.ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindingError(err))
+		return
+	}
+
+	if err := h.accountService.RequestEmailChange(c.Request.Context(), userID, req); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Verification link sent to the new email address"})
+}
+
+func (h *AccountHandler) VerifyEmailChange(c *gin.Context) {
+	var req models.VerifyEmailChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindingError(err))
+		return
+	}
+
+	if err := h.accountService.VerifyEmailChange(c.Request.Context(), req); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email address updated, please log in again"})
+}
+
+func (h *AccountHandler) DeleteAccount(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		respondError(c, errUnauthenticated)
+		return
+	}
+
+	var req models.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindingError(err))
+		return
+	}
+
+	if err := h.accountService.DeleteAccount(c.Request.Context(), userID, req); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deleted"})
+}
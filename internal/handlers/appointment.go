@@ -23,13 +23,13 @@ func NewAppointmentHandler(appointmentService *service.AppointmentService) *Appo
 func (h *AppointmentHandler) BookAppointment(c *gin.Context) {
 	userID, ok := middleware.GetUserID(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID"})
+		respondError(c, errUnauthenticated)
 		return
 	}
 
 	var req models.AppointmentRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, bindingError(err))
 		return
 	}
 
@@ -40,14 +40,14 @@ func (h *AppointmentHandler) BookAppointment(c *gin.Context) {
 		var err error
 		chatSessionID, err = primitive.ObjectIDFromHex(chatSessionIDStr)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid chat session ID"})
+			respondError(c, invalidParam("chat_session_id", "Invalid chat session ID"))
 			return
 		}
 	}
 
-	appointment, err := h.appointmentService.BookAppointment(userID, req, chatSessionID)
+	appointment, err := h.appointmentService.BookAppointment(c.Request.Context(), userID, req, chatSessionID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -57,13 +57,13 @@ func (h *AppointmentHandler) BookAppointment(c *gin.Context) {
 func (h *AppointmentHandler) GetUserAppointments(c *gin.Context) {
 	userID, ok := middleware.GetUserID(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID"})
+		respondError(c, errUnauthenticated)
 		return
 	}
 
-	appointments, err := h.appointmentService.GetUserAppointments(userID)
+	appointments, err := h.appointmentService.GetUserAppointments(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -74,21 +74,21 @@ func (h *AppointmentHandler) UpdateAppointmentStatus(c *gin.Context) {
 	appointmentIDStr := c.Param("id")
 	appointmentID, err := primitive.ObjectIDFromHex(appointmentIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid appointment ID"})
+		respondError(c, invalidParam("id", "Invalid appointment ID"))
 		return
 	}
 
 	var req struct {
-		Status string `json:"status" binding:"required"`
+		Status string `json:"status" binding:"required,oneof=pending confirmed completed cancelled"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, bindingError(err))
 		return
 	}
 
-	err = h.appointmentService.UpdateAppointmentStatus(appointmentID, req.Status)
+	err = h.appointmentService.UpdateAppointmentStatus(c.Request.Context(), appointmentID, req.Status)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -99,13 +99,13 @@ func (h *AppointmentHandler) GetAppointment(c *gin.Context) {
 	appointmentIDStr := c.Param("id")
 	appointmentID, err := primitive.ObjectIDFromHex(appointmentIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid appointment ID"})
+		respondError(c, invalidParam("id", "Invalid appointment ID"))
 		return
 	}
 
-	appointment, err := h.appointmentService.GetAppointmentByID(appointmentID)
+	appointment, err := h.appointmentService.GetAppointmentByID(c.Request.Context(), appointmentID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Appointment not found"})
+		respondError(c, err)
 		return
 	}
 
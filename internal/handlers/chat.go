@@ -1,46 +1,66 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"mime/multipart"
 	"net/http"
+	"path/filepath"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/subhammahanty235/medai/internal/metrics"
 	"github.com/subhammahanty235/medai/internal/middleware"
 	"github.com/subhammahanty235/medai/internal/models"
 	"github.com/subhammahanty235/medai/internal/service"
+	"github.com/subhammahanty235/medai/internal/upload"
 
 	// "github.com/subhammahanty235/medai/internal/shared"
-	"github.com/subhammahanty235/medai/internal/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type ChatHandler struct {
 	chatService *service.ChatService
-	s3Client    *utils.S3Client
+	uploads     *upload.Pipeline
+	maxFiles    int
 }
 
-func NewChatHandler(chatService *service.ChatService, s3Client *utils.S3Client) *ChatHandler {
+func NewChatHandler(chatService *service.ChatService, uploads *upload.Pipeline, maxFiles int) *ChatHandler {
 	return &ChatHandler{
 		chatService: chatService,
-		s3Client:    s3Client,
+		uploads:     uploads,
+		maxFiles:    maxFiles,
 	}
 }
 
 func (h *ChatHandler) StartChat(c *gin.Context) {
 	userID, ok := middleware.GetUserID(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID"})
+		respondError(c, errUnauthenticated)
 		return
 	}
 
 	doctorID := c.Param("doctorId")
 	if doctorID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Doctor ID is required"})
+		respondError(c, invalidParam("doctorId", "Doctor ID is required"))
 		return
 	}
 
-	session, err := h.chatService.StartChatSession(userID, doctorID)
+	// Get dependent ID from query parameter (optional)
+	dependentIDStr := c.Query("dependent_id")
+	var dependentID primitive.ObjectID
+	if dependentIDStr != "" {
+		var err error
+		dependentID, err = primitive.ObjectIDFromHex(dependentIDStr)
+		if err != nil {
+			respondError(c, invalidParam("dependent_id", "Invalid dependent ID"))
+			return
+		}
+	}
+
+	session, err := h.chatService.StartChatSession(c.Request.Context(), userID, doctorID, dependentID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -50,88 +70,208 @@ func (h *ChatHandler) StartChat(c *gin.Context) {
 func (h *ChatHandler) SendMessage(c *gin.Context) {
 	userID, ok := middleware.GetUserID(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID"})
+		respondError(c, errUnauthenticated)
 		return
 	}
 
 	sessionIDStr := c.Param("sessionId")
 	sessionID, err := primitive.ObjectIDFromHex(sessionIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		respondError(c, invalidParam("sessionId", "Invalid session ID"))
 		return
 	}
 
 	var req models.ChatMessageRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, bindingError(err))
 		return
 	}
 
-	message, err := h.chatService.SendMessage(sessionID, userID, req.Content, "")
+	message, err := h.chatService.SendMessage(c.Request.Context(), sessionID, userID, req.Content, nil)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, message)
 }
 
-func (h *ChatHandler) UploadImage(c *gin.Context) {
+// RetryReply asks the AI doctor again to answer the latest message of the
+// session, for sends that failed with reply_pending.
+func (h *ChatHandler) RetryReply(c *gin.Context) {
 	userID, ok := middleware.GetUserID(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID"})
+		respondError(c, errUnauthenticated)
+		return
+	}
+
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("sessionId"))
+	if err != nil {
+		respondError(c, invalidParam("sessionId", "Invalid session ID"))
+		return
+	}
+
+	message, err := h.chatService.RetryReply(c.Request.Context(), sessionID, userID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, message)
+}
+
+// UploadFiles shares images and documents (PDF or plain text) in a chat,
+// all attached to one message. Files are sent in the "files" form field;
+// the single "file" and "image" fields of older clients are still accepted.
+func (h *ChatHandler) UploadFiles(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		respondError(c, errUnauthenticated)
 		return
 	}
 
 	sessionIDStr := c.Param("sessionId")
 	sessionID, err := primitive.ObjectIDFromHex(sessionIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		respondError(c, invalidParam("sessionId", "Invalid session ID"))
 		return
 	}
 
-	file, fileHeader, err := c.Request.FormFile("image")
+	// Reject oversized bodies before they are buffered, leaving room for
+	// the multipart framing and the content field
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(h.maxFiles)*h.uploads.MaxSize+1<<20)
+
+	form, err := c.MultipartForm()
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to get image file"})
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			metrics.ObserveUpload("", "too_large", c.Request.ContentLength)
+			respondError(c, sizedUploadError(h.uploads, upload.ErrTooLarge))
+			return
+		}
+		respondError(c, service.Invalid("malformed_body", "Failed to parse uploaded files"))
 		return
 	}
-	defer file.Close()
 
-	// Upload to S3
-	imageURL, err := h.s3Client.UploadFile(file, fileHeader)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload image"})
+	var fileHeaders []*multipart.FileHeader
+	for _, field := range []string{"files", "file", "image"} {
+		fileHeaders = append(fileHeaders, form.File[field]...)
+	}
+	if len(fileHeaders) == 0 {
+		respondError(c, service.Invalid("no_files", "No files uploaded",
+			models.FieldError{Field: "files", Code: "required", Message: "is required"}))
+		return
+	}
+	if len(fileHeaders) > h.maxFiles {
+		message := fmt.Sprintf("At most %d files can be sent with one message", h.maxFiles)
+		respondError(c, service.Invalid("too_many_files", message,
+			models.FieldError{Field: "files", Code: "max", Message: message}))
 		return
 	}
 
+	// Validate the content and strip metadata before anything is stored
+	uploads := make([]service.Upload, 0, len(fileHeaders))
+	hasImages, hasDocuments := false, false
+	for _, fileHeader := range fileHeaders {
+		file, err := h.processFile(fileHeader)
+		if err != nil {
+			respondError(c, sizedUploadError(h.uploads, err))
+			return
+		}
+
+		fileName := filepath.Base(fileHeader.Filename)
+		if fileName == "." || fileName == string(filepath.Separator) {
+			fileName = "upload" + file.Extension
+		}
+
+		uploads = append(uploads, service.Upload{File: file, FileName: fileName})
+		if file.IsImage() {
+			hasImages = true
+		} else {
+			hasDocuments = true
+		}
+	}
+
 	// Get message content from form
 	content := c.PostForm("content")
 	if content == "" {
-		content = "I've uploaded an image. Please analyze it."
+		switch {
+		case hasImages && hasDocuments:
+			content = "I've shared some files. Please review them."
+		case hasDocuments:
+			content = "I've shared a document. Please review it."
+		default:
+			content = "I've uploaded an image. Please analyze it."
+		}
 	}
 
-	message, err := h.chatService.SendMessage(sessionID, userID, content, imageURL)
+	message, attachments, err := h.chatService.SendMessageWithAttachments(c.Request.Context(), sessionID, userID, content, uploads)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, sizedUploadError(h.uploads, err))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":   message,
-		"image_url": imageURL,
+		"message": message,
+		// Image thumbnails are generated in the background and appear on
+		// the attachments in the chat session once ready
+		"attachments": attachments,
 	})
 }
 
+func (h *ChatHandler) processFile(fileHeader *multipart.FileHeader) (*upload.File, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	processed, err := h.uploads.Process(file)
+	if err != nil {
+		metrics.ObserveUpload("", uploadOutcome(err), fileHeader.Size)
+		return nil, err
+	}
+	metrics.ObserveUpload(processed.ContentType, "accepted", processed.Size())
+	return processed, nil
+}
+
+// uploadOutcome names the reason an upload was rejected for metrics.
+func uploadOutcome(err error) string {
+	switch {
+	case errors.Is(err, upload.ErrTooLarge):
+		return "too_large"
+	case errors.Is(err, upload.ErrUnsupportedType):
+		return "unsupported_type"
+	case errors.Is(err, upload.ErrCorrupt):
+		return "corrupt"
+	default:
+		return "error"
+	}
+}
+
+// sizedUploadError names the configured limit when err rejects a file for
+// its size.
+func sizedUploadError(pipeline *upload.Pipeline, err error) error {
+	if !errors.Is(err, upload.ErrTooLarge) {
+		return err
+	}
+	return &upload.Error{
+		Status:  upload.ErrTooLarge.Status,
+		Code:    upload.ErrTooLarge.Code,
+		Message: pipeline.MaxSizeMessage(),
+	}
+}
+
 func (h *ChatHandler) GetChatHistory(c *gin.Context) {
 	userID, ok := middleware.GetUserID(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID"})
+		respondError(c, errUnauthenticated)
 		return
 	}
 
-	sessions, err := h.chatService.GetChatHistory(userID)
+	sessions, err := h.chatService.GetChatHistory(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -141,22 +281,62 @@ func (h *ChatHandler) GetChatHistory(c *gin.Context) {
 func (h *ChatHandler) GetChatSession(c *gin.Context) {
 	userID, ok := middleware.GetUserID(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID"})
+		respondError(c, errUnauthenticated)
 		return
 	}
 
 	sessionIDStr := c.Param("sessionId")
 	sessionID, err := primitive.ObjectIDFromHex(sessionIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid session ID"})
+		respondError(c, invalidParam("sessionId", "Invalid session ID"))
 		return
 	}
 
-	session, err := h.chatService.GetChatSession(sessionID, userID)
+	session, err := h.chatService.GetChatSession(c.Request.Context(), sessionID, userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Chat session not found"})
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, session)
 }
+
+// GetMessages returns a page of a session's messages. Pass the seq of the
+// oldest message received as before to page further back.
+func (h *ChatHandler) GetMessages(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		respondError(c, errUnauthenticated)
+		return
+	}
+
+	sessionID, err := primitive.ObjectIDFromHex(c.Param("sessionId"))
+	if err != nil {
+		respondError(c, invalidParam("sessionId", "Invalid session ID"))
+		return
+	}
+
+	var before int64
+	if value := c.Query("before"); value != "" {
+		if before, err = strconv.ParseInt(value, 10, 64); err != nil || before < 1 {
+			respondError(c, invalidParam("before", "Invalid before"))
+			return
+		}
+	}
+
+	var limit int
+	if value := c.Query("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 {
+			respondError(c, invalidParam("limit", "Invalid limit"))
+			return
+		}
+	}
+
+	page, err := h.chatService.GetMessages(c.Request.Context(), sessionID, userID, before, limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
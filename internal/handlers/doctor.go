@@ -19,9 +19,9 @@ func NewDoctorHandler(doctorService *service.DoctorService) *DoctorHandler {
 }
 
 func (h *DoctorHandler) GetAllDoctors(c *gin.Context) {
-	doctors, err := h.doctorService.GetAllDoctors()
+	doctors, err := h.doctorService.GetAllDoctors(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -31,9 +31,9 @@ func (h *DoctorHandler) GetAllDoctors(c *gin.Context) {
 func (h *DoctorHandler) GetDoctorByID(c *gin.Context) {
 	doctorID := c.Param("id")
 
-	doctor, err := h.doctorService.GetDoctorByID(doctorID)
+	doctor, err := h.doctorService.GetDoctorByID(c.Request.Context(), doctorID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Doctor not found"})
+		respondError(c, err)
 		return
 	}
 
@@ -47,18 +47,18 @@ func (h *DoctorHandler) GetRealDoctors(c *gin.Context) {
 	// var err error
 
 	if specialty != "" {
-		realDoctors, err := h.doctorService.GetRealDoctorsBySpecialty(specialty)
+		realDoctors, err := h.doctorService.GetRealDoctorsBySpecialty(c.Request.Context(), specialty)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			respondError(c, err)
 			return
 		}
 		for _, doc := range realDoctors {
 			doctors = append(doctors, doc)
 		}
 	} else {
-		realDoctors, err := h.doctorService.GetAllRealDoctors()
+		realDoctors, err := h.doctorService.GetAllRealDoctors(c.Request.Context())
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			respondError(c, err)
 			return
 		}
 		for _, doc := range realDoctors {
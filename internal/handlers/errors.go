@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// errUnauthenticated is returned when the auth middleware left no user on
+// the request.
+var errUnauthenticated = service.Unauthorized("unauthenticated", "Invalid user ID")
+
+// respondError hands err to middleware.ErrorMiddleware, which renders it.
+func respondError(c *gin.Context, err error) {
+	c.Error(err)
+}
+
+// invalidParam rejects a malformed path or query parameter.
+func invalidParam(name, message string) error {
+	return service.Invalid("invalid_parameter", message,
+		models.FieldError{Field: name, Code: "invalid", Message: message})
+}
+
+// bindingError describes a request body that failed to bind, listing every
+// field that broke a validation rule. Values are never echoed back.
+func bindingError(err error) error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]models.FieldError, 0, len(validationErrs))
+		for _, fieldErr := range validationErrs {
+			fields = append(fields, models.FieldError{
+				Field:   fieldPath(fieldErr),
+				Code:    fieldErr.Tag(),
+				Message: ruleMessage(fieldErr),
+			})
+		}
+		// The first field also goes into the message for clients that
+		// only show that
+		message := fmt.Sprintf("%s %s", fields[0].Field, fields[0].Message)
+		return service.Invalid("validation_failed", message, fields...)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		message := "has the wrong type"
+		return service.Invalid("validation_failed", typeErr.Field+" "+message,
+			models.FieldError{Field: typeErr.Field, Code: "type", Message: message})
+	}
+
+	return service.Invalid("malformed_body", "The request body is malformed")
+}
+
+// embeddedField marks the names of embedded structs, whose fields are
+// reported as if they belonged to the outer request.
+const embeddedField = "~"
+
+// UseJSONFieldNames makes validation errors name fields by their JSON keys,
+// as clients know them. Call it once before serving.
+func UseJSONFieldNames() {
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+
+	engine.RegisterTagNameFunc(func(field reflect.StructField) string {
+		if field.Anonymous {
+			return embeddedField + field.Name
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			return field.Name
+		}
+		return name
+	})
+}
+
+// fieldPath is the dotted JSON path of the field, such as
+// current_medications[0].name, without the name of the request type.
+func fieldPath(fieldErr validator.FieldError) string {
+	segments := strings.Split(fieldErr.Namespace(), ".")[1:]
+
+	path := segments[:0]
+	for _, segment := range segments {
+		if !strings.HasPrefix(segment, embeddedField) {
+			path = append(path, segment)
+		}
+	}
+	return strings.Join(path, ".")
+}
+
+func ruleMessage(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min":
+		if fieldErr.Kind() == reflect.String {
+			return fmt.Sprintf("must be at least %s characters", fieldErr.Param())
+		}
+		return fmt.Sprintf("must be at least %s", fieldErr.Param())
+	case "max":
+		if fieldErr.Kind() == reflect.String {
+			return fmt.Sprintf("must be at most %s characters", fieldErr.Param())
+		}
+		return fmt.Sprintf("must be at most %s", fieldErr.Param())
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fieldErr.Param(), " ", ", ")
+	default:
+		return "is invalid"
+	}
+}
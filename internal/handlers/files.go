@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/subhammahanty235/medai/internal/service"
+	"github.com/subhammahanty235/medai/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FileHandler serves objects of the local disk storage backend. Access is
+// granted by the HMAC signature on the link rather than a bearer token, so
+// links can be used directly in <img> tags.
+type FileHandler struct {
+	storage *storage.LocalStorage
+}
+
+func NewFileHandler(localStorage *storage.LocalStorage) *FileHandler {
+	return &FileHandler{
+		storage: localStorage,
+	}
+}
+
+func (h *FileHandler) GetFile(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+
+	if err := h.storage.Verify(key, c.Query("expires"), c.Query("signature")); err != nil {
+		respondError(c, service.Forbidden("invalid_link", err.Error()))
+		return
+	}
+
+	body, info, err := h.storage.Get(c.Request.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		respondError(c, service.NotFound("file_not_found", "File not found"))
+		return
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	defer body.Close()
+
+	c.Header("Content-Type", info.ContentType)
+	c.Header("Content-Length", strconv.FormatInt(info.Size, 10))
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+	io.Copy(c.Writer, body)
+}
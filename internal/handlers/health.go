@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/subhammahanty235/medai/internal/health"
+)
+
+type HealthHandler struct {
+	health *health.Health
+}
+
+func NewHealthHandler(health *health.Health) *HealthHandler {
+	return &HealthHandler{health: health}
+}
+
+// Liveness answers as long as the process can serve requests; dependencies
+// are not checked so that an outage does not get healthy instances
+// restarted.
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusOK})
+}
+
+// Readiness reports whether the instance should receive traffic, with the
+// status and latency of each dependency.
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	report := h.health.Readiness(c.Request.Context())
+
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/subhammahanty235/medai/internal/middleware"
+	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/service"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type HealthProfileHandler struct {
+	healthProfileService *service.HealthProfileService
+}
+
+func NewHealthProfileHandler(healthProfileService *service.HealthProfileService) *HealthProfileHandler {
+	return &HealthProfileHandler{
+		healthProfileService: healthProfileService,
+	}
+}
+
+func (h *HealthProfileHandler) GetProfile(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		respondError(c, errUnauthenticated)
+		return
+	}
+
+	profile, err := h.healthProfileService.GetProfile(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func (h *HealthProfileHandler) UpdateProfile(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		respondError(c, errUnauthenticated)
+		return
+	}
+
+	var req models.HealthProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindingError(err))
+		return
+	}
+
+	profile, err := h.healthProfileService.UpdateProfile(c.Request.Context(), userID, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+func (h *HealthProfileHandler) DeleteProfile(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		respondError(c, errUnauthenticated)
+		return
+	}
+
+	if err := h.healthProfileService.DeleteProfile(c.Request.Context(), userID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Health profile deleted"})
+}
+
+func (h *HealthProfileHandler) AddDependent(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		respondError(c, errUnauthenticated)
+		return
+	}
+
+	var req models.DependentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindingError(err))
+		return
+	}
+
+	dependent, err := h.healthProfileService.AddDependent(c.Request.Context(), userID, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, dependent)
+}
+
+func (h *HealthProfileHandler) UpdateDependent(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		respondError(c, errUnauthenticated)
+		return
+	}
+
+	dependentID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, invalidParam("id", "Invalid dependent ID"))
+		return
+	}
+
+	var req models.DependentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindingError(err))
+		return
+	}
+
+	dependent, err := h.healthProfileService.UpdateDependent(c.Request.Context(), userID, dependentID, req)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dependent)
+}
+
+func (h *HealthProfileHandler) DeleteDependent(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		respondError(c, errUnauthenticated)
+		return
+	}
+
+	dependentID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, invalidParam("id", "Invalid dependent ID"))
+		return
+	}
+
+	if err := h.healthProfileService.DeleteDependent(c.Request.Context(), userID, dependentID); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Dependent deleted"})
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/middleware"
+	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcLoginCookie binds a provider sign-in to the browser that started it.
+// It is only sent to the OIDC routes and cannot be read by scripts.
+const (
+	oidcLoginCookie     = "oidc_login"
+	oidcLoginCookiePath = "/api/auth/oidc"
+)
+
+type OIDCHandler struct {
+	oidcService *service.OIDCService
+}
+
+func NewOIDCHandler(oidcService *service.OIDCService) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService: oidcService,
+	}
+}
+
+func (h *OIDCHandler) GetProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": h.oidcService.Providers()})
+}
+
+func (h *OIDCHandler) StartLogin(c *gin.Context) {
+	login, err := h.oidcService.StartLogin(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondOIDCLogin(c, login)
+}
+
+// StartLink starts a sign-in with the provider that links it to the
+// signed-in user's account.
+func (h *OIDCHandler) StartLink(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		respondError(c, errUnauthenticated)
+		return
+	}
+
+	login, err := h.oidcService.StartLink(c.Request.Context(), userID, c.Param("provider"))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	respondOIDCLogin(c, login)
+}
+
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	var req models.OIDCCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindingError(err))
+		return
+	}
+
+	// A missing cookie leaves the binding empty, which never matches
+	binding, _ := c.Cookie(oidcLoginCookie)
+	setOIDCLoginCookie(c, "", -1)
+
+	response, err := h.oidcService.CompleteLogin(c.Request.Context(), c.Param("provider"), req, binding)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func respondOIDCLogin(c *gin.Context, login *service.OIDCLogin) {
+	setOIDCLoginCookie(c, login.Binding, int(time.Until(login.ExpiresAt).Seconds()))
+	c.JSON(http.StatusOK, gin.H{"authorization_url": login.AuthorizationURL})
+}
+
+// setOIDCLoginCookie sets the binding cookie, or deletes it for a negative
+// maxAge. SameSite=Lax is enough as the app posts the callback itself.
+func setOIDCLoginCookie(c *gin.Context, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcLoginCookie,
+		Value:    value,
+		Path:     oidcLoginCookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/middleware"
+	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/oidcmock"
+	"github.com/subhammahanty235/medai/internal/repository"
+	"github.com/subhammahanty235/medai/internal/service"
+	"github.com/subhammahanty235/medai/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testJWTSecret = "test-secret"
+
+// oidcTest runs the OIDC routes against the mock provider, with the
+// services on in-memory repositories.
+type oidcTest struct {
+	provider *oidcmock.Server
+	users    repository.UserRepository
+	auth     *service.AuthService
+	router   *gin.Engine
+}
+
+func newOIDCTest(t *testing.T) *oidcTest {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	provider := oidcmock.NewServer("medai", "client-secret")
+	t.Cleanup(provider.Close)
+
+	client, err := utils.NewOIDCProvider(context.Background(), "mock", provider.Issuer(), "medai", "client-secret", "http://localhost/auth/callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repos := repository.NewMemoryRepositories()
+	audit := service.NewAuditService(repos.AuditEvents, logger)
+	loginGuard := service.NewLoginGuard(repos.LoginAttempts, service.LoginGuardConfig{MaxAttempts: 5, LockoutDuration: time.Hour}, audit)
+	auth := service.NewAuthService(repos.Users, testJWTSecret, loginGuard, service.MFAConfig{Issuer: "MedAI"})
+	handler := NewOIDCHandler(service.NewOIDCService(repos.OIDCLoginStates, auth, client))
+
+	router := gin.New()
+	router.Use(middleware.ErrorMiddleware(logger))
+	router.GET("/api/auth/oidc/:provider/start", handler.StartLogin)
+	router.POST("/api/auth/oidc/:provider/callback", handler.Callback)
+	protected := router.Group("/api", middleware.AuthMiddleware(testJWTSecret, auth.GetTokenVersion))
+	protected.GET("/auth/oidc/:provider/link", handler.StartLink)
+
+	return &oidcTest{provider: provider, users: repos.Users, auth: auth, router: router}
+}
+
+// start begins a sign-in, or a link for a non-empty token, and returns the
+// provider's code and state with the cookie binding them to the browser.
+func (o *oidcTest) start(t *testing.T, token string) (code, state string, cookie *http.Cookie) {
+	t.Helper()
+
+	path := "/api/auth/oidc/mock/start"
+	if token != "" {
+		path = "/api/auth/oidc/mock/link"
+	}
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	o.router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d %s", path, rec.Code, rec.Body)
+	}
+
+	var body struct {
+		AuthorizationURL string `json:"authorization_url"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == oidcLoginCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil || !cookie.HttpOnly {
+		t.Fatalf("GET %s cookies = %v, want an HttpOnly %s cookie", path, rec.Result().Cookies(), oidcLoginCookie)
+	}
+
+	code, state, err := o.provider.Authorize(body.AuthorizationURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code, state, cookie
+}
+
+// callback posts the provider's answer and returns the response.
+func (o *oidcTest) callback(t *testing.T, code, state string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+
+	body, _ := json.Marshal(models.OIDCCallbackRequest{Code: code, State: state})
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/oidc/mock/callback", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	o.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// signIn runs a whole sign-in and returns the signed-in user.
+func (o *oidcTest) signIn(t *testing.T, token string) models.User {
+	t.Helper()
+
+	code, state, cookie := o.start(t, token)
+	rec := o.callback(t, code, state, cookie)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("callback = %d %s", rec.Code, rec.Body)
+	}
+
+	var response models.AuthResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Token == "" {
+		t.Fatalf("callback = %s, want a token", rec.Body)
+	}
+	return response.User
+}
+
+func errorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var response models.ErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("error body %s: %v", rec.Body, err)
+	}
+	return response.Code
+}
+
+func TestOIDCSignInCreatesUser(t *testing.T) {
+	o := newOIDCTest(t)
+
+	user := o.signIn(t, "")
+	if user.Email != "mock.user@example.com" || !user.EmailVerified {
+		t.Errorf("user = %+v, want a verified account for the provider's email", user)
+	}
+
+	// Signing in again finds the same account
+	if again := o.signIn(t, ""); again.ID != user.ID {
+		t.Errorf("second sign-in user = %s, want %s", again.ID.Hex(), user.ID.Hex())
+	}
+}
+
+func TestOIDCCallbackRequiresBrowserBinding(t *testing.T) {
+	o := newOIDCTest(t)
+
+	code, state, _ := o.start(t, "")
+	if rec := o.callback(t, code, state, nil); rec.Code != http.StatusUnauthorized || errorCode(t, rec) != "invalid_sign_in_state" {
+		t.Errorf("callback without the cookie = %d %s, want invalid_sign_in_state", rec.Code, rec.Body)
+	}
+
+	// The cookie of another sign-in does not fit either
+	code, state, _ = o.start(t, "")
+	_, _, otherCookie := o.start(t, "")
+	if rec := o.callback(t, code, state, otherCookie); rec.Code != http.StatusUnauthorized || errorCode(t, rec) != "invalid_sign_in_state" {
+		t.Errorf("callback with another sign-in's cookie = %d %s, want invalid_sign_in_state", rec.Code, rec.Body)
+	}
+
+	// A tampered nonce is rejected, and the state is spent
+	code, state, cookie := o.start(t, "")
+	tampered := *cookie
+	tampered.Value = state + ".forged"
+	if rec := o.callback(t, code, state, &tampered); rec.Code != http.StatusUnauthorized {
+		t.Errorf("callback with a forged nonce = %d %s, want 401", rec.Code, rec.Body)
+	}
+	if rec := o.callback(t, code, state, cookie); rec.Code != http.StatusUnauthorized {
+		t.Errorf("callback reusing the state = %d %s, want 401", rec.Code, rec.Body)
+	}
+}
+
+func TestOIDCDoesNotLinkUnverifiedAccount(t *testing.T) {
+	o := newOIDCTest(t)
+	ctx := context.Background()
+
+	// Registered by someone who never proved they own the address
+	registered, err := o.auth.Register(ctx, models.RegisterRequest{Name: "Mock User", Email: "mock.user@example.com", Password: "secret1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, state, cookie := o.start(t, "")
+	rec := o.callback(t, code, state, cookie)
+	if rec.Code != http.StatusConflict || errorCode(t, rec) != "link_required" {
+		t.Fatalf("callback for an unverified account = %d %s, want link_required", rec.Code, rec.Body)
+	}
+	if user, _ := o.users.FindByID(ctx, registered.User.ID); len(user.Identities) != 0 {
+		t.Fatalf("identities = %+v, want none linked", user.Identities)
+	}
+
+	// Signed in to the account, its owner can link the provider
+	linked := o.signIn(t, registered.Token)
+	if linked.ID != registered.User.ID || len(linked.Identities) != 1 {
+		t.Fatalf("linked user = %+v, want the registered account with the identity", linked)
+	}
+	if user := o.signIn(t, ""); user.ID != registered.User.ID {
+		t.Errorf("sign-in after linking user = %s, want %s", user.ID.Hex(), registered.User.ID.Hex())
+	}
+}
+
+func TestOIDCLinksVerifiedAccount(t *testing.T) {
+	o := newOIDCTest(t)
+	ctx := context.Background()
+
+	existing := &models.User{Name: "Mock User", Email: "mock.user@example.com", EmailVerified: true, Role: "user"}
+	if err := o.users.Create(ctx, existing); err != nil {
+		t.Fatal(err)
+	}
+
+	if user := o.signIn(t, ""); user.ID != existing.ID {
+		t.Errorf("user = %s, want the verified account %s", user.ID.Hex(), existing.ID.Hex())
+	}
+}
+
+func TestOIDCIdentityLinkedToAnotherUser(t *testing.T) {
+	o := newOIDCTest(t)
+	ctx := context.Background()
+
+	o.signIn(t, "")
+	other, err := o.auth.Register(ctx, models.RegisterRequest{Name: "Ravi", Email: "ravi@example.com", Password: "secret1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, state, cookie := o.start(t, other.Token)
+	if rec := o.callback(t, code, state, cookie); rec.Code != http.StatusConflict || errorCode(t, rec) != "identity_in_use" {
+		t.Errorf("linking an identity of another user = %d %s, want identity_in_use", rec.Code, rec.Body)
+	}
+}
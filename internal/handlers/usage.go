@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/service"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UsageHandler serves the LLM usage and budgets of users to admins.
+type UsageHandler struct {
+	usageService *service.UsageService
+}
+
+func NewUsageHandler(usageService *service.UsageService) *UsageHandler {
+	return &UsageHandler{
+		usageService: usageService,
+	}
+}
+
+// UsageByDay, UsageByUser and UsageByPersona total LLM calls, tokens and
+// estimated cost. The from and to query parameters are UTC dates
+// (YYYY-MM-DD), both included; user_id and persona narrow the calls.
+func (h *UsageHandler) UsageByDay(c *gin.Context) {
+	h.summarize(c, service.UsageByDay)
+}
+
+func (h *UsageHandler) UsageByUser(c *gin.Context) {
+	h.summarize(c, service.UsageByUser)
+}
+
+func (h *UsageHandler) UsageByPersona(c *gin.Context) {
+	h.summarize(c, service.UsageByPersona)
+}
+
+func (h *UsageHandler) summarize(c *gin.Context, groupBy string) {
+	filter := service.UsageFilter{Persona: c.Query("persona")}
+
+	var err error
+	if value := c.Query("from"); value != "" {
+		if filter.From, err = time.Parse(time.DateOnly, value); err != nil {
+			respondError(c, invalidParam("from", "Invalid from, expected YYYY-MM-DD"))
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if filter.To, err = time.Parse(time.DateOnly, value); err != nil {
+			respondError(c, invalidParam("to", "Invalid to, expected YYYY-MM-DD"))
+			return
+		}
+		filter.To = filter.To.AddDate(0, 0, 1)
+	}
+	if value := c.Query("user_id"); value != "" {
+		if filter.UserID, err = primitive.ObjectIDFromHex(value); err != nil {
+			respondError(c, invalidParam("user_id", "Invalid user ID"))
+			return
+		}
+	}
+	if value := c.Query("limit"); value != "" {
+		if filter.Limit, err = strconv.Atoi(value); err != nil || filter.Limit < 1 {
+			respondError(c, invalidParam("limit", "Invalid limit"))
+			return
+		}
+	}
+
+	summaries, err := h.usageService.Summarize(c.Request.Context(), groupBy, filter)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"usage": summaries})
+}
+
+func (h *UsageHandler) GetBudget(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, invalidParam("id", "Invalid user ID"))
+		return
+	}
+
+	budget, err := h.usageService.Budget(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, budget)
+}
+
+// SetBudget gives a user a monthly budget of their own; 0 lets them spend
+// without limit.
+func (h *UsageHandler) SetBudget(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, invalidParam("id", "Invalid user ID"))
+		return
+	}
+
+	var req models.UsageBudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, bindingError(err))
+		return
+	}
+
+	budget, err := h.usageService.SetBudget(c.Request.Context(), userID, *req.MonthlyUSD)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, budget)
+}
+
+// ResetBudget puts a user back on the default budget.
+func (h *UsageHandler) ResetBudget(c *gin.Context) {
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		respondError(c, invalidParam("id", "Invalid user ID"))
+		return
+	}
+
+	budget, err := h.usageService.ResetBudget(c.Request.Context(), userID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, budget)
+}
@@ -0,0 +1,147 @@
+// Package health reports whether the server is ready for traffic: it
+// tracks startup and shutdown and runs a check per dependency.
+package health
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// States reported by readiness and by each check.
+const (
+	StatusOK           = "ok"
+	StatusFailing      = "failing"
+	StatusStarting     = "starting"
+	StatusShuttingDown = "shutting_down"
+)
+
+// Check reports whether a dependency is usable; a nil error means it is.
+type Check func(ctx context.Context) error
+
+// Info describes the state of a component without deciding readiness, such
+// as a circuit breaker that fails requests fast while other features keep
+// working.
+type Info func() any
+
+// Result is the outcome of one check.
+type Result struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Report is the readiness of the server. Checks are only run once startup
+// has completed and before shutdown begins.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+	Info   map[string]any    `json:"info,omitempty"`
+}
+
+func (r Report) Ready() bool {
+	return r.Status == StatusOK
+}
+
+type named struct {
+	name  string
+	check Check
+}
+
+type Health struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks []named
+	info   map[string]Info
+
+	started      atomic.Bool
+	shuttingDown atomic.Bool
+}
+
+// New returns a Health that gives each check up to timeout.
+func New(timeout time.Duration) *Health {
+	return &Health{timeout: timeout}
+}
+
+// Add registers a dependency check under name.
+func (h *Health) Add(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, named{name, check})
+}
+
+// AddInfo reports info under name alongside the checks.
+func (h *Health) AddInfo(name string, info Info) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.info == nil {
+		h.info = make(map[string]Info)
+	}
+	h.info[name] = info
+}
+
+// MarkStarted opens the readiness gate once startup work such as migrations
+// is done.
+func (h *Health) MarkStarted() {
+	h.started.Store(true)
+}
+
+// MarkShuttingDown makes readiness fail so load balancers stop routing new
+// requests while in-flight ones finish.
+func (h *Health) MarkShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Readiness runs every check concurrently and reports the combined state.
+func (h *Health) Readiness(ctx context.Context) Report {
+	switch {
+	case h.shuttingDown.Load():
+		return Report{Status: StatusShuttingDown}
+	case !h.started.Load():
+		return Report{Status: StatusStarting}
+	}
+
+	h.mu.RLock()
+	checks := append([]named(nil), h.checks...)
+	info := make(map[string]any, len(h.info))
+	for name, describe := range h.info {
+		info[name] = describe()
+	}
+	h.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = h.run(ctx, check)
+		}(i, c.check)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks)), Info: info}
+	for i, c := range checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFailing
+		}
+	}
+	return report
+}
+
+func (h *Health) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	started := time.Now()
+	err := check(ctx)
+	result := Result{Status: StatusOK, LatencyMs: time.Since(started).Milliseconds()}
+	if err != nil {
+		result.Status = StatusFailing
+		result.Error = err.Error()
+	}
+	return result
+}
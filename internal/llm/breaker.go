@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"sync"
+	"time"
+)
+
+// States of a circuit breaker.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// BreakerState describes a breaker for the readiness report.
+type BreakerState struct {
+	State    string    `json:"state"`
+	Failures int       `json:"failures"`
+	OpenedAt time.Time `json:"opened_at,omitempty"`
+}
+
+// Breaker stops calls to a provider after threshold transient failures in
+// a row. Once cooldown has passed, a single probe call is let through; its
+// outcome closes the breaker or opens it again. A threshold of zero never
+// opens it.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+	onChange  func(state string)
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewBreaker returns a closed breaker. onChange, if not nil, is called with
+// every new state.
+func NewBreaker(threshold int, cooldown time.Duration, onChange func(state string)) *Breaker {
+	b := &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		onChange:  onChange,
+		state:     BreakerClosed,
+	}
+	if onChange != nil {
+		onChange(BreakerClosed)
+	}
+	return b
+}
+
+// Allow reports whether a call may be made now. Every allowed call must be
+// followed by Success, Failure or Abandon.
+func (b *Breaker) Allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.setState(BreakerHalfOpen)
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Success records a call the provider answered, closing the breaker.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	b.setState(BreakerClosed)
+}
+
+// Failure records a transient failure of the provider.
+func (b *Breaker) Failure(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == BreakerHalfOpen || (b.threshold > 0 && b.failures >= b.threshold) {
+		b.openedAt = now
+		b.setState(BreakerOpen)
+	}
+}
+
+// Abandon records a call whose outcome says nothing about the provider,
+// such as one the client gave up on.
+func (b *Breaker) Abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := BreakerState{State: b.state, Failures: b.failures}
+	if b.state != BreakerClosed {
+		state.OpenedAt = b.openedAt
+	}
+	return state
+}
+
+func (b *Breaker) setState(state string) {
+	if b.state == state {
+		return
+	}
+	b.state = state
+	if b.onChange != nil {
+		b.onChange(state)
+	}
+}
@@ -0,0 +1,252 @@
+// Package llm generates replies through a chain of LLM providers and models.
+// Transient failures are retried with jittered backoff, each provider sits
+// behind a circuit breaker, and when a model keeps failing the next one in
+// the chain is tried.
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/tracing"
+	"github.com/subhammahanty235/medai/internal/utils"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrCircuitOpen is returned, wrapped with the provider's name, when the
+// last model of the chain was skipped because its provider's breaker is
+// open.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// Provider generates replies with the models of one LLM provider.
+type Provider interface {
+	Generate(ctx context.Context, model, systemPrompt, prompt string, imageURLs []string) (*utils.Completion, error)
+	// Retryable reports whether err is transient. Only transient errors
+	// are retried and count against the provider's breaker.
+	Retryable(err error) bool
+}
+
+// Target is a model of a provider.
+type Target struct {
+	Provider string
+	Model    string
+}
+
+func (t Target) String() string {
+	return t.Provider + ":" + t.Model
+}
+
+// ParseChain parses a comma-separated list of provider:model targets, in
+// the order they are tried, such as "gemini:gemini-pro,gemini:gemini-1.5-flash".
+func ParseChain(spec string) ([]Target, error) {
+	var chain []Target
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		provider, model, ok := strings.Cut(entry, ":")
+		if !ok || provider == "" || model == "" {
+			return nil, fmt.Errorf("invalid model %q, expected provider:model", entry)
+		}
+		chain = append(chain, Target{Provider: provider, Model: model})
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("no models configured")
+	}
+	return chain, nil
+}
+
+type Config struct {
+	// TextChain answers plain messages, VisionChain messages with images
+	TextChain   []Target
+	VisionChain []Target
+
+	// Each target is tried up to MaxRetries more times after a transient
+	// failure, waiting a jittered delay that doubles from RetryBaseDelay
+	// up to RetryMaxDelay
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+
+	// A provider's breaker opens after BreakerFailures transient failures
+	// in a row and lets a probe through after BreakerCooldown; zero
+	// failures disables it
+	BreakerFailures int
+	BreakerCooldown time.Duration
+
+	// OnBreakerChange, if not nil, is called with a provider and its new
+	// breaker state
+	OnBreakerChange func(provider, state string)
+}
+
+// Request is a reply to generate.
+type Request struct {
+	SystemPrompt string
+	Prompt       string
+	ImageURLs    []string
+}
+
+// Attempt is one call made to a provider. Tokens are those the provider
+// reported, also for failed calls.
+type Attempt struct {
+	Target
+	Latency          time.Duration
+	PromptTokens     int
+	CompletionTokens int
+	Err              error
+}
+
+// Reply is the outcome of Generate. Attempts lists every call made, in
+// order, whether or not a reply was generated.
+type Reply struct {
+	Completion *utils.Completion
+	Target     Target
+	Attempts   []Attempt
+}
+
+type Client struct {
+	providers map[string]Provider
+	breakers  map[string]*Breaker
+	cfg       Config
+}
+
+// NewClient returns a client calling providers, keyed by the names used in
+// the chains.
+func NewClient(cfg Config, providers map[string]Provider) (*Client, error) {
+	c := &Client{
+		providers: providers,
+		breakers:  make(map[string]*Breaker, len(providers)),
+		cfg:       cfg,
+	}
+
+	for _, target := range append(append([]Target(nil), cfg.TextChain...), cfg.VisionChain...) {
+		if _, ok := providers[target.Provider]; !ok {
+			return nil, fmt.Errorf("unknown LLM provider %q", target.Provider)
+		}
+	}
+	if len(cfg.TextChain) == 0 || len(cfg.VisionChain) == 0 {
+		return nil, errors.New("no models configured")
+	}
+
+	for name := range providers {
+		var onChange func(string)
+		if cfg.OnBreakerChange != nil {
+			onChange = func(state string) { cfg.OnBreakerChange(name, state) }
+		}
+		c.breakers[name] = NewBreaker(cfg.BreakerFailures, cfg.BreakerCooldown, onChange)
+	}
+	return c, nil
+}
+
+// Generate asks the models of the chain for a reply until one answers. It
+// stops early when ctx is done. The returned Reply is never nil, so that
+// the attempts can be accounted for on failure too.
+func (c *Client) Generate(ctx context.Context, req Request) (*Reply, error) {
+	ctx, span := tracing.Start(ctx, "llm.Generate")
+	defer span.End()
+
+	chain := c.cfg.TextChain
+	if len(req.ImageURLs) > 0 {
+		chain = c.cfg.VisionChain
+	}
+
+	reply := &Reply{}
+	var lastErr error
+targets:
+	for _, target := range chain {
+		provider, breaker := c.providers[target.Provider], c.breakers[target.Provider]
+
+		for try := 0; try <= c.cfg.MaxRetries; try++ {
+			if try > 0 {
+				if err := sleep(ctx, c.backoff(try)); err != nil {
+					return reply, errors.Join(err, lastErr)
+				}
+			}
+			if !breaker.Allow(time.Now()) {
+				lastErr = fmt.Errorf("%s: %w", target.Provider, ErrCircuitOpen)
+				break
+			}
+
+			started := time.Now()
+			completion, err := provider.Generate(ctx, target.Model, req.SystemPrompt, req.Prompt, req.ImageURLs)
+			attempt := Attempt{Target: target, Latency: time.Since(started), Err: err}
+			if completion != nil {
+				attempt.PromptTokens, attempt.CompletionTokens = completion.PromptTokens, completion.CompletionTokens
+			}
+			reply.Attempts = append(reply.Attempts, attempt)
+
+			switch {
+			case err == nil:
+				breaker.Success()
+				reply.Completion, reply.Target = completion, target
+				span.SetAttributes(
+					attribute.String("llm.target", target.String()),
+					attribute.Int("llm.attempts", len(reply.Attempts)),
+				)
+				return reply, nil
+
+			case ctx.Err() != nil:
+				// The caller gave up; that says nothing about the provider
+				breaker.Abandon()
+				return reply, err
+
+			case !provider.Retryable(err):
+				// The provider answered, but another model may still do
+				// better than this one
+				breaker.Success()
+				lastErr = err
+				continue targets
+
+			default:
+				breaker.Failure(time.Now())
+				lastErr = err
+			}
+		}
+	}
+
+	span.SetAttributes(attribute.Int("llm.attempts", len(reply.Attempts)))
+	tracing.RecordError(span, lastErr)
+	return reply, lastErr
+}
+
+// Breakers returns the state of every provider's breaker.
+func (c *Client) Breakers() map[string]BreakerState {
+	states := make(map[string]BreakerState, len(c.breakers))
+	for name, breaker := range c.breakers {
+		states[name] = breaker.State()
+	}
+	return states
+}
+
+// backoff is the delay before retry number try (1 for the first retry):
+// a random duration between half of and the full doubled delay.
+func (c *Client) backoff(try int) time.Duration {
+	delay := c.cfg.RetryBaseDelay
+	for i := 1; i < try && delay < c.cfg.RetryMaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, c.cfg.RetryMaxDelay)
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
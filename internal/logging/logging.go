@@ -0,0 +1,165 @@
+// Package logging builds the structured JSON logger of the application.
+// Records are redacted before they are written, so message content, email
+// addresses and passwords never reach the logs, and they carry the
+// attributes stored in the context of the request that produced them.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Redacted replaces the values that may not be logged.
+const Redacted = "[REDACTED]"
+
+// New returns a logger writing JSON records at level and above to w.
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	})
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+// ParseLevel parses a level name such as "debug" or "warn".
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q", name)
+	}
+	return level, nil
+}
+
+type attrsKey struct{}
+
+// WithAttrs returns a context whose log records carry attrs in addition to
+// those already attached to ctx.
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	combined := make([]slog.Attr, 0, len(existing)+len(attrs))
+	combined = append(combined, existing...)
+	combined = append(combined, attrs...)
+	return context.WithValue(ctx, attrsKey{}, combined)
+}
+
+// contextHandler adds the attributes attached to the context with WithAttrs,
+// and the trace the record belongs to, to every record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		record.AddAttrs(attrs...)
+	}
+
+	if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+		record.AddAttrs(
+			slog.String("trace_id", spanContext.TraceID().String()),
+			slog.String("span_id", spanContext.SpanID().String()),
+		)
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+// redactedKeys name attributes whose values are never logged, whatever
+// they contain.
+var redactedKeys = map[string]bool{
+	"content":       true,
+	"message":       true,
+	"text":          true,
+	"prompt":        true,
+	"reply":         true,
+	"response":      true,
+	"body":          true,
+	"authorization": true,
+	"token":         true,
+	"code":          true,
+	"recovery_code": true,
+	"secret":        true,
+}
+
+// redact replaces the values of sensitive attributes and scrubs email
+// addresses from everything else, including the message and errors.
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+	if len(groups) == 0 && (key == slog.TimeKey || key == slog.LevelKey) {
+		return attr
+	}
+	if redactedKeys[key] || strings.Contains(key, "password") || strings.Contains(key, "email") {
+		return slog.String(attr.Key, Redacted)
+	}
+
+	switch attr.Value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, ScrubEmails(attr.Value.String()))
+	case slog.KindAny:
+		// Errors and arbitrary values are flattened to text so that email
+		// addresses inside them can be scrubbed
+		if err, ok := attr.Value.Any().(error); ok {
+			return slog.String(attr.Key, ScrubEmails(err.Error()))
+		}
+		return slog.String(attr.Key, ScrubEmails(fmt.Sprint(attr.Value.Any())))
+	}
+	return attr
+}
+
+// ScrubEmails replaces every email address in s with Redacted. Anything of
+// the form local@domain.tld counts as an address.
+func ScrubEmails(s string) string {
+	at := strings.IndexByte(s, '@')
+	if at < 0 {
+		return s
+	}
+
+	var out strings.Builder
+	for at >= 0 {
+		start := at
+		for start > 0 && isLocalPartByte(s[start-1]) {
+			start--
+		}
+		end := at + 1
+		for end < len(s) && isDomainByte(s[end]) {
+			end++
+		}
+		// Trailing dots end the sentence rather than the domain
+		for end > at+1 && s[end-1] == '.' {
+			end--
+		}
+
+		domain := s[at+1 : end]
+		if start < at && strings.Contains(domain, ".") {
+			out.WriteString(s[:start])
+			out.WriteString(Redacted)
+		} else {
+			out.WriteString(s[:end])
+		}
+
+		s = s[end:]
+		at = strings.IndexByte(s, '@')
+	}
+	out.WriteString(s)
+	return out.String()
+}
+
+func isLocalPartByte(b byte) bool {
+	return isDomainByte(b) || strings.IndexByte("!#$%&'*+/=?^_`{|}~", b) >= 0
+}
+
+func isDomainByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '.' || b == '-'
+}
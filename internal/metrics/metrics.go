@@ -0,0 +1,170 @@
+// Package metrics defines the Prometheus metrics of the server. Labels are
+// limited to values chosen by the server, such as route templates, doctor
+// personas and model names, so that no patient data ends up in them.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/subhammahanty235/medai/internal/utils"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "medai_http_requests_total",
+		Help: "HTTP requests by route template, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "medai_http_request_duration_seconds",
+		Help:    "HTTP request duration by route template and method.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"route", "method"})
+
+	llmDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "medai_llm_request_duration_seconds",
+		Help:    "LLM call duration by doctor persona, model and outcome.",
+		Buckets: []float64{.25, .5, 1, 2, 4, 8, 15, 30, 60, 120},
+	}, []string{"persona", "model", "outcome"})
+
+	llmTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "medai_llm_tokens_total",
+		Help: "LLM tokens used by doctor persona, model and direction (prompt or completion).",
+	}, []string{"persona", "model", "direction"})
+
+	llmErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "medai_llm_errors_total",
+		Help: "Failed LLM calls by doctor persona, model and reason.",
+	}, []string{"persona", "model", "reason"})
+
+	llmCircuitState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "medai_llm_circuit_state",
+		Help: "State of each LLM provider's circuit breaker; 1 for the current state, 0 for the others.",
+	}, []string{"provider", "state"})
+
+	triageEscalations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "medai_triage_escalations_total",
+		Help: "Replies that recommended seeing a real doctor, by doctor persona.",
+	}, []string{"persona"})
+
+	uploads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "medai_uploads_total",
+		Help: "Uploaded chat files by content type and outcome.",
+	}, []string{"content_type", "outcome"})
+
+	uploadBytes = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "medai_upload_size_bytes",
+		Help:    "Size of accepted chat files by content type.",
+		Buckets: prometheus.ExponentialBuckets(16<<10, 4, 8),
+	}, []string{"content_type"})
+
+	rateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "medai_rate_limited_total",
+		Help: "Refused requests by route group or quota and the limit that refused them (ip, user or quota).",
+	}, []string{"group", "scope"})
+
+	appointmentTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "medai_appointment_transitions_total",
+		Help: "Appointment status changes; bookings count as from \"none\".",
+	}, []string{"from", "to"})
+)
+
+// ObserveHTTPRequest records a served request. route must be the route
+// template, never the raw path, which contains IDs.
+func ObserveHTTPRequest(route, method string, status int, duration time.Duration) {
+	httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	httpDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+}
+
+// ObserveLLMCall records an LLM call by the AI doctor persona. Token counts
+// are zero when the API did not report them.
+func ObserveLLMCall(persona, model string, duration time.Duration, promptTokens, completionTokens int, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+		llmErrors.WithLabelValues(persona, model, llmErrorReason(err)).Inc()
+	}
+	llmDuration.WithLabelValues(persona, model, outcome).Observe(duration.Seconds())
+
+	if promptTokens > 0 {
+		llmTokens.WithLabelValues(persona, model, "prompt").Add(float64(promptTokens))
+	}
+	if completionTokens > 0 {
+		llmTokens.WithLabelValues(persona, model, "completion").Add(float64(completionTokens))
+	}
+}
+
+// llmErrorReason maps an error to a fixed set of reasons; error messages
+// may quote the prompt.
+func llmErrorReason(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, utils.ErrNoResponse), errors.Is(err, utils.ErrUnexpectedResponse):
+		return "empty_response"
+	default:
+		return "error"
+	}
+}
+
+// SetLLMCircuitState records the state a provider's circuit breaker moved
+// to: closed, open or half_open.
+func SetLLMCircuitState(provider, state string) {
+	for _, known := range []string{"closed", "open", "half_open"} {
+		value := 0.0
+		if known == state {
+			value = 1
+		}
+		llmCircuitState.WithLabelValues(provider, known).Set(value)
+	}
+}
+
+func ObserveTriageEscalation(persona string) {
+	triageEscalations.WithLabelValues(persona).Inc()
+}
+
+// ObserveUpload records a processed upload. contentType is the type
+// detected from the content, or empty for rejected files.
+func ObserveUpload(contentType, outcome string, size int64) {
+	if contentType == "" {
+		contentType = "unknown"
+	}
+	uploads.WithLabelValues(contentType, outcome).Inc()
+	if outcome == "accepted" {
+		uploadBytes.WithLabelValues(contentType).Observe(float64(size))
+	}
+}
+
+// ObserveRateLimited records a request refused by a rate limit or quota.
+func ObserveRateLimited(group, scope string) {
+	rateLimited.WithLabelValues(group, scope).Inc()
+}
+
+// ObserveAppointmentTransition records an appointment moving from one status
+// to another; from is empty for new bookings.
+func ObserveAppointmentTransition(from, to string) {
+	if from == "" {
+		from = "none"
+	} else {
+		from = appointmentStatusLabel(from)
+	}
+	appointmentTransitions.WithLabelValues(from, appointmentStatusLabel(to)).Inc()
+}
+
+// appointmentStatusLabel keeps statuses stored before they were validated
+// from becoming labels of their own.
+func appointmentStatusLabel(status string) string {
+	switch status {
+	case "pending", "confirmed", "completed", "cancelled":
+		return status
+	default:
+		return "other"
+	}
+}
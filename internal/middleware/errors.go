@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/repository"
+	"github.com/subhammahanty235/medai/internal/service"
+	"github.com/subhammahanty235/medai/internal/storage"
+	"github.com/subhammahanty235/medai/internal/upload"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// statusClientClosedRequest is nginx's status for requests the client gave
+// up on; nobody reads the answer, it only keeps them apart in logs and
+// metrics.
+const statusClientClosedRequest = 499
+
+// ErrorMiddleware renders the error a handler attached with c.Error as a
+// models.ErrorResponse. Domain errors keep their message and code; anything
+// unexpected becomes a 500 whose cause is logged rather than shown, so
+// driver and SDK messages never reach clients.
+func ErrorMiddleware(logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+		err := c.Errors.Last().Err
+
+		status, response := errorResponse(err)
+		response.RequestID = c.GetString("requestID")
+
+		ctx := c.Request.Context()
+		switch {
+		case status >= http.StatusInternalServerError && status != http.StatusGatewayTimeout:
+			logger.ErrorContext(ctx, "Request failed", "error_code", response.Code, "error", err)
+		case status == http.StatusGatewayTimeout:
+			logger.WarnContext(ctx, "Request timed out", "error", err)
+		}
+
+		if wait := retryAfter(err); wait > 0 {
+			setRetryAfter(c, wait)
+		}
+
+		c.JSON(status, response)
+	}
+}
+
+// abortWithError stops the chain and leaves err for ErrorMiddleware.
+func abortWithError(c *gin.Context, err error) {
+	c.Error(err)
+	c.Abort()
+}
+
+// retryAfter is how long a throttled client has to wait, or zero.
+func retryAfter(err error) time.Duration {
+	var (
+		domainErr *service.Error
+		throttled *service.LoginThrottledError
+	)
+	switch {
+	case errors.As(err, &throttled):
+		return throttled.RetryAfter
+	case errors.As(err, &domainErr):
+		return domainErr.RetryAfter
+	}
+	return 0
+}
+
+// setRetryAfter sets the Retry-After header in whole seconds, rounded up.
+func setRetryAfter(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+}
+
+// errorResponse maps err to a status and the body to answer with.
+func errorResponse(err error) (int, models.ErrorResponse) {
+	var (
+		domainErr *service.Error
+		throttled *service.LoginThrottledError
+		uploadErr *upload.Error
+	)
+
+	switch {
+	// Checked first: a timed-out upstream call is a timeout whatever else
+	// it is. The database also reports server-side time limits as timeouts
+	case errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err):
+		return http.StatusGatewayTimeout, models.ErrorResponse{Code: "timeout", Error: "The request timed out, please try again"}
+
+	case errors.Is(err, context.Canceled):
+		return statusClientClosedRequest, models.ErrorResponse{Code: "canceled", Error: "The request was canceled"}
+
+	case errors.As(err, &throttled):
+		return http.StatusTooManyRequests, models.ErrorResponse{Code: "too_many_attempts", Error: throttled.Error()}
+
+	case errors.As(err, &domainErr):
+		return kindStatus(domainErr.Kind), models.ErrorResponse{
+			Code:    domainErr.Code,
+			Error:   domainErr.Message,
+			Details: domainErr.Fields,
+		}
+
+	case errors.As(err, &uploadErr):
+		return uploadErr.Status, models.ErrorResponse{Code: uploadErr.Code, Error: uploadErr.Message}
+
+	// Lower-level errors that services did not translate
+	case errors.Is(err, repository.ErrNotFound), errors.Is(err, storage.ErrNotFound), errors.Is(err, mongo.ErrNoDocuments):
+		return http.StatusNotFound, models.ErrorResponse{Code: "not_found", Error: "Not found"}
+	case errors.Is(err, repository.ErrDuplicate):
+		return http.StatusConflict, models.ErrorResponse{Code: "conflict", Error: "Already exists"}
+
+	default:
+		return http.StatusInternalServerError, models.ErrorResponse{Code: "internal_error", Error: "Internal server error"}
+	}
+}
+
+func kindStatus(kind error) int {
+	switch kind {
+	case service.ErrNotFound:
+		return http.StatusNotFound
+	case service.ErrConflict:
+		return http.StatusConflict
+	case service.ErrForbidden:
+		return http.StatusForbidden
+	case service.ErrUnauthorized:
+		return http.StatusUnauthorized
+	case service.ErrValidation:
+		return http.StatusBadRequest
+	case service.ErrUnavailable:
+		return http.StatusServiceUnavailable
+	case service.ErrRateLimited:
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusInternalServerError
+	}
+}
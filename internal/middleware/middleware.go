@@ -1,43 +1,96 @@
 package middleware
 
 import (
-	"net/http"
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
 	"strings"
 
+	"github.com/subhammahanty235/medai/internal/logging"
+	"github.com/subhammahanty235/medai/internal/repository"
+	"github.com/subhammahanty235/medai/internal/service"
 	"github.com/subhammahanty235/medai/internal/utils"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func AuthMiddleware(jwtSecret string) gin.HandlerFunc {
+var (
+	errMissingAuthorization = service.Unauthorized("missing_authorization", "Authorization header required")
+	errInvalidAuthorization = service.Unauthorized("invalid_authorization", "Invalid authorization header format")
+	errInvalidToken         = service.Unauthorized("invalid_token", "Invalid token")
+	errRoleNotAllowed       = service.Forbidden("role_not_allowed", "You are not allowed to do this")
+)
+
+// TokenVersionLookup returns the current token version of a user, or an
+// error if the user no longer exists.
+type TokenVersionLookup func(ctx context.Context, userID primitive.ObjectID) (int, error)
+
+func AuthMiddleware(jwtSecret string, tokenVersion TokenVersionLookup) gin.HandlerFunc {
+	return authMiddleware(jwtSecret, tokenVersion, utils.TokenPurposeAccess)
+}
+
+// MFAEnrollmentAuthMiddleware also accepts the restricted token issued to
+// users whose role requires two-factor enrollment before full access.
+func MFAEnrollmentAuthMiddleware(jwtSecret string, tokenVersion TokenVersionLookup) gin.HandlerFunc {
+	return authMiddleware(jwtSecret, tokenVersion, utils.TokenPurposeAccess, utils.TokenPurposeMFAEnrollment)
+}
+
+func authMiddleware(jwtSecret string, tokenVersion TokenVersionLookup, allowedPurposes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
-			c.Abort()
+			abortWithError(c, errMissingAuthorization)
 			return
 		}
 
 		// Extract token from "Bearer <token>"
 		tokenParts := strings.Split(authHeader, " ")
 		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
-			c.Abort()
+			abortWithError(c, errInvalidAuthorization)
 			return
 		}
 
 		token := tokenParts[1]
 		claims, err := utils.ValidateToken(token, jwtSecret)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			c.Abort()
+		if err != nil || !slices.Contains(allowedPurposes, claims.Purpose) {
+			abortWithError(c, errInvalidToken)
+			return
+		}
+
+		// Reject tokens revoked by a password change or account deletion. A
+		// failed lookup is not the token's fault and is answered as such
+		currentVersion, err := tokenVersion(c.Request.Context(), claims.UserID)
+		if err != nil && !errors.Is(err, repository.ErrNotFound) {
+			abortWithError(c, err)
+			return
+		}
+		if err != nil || currentVersion != claims.TokenVersion {
+			abortWithError(c, errInvalidToken)
 			return
 		}
 
 		// Set user ID in context
 		c.Set("userID", claims.UserID)
 		c.Set("userEmail", claims.Email)
+		c.Set("userRole", claims.Role)
+
+		// Attribute the rest of the request's log records to the user
+		ctx := logging.WithAttrs(c.Request.Context(), slog.String("user_id", claims.UserID.Hex()))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// RequireRole lets through users with one of roles. It must run behind an
+// auth middleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !slices.Contains(roles, GetUserRole(c)) {
+			abortWithError(c, errRoleNotAllowed)
+			return
+		}
 		c.Next()
 	}
 }
@@ -48,6 +101,7 @@ func CORSMiddleware() gin.HandlerFunc {
 		c.Header("Access-Control-Allow-Credentials", "true")
 		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
 		c.Header("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Header("Access-Control-Expose-Headers", "Retry-After, "+RequestIDHeader+", "+RateLimitLimitHeader+", "+RateLimitRemainingHeader+", "+QuotaLimitHeader+", "+QuotaRemainingHeader+", "+QuotaResetHeader)
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -67,3 +121,7 @@ func GetUserID(c *gin.Context) (primitive.ObjectID, bool) {
 	objectID, ok := userID.(primitive.ObjectID)
 	return objectID, ok
 }
+
+func GetUserRole(c *gin.Context) string {
+	return c.GetString("userRole")
+}
@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/subhammahanty235/medai/internal/metrics"
+	"github.com/subhammahanty235/medai/internal/ratelimit"
+	"github.com/subhammahanty235/medai/internal/service"
+)
+
+// Headers telling clients how much of their rate limit and daily quota is
+// left. X-Quota-Reset is the Unix time at which the quota starts over.
+const (
+	RateLimitLimitHeader     = "X-RateLimit-Limit"
+	RateLimitRemainingHeader = "X-RateLimit-Remaining"
+	QuotaLimitHeader         = "X-Quota-Limit"
+	QuotaRemainingHeader     = "X-Quota-Remaining"
+	QuotaResetHeader         = "X-Quota-Reset"
+)
+
+const rateLimitedMessage = "Too many requests, please slow down"
+
+// limitCheck is the outcome of one limit of a RateLimitMiddleware.
+type limitCheck struct {
+	scope  string
+	result ratelimit.Result
+}
+
+// RateLimitMiddleware limits the requests to a route group per client IP
+// and, behind an auth middleware, per user according to their role. group
+// keeps the buckets of route groups apart, so that a busy group does not
+// use up the budget of another.
+func RateLimitMiddleware(limiter *ratelimit.Limiter, group string, policy ratelimit.Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		now := time.Now()
+
+		checks := []limitCheck{
+			{scope: "ip", result: limiter.Allow(group+":ip:"+c.ClientIP(), policy.IP, now)},
+		}
+		if userID, ok := GetUserID(c); ok {
+			rate := policy.UserRate(GetUserRole(c))
+			checks = append(checks, limitCheck{scope: "user", result: limiter.Allow(group+":user:"+userID.Hex(), rate, now)})
+		}
+
+		// The headers describe whichever limit is closest to refusing
+		var tightest *ratelimit.Result
+		for i := range checks {
+			result := &checks[i].result
+			if !result.Allowed {
+				metrics.ObserveRateLimited(group, checks[i].scope)
+				c.Header(RateLimitLimitHeader, strconv.Itoa(result.Limit))
+				c.Header(RateLimitRemainingHeader, "0")
+				abortWithError(c, service.RateLimited("rate_limited", rateLimitedMessage, result.RetryAfter))
+				return
+			}
+			if result.Limit > 0 && (tightest == nil || result.Remaining < tightest.Remaining) {
+				tightest = result
+			}
+		}
+		if tightest != nil {
+			c.Header(RateLimitLimitHeader, strconv.Itoa(tightest.Limit))
+			c.Header(RateLimitRemainingHeader, strconv.Itoa(tightest.Remaining))
+		}
+
+		c.Next()
+	}
+}
+
+// QuotaMiddleware counts a request against the user's daily quota of kind,
+// one of the service.Quota constants. Requests that fail are refunded, so
+// that errors on our side or invalid input do not use up the quota. It must
+// run behind an auth middleware.
+func QuotaMiddleware(quotas *service.QuotaService, kind string, logger *slog.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := GetUserID(c)
+		if !ok {
+			abortWithError(c, errMissingAuthorization)
+			return
+		}
+
+		ctx := c.Request.Context()
+		usage, err := quotas.Consume(ctx, userID, GetUserRole(c), kind)
+		if usage != nil {
+			setQuotaHeaders(c, usage, usage.Remaining)
+		}
+		if err != nil {
+			if usage != nil {
+				metrics.ObserveRateLimited(kind, "quota")
+			}
+			abortWithError(c, err)
+			return
+		}
+
+		c.Next()
+
+		if usage == nil || (len(c.Errors) == 0 && c.Writer.Status() < http.StatusBadRequest) {
+			return
+		}
+		if err := quotas.Refund(ctx, usage); err != nil {
+			logger.ErrorContext(ctx, "Error refunding quota of failed request", "quota", kind, "error", err)
+			return
+		}
+		// Errors are rendered after this returns, so the headers can
+		// still be corrected
+		if !c.Writer.Written() {
+			setQuotaHeaders(c, usage, usage.Remaining+1)
+		}
+	}
+}
+
+func setQuotaHeaders(c *gin.Context, usage *service.QuotaUsage, remaining int) {
+	c.Header(QuotaLimitHeader, strconv.Itoa(usage.Limit))
+	c.Header(QuotaRemainingHeader, strconv.Itoa(remaining))
+	c.Header(QuotaResetHeader, strconv.FormatInt(usage.ResetAt.Unix(), 10))
+}
@@ -0,0 +1,168 @@
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/storage"
+	"github.com/subhammahanty235/medai/internal/upload"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// legacyMessage holds the per-message file fields used before attachments.
+type legacyMessage struct {
+	ImageKey      string                `bson:"image_key"`
+	ImageStatus   string                `bson:"image_status"`
+	ImageVariants []models.ImageVariant `bson:"image_variants"`
+	Document      *struct {
+		Key         string                `bson:"key"`
+		FileName    string                `bson:"file_name"`
+		ContentType string                `bson:"content_type"`
+		Size        int64                 `bson:"size"`
+		PageCount   int                   `bson:"page_count"`
+		HasText     bool                  `bson:"has_text"`
+		Pages       []models.DocumentPage `bson:"pages"`
+	} `bson:"document"`
+}
+
+// migrateMessageAttachments moves the image and document fields of messages
+// into their attachments list, computing checksums from the stored objects
+// so that later uploads of the same file are deduplicated. It must run after
+// migrateImageURLsToKeys. Migrated messages no longer have the old fields, so
+// running it again is a no-op.
+func migrateMessageAttachments(ctx context.Context, env Env) error {
+	collection := env.DB.GetCollection("chat_sessions")
+	blobStorage := env.Storage
+
+	cursor, err := collection.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"messages.image_key": bson.M{"$exists": true}},
+		bson.M{"messages.document": bson.M{"$exists": true}},
+	}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	migrated := 0
+
+	for cursor.Next(ctx) {
+		var session struct {
+			ID       primitive.ObjectID `bson:"_id"`
+			Messages []legacyMessage    `bson:"messages"`
+		}
+		if err := cursor.Decode(&session); err != nil {
+			return err
+		}
+
+		set := bson.M{}
+		unset := bson.M{}
+		for i, message := range session.Messages {
+			var attachments []models.Attachment
+
+			if message.ImageKey != "" {
+				attachment := models.Attachment{
+					ID:       primitive.NewObjectID(),
+					Kind:     models.AttachmentKindImage,
+					Key:      message.ImageKey,
+					Status:   message.ImageStatus,
+					Variants: message.ImageVariants,
+				}
+				if attachment.Status == "" {
+					// Uploaded before variants existed; generate them now
+					attachment.Status = models.AttachmentStatusPending
+				}
+				describeStoredObject(ctx, env.Logger, blobStorage, &attachment)
+				attachments = append(attachments, attachment)
+			}
+
+			if document := message.Document; document != nil {
+				attachment := models.Attachment{
+					ID:          primitive.NewObjectID(),
+					Kind:        models.AttachmentKindDocument,
+					Key:         document.Key,
+					FileName:    document.FileName,
+					ContentType: document.ContentType,
+					Size:        document.Size,
+					Status:      models.AttachmentStatusReady,
+					PageCount:   document.PageCount,
+					HasText:     document.HasText,
+					Pages:       document.Pages,
+				}
+				describeStoredObject(ctx, env.Logger, blobStorage, &attachment)
+				attachments = append(attachments, attachment)
+			}
+
+			if len(attachments) == 0 {
+				continue
+			}
+
+			prefix := fmt.Sprintf("messages.%d.", i)
+			set[prefix+"attachments"] = attachments
+			for _, field := range []string{"image_key", "image_status", "image_variants", "document"} {
+				unset[prefix+field] = ""
+			}
+		}
+
+		if len(set) == 0 {
+			continue
+		}
+
+		_, err := collection.UpdateOne(
+			ctx,
+			bson.M{"_id": session.ID},
+			bson.M{"$set": set, "$unset": unset},
+		)
+		if err != nil {
+			return err
+		}
+		migrated += len(set)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	if migrated > 0 {
+		env.Logger.InfoContext(ctx, "Migrated files of messages to attachments", "messages", migrated)
+	}
+	return nil
+}
+
+// describeStoredObject fills in the checksum, size, content type and image
+// dimensions of an attachment from its stored object. Objects that cannot be
+// read are left undescribed rather than failing the migration.
+func describeStoredObject(ctx context.Context, logger *slog.Logger, blobStorage storage.Storage, attachment *models.Attachment) {
+	body, info, err := blobStorage.Get(ctx, attachment.Key)
+	if err != nil {
+		logger.ErrorContext(ctx, "Error reading file while migrating attachments", "key", attachment.Key, "error", err)
+		return
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		logger.ErrorContext(ctx, "Error reading file while migrating attachments", "key", attachment.Key, "error", err)
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	attachment.Checksum = hex.EncodeToString(sum[:])
+	attachment.Size = int64(len(data))
+
+	if attachment.ContentType == "" {
+		attachment.ContentType = upload.Sniff(data)
+		if attachment.ContentType == "" {
+			attachment.ContentType = info.ContentType
+		}
+	}
+	if attachment.Kind == models.AttachmentKindImage {
+		attachment.Width, attachment.Height = upload.ImageDimensions(data)
+	}
+}
@@ -0,0 +1,89 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/subhammahanty235/medai/internal/storage"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// migrateImageURLsToKeys converts messages that still store a public image
+// URL into storage keys and makes the referenced objects private. It only
+// touches messages that have an image_url, so running it again is a no-op
+// once it succeeded, and picks up where it stopped after a failure.
+func migrateImageURLsToKeys(ctx context.Context, env Env) error {
+	collection := env.DB.GetCollection("chat_sessions")
+	blobStorage := env.Storage
+
+	cursor, err := collection.Find(ctx, bson.M{"messages.image_url": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	privatizer, _ := blobStorage.(storage.Privatizer)
+	migrated := 0
+
+	for cursor.Next(ctx) {
+		var session struct {
+			ID       primitive.ObjectID `bson:"_id"`
+			Messages []struct {
+				ImageURL string `bson:"image_url"`
+			} `bson:"messages"`
+		}
+		if err := cursor.Decode(&session); err != nil {
+			return err
+		}
+
+		set := bson.M{}
+		unset := bson.M{}
+		for i, message := range session.Messages {
+			if message.ImageURL == "" {
+				continue
+			}
+
+			key, ok := blobStorage.KeyFromURL(message.ImageURL)
+			if !ok {
+				env.Logger.WarnContext(ctx, "Leaving image URL not in configured storage", "session_id", session.ID.Hex(), "message_index", i)
+				continue
+			}
+
+			// Failing leaves the migration unapplied, with the image URL of
+			// this message in place for the next run to find it again
+			if privatizer != nil {
+				if err := privatizer.MakePrivate(ctx, key); err != nil {
+					return fmt.Errorf("making image %s private: %w", key, err)
+				}
+			}
+
+			set[fmt.Sprintf("messages.%d.image_key", i)] = key
+			unset[fmt.Sprintf("messages.%d.image_url", i)] = ""
+		}
+
+		if len(set) == 0 {
+			continue
+		}
+
+		_, err := collection.UpdateOne(
+			ctx,
+			bson.M{"_id": session.ID},
+			bson.M{"$set": set, "$unset": unset},
+		)
+		if err != nil {
+			return err
+		}
+		migrated += len(set)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	if migrated > 0 {
+		env.Logger.InfoContext(ctx, "Migrated message images from public URLs to storage keys", "images", migrated)
+	}
+	return nil
+}
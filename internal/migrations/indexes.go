@@ -0,0 +1,252 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// indexSet lists indexes to create, by collection. Each migration that
+// creates indexes has a set of its own that never changes once released, so
+// a version always creates what it did when it was first applied; new
+// indexes go in a new set and migration.
+type indexSet map[string][]mongo.IndexModel
+
+// initialIndexes are created by migration 5.
+var initialIndexes = indexSet{
+	"users": {
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetName("email_unique").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}},
+			Options: options.Index().SetName("identities"),
+		},
+		{
+			Keys: bson.D{{Key: "email_change_token_hash", Value: 1}},
+			Options: options.Index().SetName("email_change_token_hash").
+				SetPartialFilterExpression(bson.M{"email_change_token_hash": bson.M{"$exists": true}}),
+		},
+	},
+	"chat_sessions": {
+		{
+			// One active session per user, doctor and patient. Sessions about
+			// the user themselves have no dependent_id, which is indexed as null.
+			Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "doctor_id", Value: 1}, {Key: "dependent_id", Value: 1}},
+			Options: options.Index().SetName("one_active_session").SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": "active"}),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "updated_at", Value: -1}},
+			Options: options.Index().SetName("user_sessions"),
+		},
+		{
+			// Dropped by migration 6 along with the embedded messages
+			Keys: bson.D{{Key: "messages.attachments.status", Value: 1}},
+			Options: options.Index().SetName("pending_attachments").
+				SetPartialFilterExpression(bson.M{"messages.attachments.status": "pending"}),
+		},
+	},
+	"appointments": {
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}},
+			Options: options.Index().SetName("user_appointments"),
+		},
+	},
+	"real_doctors": {
+		{
+			Keys:    bson.D{{Key: "specialty", Value: 1}},
+			Options: options.Index().SetName("specialty"),
+		},
+	},
+	"health_profiles": {
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}},
+			Options: options.Index().SetName("user_profile"),
+		},
+	},
+	"oidc_login_states": {
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetName("expiry").SetExpireAfterSeconds(0),
+		},
+	},
+	"audit_events": {
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("user_events"),
+		},
+	},
+}
+
+// messageIndexes are created by migration 6.
+var messageIndexes = indexSet{
+	"messages": {
+		{
+			Keys:    bson.D{{Key: "session_id", Value: 1}, {Key: "seq", Value: 1}},
+			Options: options.Index().SetName("session_seq").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "session_id", Value: 1}, {Key: "attachments.checksum", Value: 1}},
+			Options: options.Index().SetName("session_attachments"),
+		},
+		{
+			Keys: bson.D{{Key: "attachments.status", Value: 1}},
+			Options: options.Index().SetName("pending_attachments").
+				SetPartialFilterExpression(bson.M{"attachments.status": "pending"}),
+		},
+	},
+}
+
+// dailyUsageIndexes are created by migration 7.
+var dailyUsageIndexes = indexSet{
+	"daily_usage": {
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetName("expiry").SetExpireAfterSeconds(0),
+		},
+	},
+}
+
+// llmUsageIndexes are created by migration 8.
+var llmUsageIndexes = indexSet{
+	"llm_usage": {
+		{
+			Keys:    bson.D{{Key: "created_at", Value: 1}},
+			Options: options.Index().SetName("created"),
+		},
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: 1}},
+			Options: options.Index().SetName("user_usage"),
+		},
+	},
+}
+
+// pendingReplyIndexes are created by migration 9.
+var pendingReplyIndexes = indexSet{
+	"messages": {
+		{
+			Keys: bson.D{{Key: "session_id", Value: 1}, {Key: "seq", Value: -1}},
+			Options: options.Index().SetName("pending_replies").
+				SetPartialFilterExpression(bson.M{"reply_status": "pending"}),
+		},
+	},
+}
+
+// loginAttemptIndexes are created by migration 10.
+var loginAttemptIndexes = indexSet{
+	"login_attempts": {
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetName("expiry").SetExpireAfterSeconds(0),
+		},
+	},
+}
+
+// create creates the indexes of the set. Creating an index that already
+// exists with the same options is a no-op, so running it again is safe.
+func (set indexSet) create(ctx context.Context, env Env) error {
+	for collection, models := range set {
+		if _, err := env.DB.GetCollection(collection).Indexes().CreateMany(ctx, models); err != nil {
+			return fmt.Errorf("creating indexes on %s: %w", collection, err)
+		}
+	}
+	return nil
+}
+
+// closeDuplicateActiveSessions keeps the most recently updated active
+// session of each user, doctor and patient and closes the others, which the
+// one_active_session index would otherwise reject.
+func closeDuplicateActiveSessions(ctx context.Context, env Env) error {
+	collection := env.DB.GetCollection("chat_sessions")
+
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"status": "active"}}},
+		{{Key: "$sort", Value: bson.M{"updated_at": -1}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"user_id": "$user_id", "doctor_id": "$doctor_id", "dependent_id": "$dependent_id"},
+			"ids":   bson.M{"$push": "$_id"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var groups []struct {
+		IDs []interface{} `bson:"ids"`
+	}
+	if err := cursor.All(ctx, &groups); err != nil {
+		return err
+	}
+
+	closed := 0
+	for _, group := range groups {
+		result, err := collection.UpdateMany(ctx,
+			bson.M{"_id": bson.M{"$in": group.IDs[1:]}},
+			bson.M{"$set": bson.M{"status": "closed"}},
+		)
+		if err != nil {
+			return err
+		}
+		closed += int(result.ModifiedCount)
+	}
+
+	if closed > 0 {
+		env.Logger.InfoContext(ctx, "Closed duplicate active chat sessions", "sessions", closed)
+	}
+	return nil
+}
+
+// checkDuplicateEmails fails if addresses are shared by several accounts,
+// which have to be merged or changed by hand before email can be unique.
+func checkDuplicateEmails(ctx context.Context, env Env) error {
+	cursor, err := env.DB.GetCollection("users").Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{"_id": "$email", "count": bson.M{"$sum": 1}}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var duplicates []bson.M
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return err
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("%d email addresses are used by more than one account", len(duplicates))
+	}
+	return nil
+}
+
+func addIndexes(ctx context.Context, env Env) error {
+	if err := checkDuplicateEmails(ctx, env); err != nil {
+		return err
+	}
+	if err := closeDuplicateActiveSessions(ctx, env); err != nil {
+		return err
+	}
+	return initialIndexes.create(ctx, env)
+}
+
+// expireLoginAttempts gives the failed logins recorded before they expired
+// a day to live from now, then indexes their expiry.
+func expireLoginAttempts(ctx context.Context, env Env) error {
+	_, err := env.DB.GetCollection("login_attempts").UpdateMany(
+		ctx,
+		bson.M{"expires_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"expires_at": time.Now().Add(24 * time.Hour)}},
+	)
+	if err != nil {
+		return err
+	}
+	return loginAttemptIndexes.create(ctx, env)
+}
@@ -0,0 +1,145 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/subhammahanty235/medai/internal/db"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	lockID = "migrations"
+	// lockTTL bounds how long a crashed replica blocks the others; a live
+	// holder keeps extending it
+	lockTTL          = time.Minute
+	lockPollInterval = 2 * time.Second
+)
+
+var (
+	// ErrLockTimeout is returned when another replica holds the migration
+	// lock for longer than the configured timeout.
+	ErrLockTimeout = errors.New("timed out waiting for the migration lock")
+	// ErrLockLost is returned when the lease expired, for instance because
+	// renewals failed for longer than its TTL, and another replica took the
+	// lock over.
+	ErrLockLost = errors.New("lost the migration lock to another instance")
+)
+
+// lock is a lease on the migration_lock document, renewed in the background
+// until released.
+type lock struct {
+	collection *mongo.Collection
+	owner      string
+	logger     *slog.Logger
+	lost       context.CancelCauseFunc
+	stop       chan struct{}
+	done       sync.WaitGroup
+}
+
+// acquireLock waits for the lock and returns it with a context derived from
+// ctx, which is cancelled with ErrLockLost if the lease is lost. Work done
+// under the lock must use that context.
+func acquireLock(ctx context.Context, database *db.Database, timeout time.Duration, logger *slog.Logger) (*lock, context.Context, error) {
+	l := &lock{
+		collection: database.GetCollection("migration_lock"),
+		owner:      uuid.New().String(),
+		logger:     logger,
+		stop:       make(chan struct{}),
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		acquired, err := l.tryAcquire(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		if acquired {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			return nil, nil, ErrLockTimeout
+		}
+		logger.InfoContext(ctx, "Waiting for another instance to finish migrating")
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+
+	ctx, l.lost = context.WithCancelCause(ctx)
+	l.done.Add(1)
+	go l.renew()
+	return l, ctx, nil
+}
+
+// tryAcquire takes the lock if it is free or its holder's lease expired. A
+// live lease makes the upsert collide with the existing document.
+func (l *lock) tryAcquire(ctx context.Context) (bool, error) {
+	now := time.Now()
+	_, err := l.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": lockID, "expires_at": bson.M{"$lt": now}},
+		bson.M{"$set": bson.M{
+			"owner":      l.owner,
+			"locked_at":  now,
+			"expires_at": now.Add(lockTTL),
+		}},
+		options.Update().SetUpsert(true),
+	)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (l *lock) renew() {
+	defer l.done.Done()
+
+	ticker := time.NewTicker(lockTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			result, err := l.collection.UpdateOne(
+				context.Background(),
+				bson.M{"_id": lockID, "owner": l.owner},
+				bson.M{"$set": bson.M{"expires_at": time.Now().Add(lockTTL)}},
+			)
+			if err != nil {
+				l.logger.Error("Error renewing migration lock", "error", err)
+				continue
+			}
+			if result.MatchedCount == 0 {
+				// Another replica holds the lock now, so migrating on would
+				// run migrations twice
+				l.logger.Error("Lost the migration lock, stopping migrations")
+				l.lost(ErrLockLost)
+				return
+			}
+		}
+	}
+}
+
+func (l *lock) release() {
+	close(l.stop)
+	l.done.Wait()
+	l.lost(nil)
+
+	if _, err := l.collection.DeleteOne(context.Background(), bson.M{"_id": lockID, "owner": l.owner}); err != nil {
+		l.logger.Error("Error releasing migration lock", "error", err)
+	}
+}
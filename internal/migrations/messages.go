@@ -0,0 +1,104 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// moveMessagesToCollection moves the messages embedded in chat sessions into
+// the messages collection, numbering them in their stored order, and leaves
+// the sessions with their message count and a preview of the last message.
+// Messages copied by an interrupted run collide with the session_seq index
+// and are skipped, so running it again is safe.
+func moveMessagesToCollection(ctx context.Context, env Env) error {
+	if err := messageIndexes.create(ctx, env); err != nil {
+		return err
+	}
+
+	// Replaced by the pending_attachments index of the messages collection
+	_, err := env.DB.GetCollection("chat_sessions").Indexes().DropOne(ctx, "pending_attachments")
+	var commandErr mongo.CommandError
+	if err != nil && !(errors.As(err, &commandErr) && commandErr.Name == "IndexNotFound") {
+		return err
+	}
+
+	sessions := env.DB.GetCollection("chat_sessions")
+	messages := env.DB.GetCollection("messages")
+
+	cursor, err := sessions.Find(ctx, bson.M{"messages": bson.M{"$exists": true}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	moved := 0
+
+	for cursor.Next(ctx) {
+		var session struct {
+			ID       primitive.ObjectID `bson:"_id"`
+			Messages []models.Message   `bson:"messages"`
+		}
+		if err := cursor.Decode(&session); err != nil {
+			return err
+		}
+
+		set := bson.M{"message_count": int64(len(session.Messages))}
+		if len(session.Messages) > 0 {
+			docs := make([]interface{}, len(session.Messages))
+			for i := range session.Messages {
+				message := &session.Messages[i]
+				if message.ID.IsZero() {
+					message.ID = primitive.NewObjectID()
+				}
+				message.SessionID = session.ID
+				message.Seq = int64(i + 1)
+				docs[i] = message
+			}
+
+			_, err := messages.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+			if err != nil && !onlyDuplicateKeyErrors(err) {
+				return err
+			}
+
+			set["last_message"] = session.Messages[len(session.Messages)-1].Preview()
+		}
+
+		_, err := sessions.UpdateOne(ctx,
+			bson.M{"_id": session.ID},
+			bson.M{"$set": set, "$unset": bson.M{"messages": ""}},
+		)
+		if err != nil {
+			return err
+		}
+		moved += len(session.Messages)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	if moved > 0 {
+		env.Logger.InfoContext(ctx, "Moved messages out of chat sessions", "messages", moved)
+	}
+	return nil
+}
+
+func onlyDuplicateKeyErrors(err error) bool {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return false
+	}
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Code != 11000 {
+			return false
+		}
+	}
+	return true
+}
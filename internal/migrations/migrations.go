@@ -0,0 +1,16 @@
+package migrations
+
+// All lists every migration. Append new ones with the next version; never
+// renumber or remove a migration that may have been applied somewhere.
+var All = []Migration{
+	{Version: 1, Name: "seed_doctors", Up: seedDoctors},
+	{Version: 2, Name: "seed_real_doctors", Up: seedRealDoctors},
+	{Version: 3, Name: "message_image_urls_to_keys", Up: migrateImageURLsToKeys},
+	{Version: 4, Name: "message_attachments", Up: migrateMessageAttachments},
+	{Version: 5, Name: "indexes", Up: addIndexes},
+	{Version: 6, Name: "messages_collection", Up: moveMessagesToCollection},
+	{Version: 7, Name: "daily_usage_expiry", Up: dailyUsageIndexes.create},
+	{Version: 8, Name: "llm_usage_indexes", Up: llmUsageIndexes.create},
+	{Version: 9, Name: "pending_replies_index", Up: pendingReplyIndexes.create},
+	{Version: 10, Name: "login_attempts_expiry", Up: expireLoginAttempts},
+}
@@ -0,0 +1,186 @@
+// Package migrations brings the database schema and data up to date. Each
+// migration has a version and runs once per database, in version order; the
+// versions applied are recorded in the migrations collection. A lock keeps
+// replicas that start together from running the same migration twice.
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/db"
+	"github.com/subhammahanty235/medai/internal/storage"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// migrationTimeout bounds a single migration.
+const migrationTimeout = time.Hour
+
+// Env holds what migrations may need besides the database.
+type Env struct {
+	DB      *db.Database
+	Storage storage.Storage
+	Logger  *slog.Logger
+}
+
+// Migration is one step of the schema history. Up must be idempotent: if it
+// fails part way the migration is not recorded and runs again in full next
+// time.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, env Env) error
+}
+
+// Record is stored in the migrations collection for each applied migration.
+type Record struct {
+	Version    int       `bson:"_id"`
+	Name       string    `bson:"name"`
+	AppliedAt  time.Time `bson:"applied_at"`
+	DurationMs int64     `bson:"duration_ms"`
+}
+
+// Status describes a known migration and whether it has been applied.
+type Status struct {
+	Migration Migration
+	Applied   *Record
+}
+
+type Migrator struct {
+	env         Env
+	migrations  []Migration
+	lockTimeout time.Duration
+}
+
+// NewMigrator orders the migrations by version and rejects duplicate or
+// non-positive versions.
+func NewMigrator(env Env, migrations []Migration, lockTimeout time.Duration) (*Migrator, error) {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+
+	for i, migration := range sorted {
+		if migration.Version <= 0 {
+			return nil, fmt.Errorf("migration %q has invalid version %d", migration.Name, migration.Version)
+		}
+		if i > 0 && sorted[i-1].Version == migration.Version {
+			return nil, fmt.Errorf("migrations %q and %q share version %d", sorted[i-1].Name, migration.Name, migration.Version)
+		}
+	}
+
+	return &Migrator{
+		env:         env,
+		migrations:  sorted,
+		lockTimeout: lockTimeout,
+	}, nil
+}
+
+// Status lists every known migration in order with its record, if applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Migration: migration}
+		if record, ok := applied[migration.Version]; ok {
+			status.Applied = &record
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Pending returns the migrations that have not been applied, in the order
+// Apply would run them.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; !ok {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// Apply runs the pending migrations in order while holding the migration
+// lock, waiting for another replica to finish first if needed. It stops at
+// the first failure and returns the migrations applied before it.
+func (m *Migrator) Apply(ctx context.Context) ([]Migration, error) {
+	lock, ctx, err := acquireLock(ctx, m.env.DB, m.lockTimeout, m.env.Logger)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.release()
+
+	// Another replica may have applied some while we waited for the lock
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range pending {
+		m.env.Logger.InfoContext(ctx, "Applying migration", "version", migration.Version, "name", migration.Name)
+
+		// The deadline replaces the database's per-operation timeout, which
+		// is sized for requests rather than scans of whole collections
+		started := time.Now()
+		upCtx, cancel := context.WithTimeout(ctx, migrationTimeout)
+		err := migration.Up(upCtx, m.env)
+		cancel()
+		if errors.Is(context.Cause(ctx), ErrLockLost) {
+			err = ErrLockLost
+		}
+		if err != nil {
+			return done, fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+
+		_, err = m.env.DB.GetCollection("migrations").InsertOne(ctx, Record{
+			Version:    migration.Version,
+			Name:       migration.Name,
+			AppliedAt:  time.Now(),
+			DurationMs: time.Since(started).Milliseconds(),
+		})
+		if err != nil {
+			return done, fmt.Errorf("recording migration %d %s: %w", migration.Version, migration.Name, err)
+		}
+
+		done = append(done, migration)
+	}
+
+	return done, nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]Record, error) {
+	cursor, err := m.env.DB.GetCollection("migrations").Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []Record
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]Record, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
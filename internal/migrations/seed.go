@@ -1,31 +1,26 @@
-package db
+package migrations
 
 import (
 	"context"
-	"log"
 
 	"github.com/subhammahanty235/medai/internal/models"
 
 	"go.mongodb.org/mongo-driver/bson"
 )
 
-func (d *Database) seedData() {
-	d.seedDoctors()
-	d.seedRealDoctors()
-}
-
-func (d *Database) seedDoctors() {
-	collection := d.GetCollection("doctors")
+// seedDoctors adds the AI doctors. Databases seeded before migrations
+// existed already have them and are left as they are.
+func seedDoctors(ctx context.Context, env Env) error {
+	collection := env.DB.GetCollection("doctors")
 
 	// Check if doctors already exist
-	count, err := collection.CountDocuments(context.Background(), bson.D{})
+	count, err := collection.CountDocuments(ctx, bson.D{})
 	if err != nil {
-		log.Printf("Error checking doctors count: %v", err)
-		return
+		return err
 	}
 
 	if count > 0 {
-		return // Data already exists
+		return nil // Data already exists
 	}
 
 	doctors := []interface{}{
@@ -85,26 +80,23 @@ func (d *Database) seedDoctors() {
 		},
 	}
 
-	_, err = collection.InsertMany(context.Background(), doctors)
-	if err != nil {
-		log.Printf("Error seeding doctors: %v", err)
-	} else {
-		log.Println("Doctors seeded successfully")
-	}
+	_, err = collection.InsertMany(ctx, doctors)
+	return err
 }
 
-func (d *Database) seedRealDoctors() {
-	collection := d.GetCollection("real_doctors")
+// seedRealDoctors adds the doctors that appointments can be booked with,
+// unless the database already has some.
+func seedRealDoctors(ctx context.Context, env Env) error {
+	collection := env.DB.GetCollection("real_doctors")
 
 	// Check if real doctors already exist
-	count, err := collection.CountDocuments(context.Background(), bson.D{})
+	count, err := collection.CountDocuments(ctx, bson.D{})
 	if err != nil {
-		log.Printf("Error checking real doctors count: %v", err)
-		return
+		return err
 	}
 
 	if count > 0 {
-		return // Data already exists
+		return nil // Data already exists
 	}
 
 	realDoctors := []interface{}{
@@ -158,10 +150,6 @@ func (d *Database) seedRealDoctors() {
 		},
 	}
 
-	_, err = collection.InsertMany(context.Background(), realDoctors)
-	if err != nil {
-		log.Printf("Error seeding real doctors: %v", err)
-	} else {
-		log.Println("Real doctors seeded successfully")
-	}
+	_, err = collection.InsertMany(ctx, realDoctors)
+	return err
 }
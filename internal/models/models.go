@@ -1,18 +1,63 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 type User struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Name      string             `bson:"name" json:"name"`
-	Email     string             `bson:"email" json:"email"`
-	Password  string             `bson:"password" json:"-"`
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Name          string             `bson:"name" json:"name"`
+	Email         string             `bson:"email" json:"email"`
+	EmailVerified bool               `bson:"email_verified" json:"email_verified"` // through a verification link or a sign-in provider
+	Password      string             `bson:"password" json:"-"`
+	Role          string             `bson:"role,omitempty" json:"role"` // user, doctor, admin
+	MFA           MFASettings        `bson:"mfa" json:"mfa"`
+	Identities    []ExternalIdentity `bson:"identities,omitempty" json:"identities,omitempty"`
+	// ProviderSignInAt is when the user last signed in through a provider,
+	// which confirms sensitive changes to accounts without a password
+	ProviderSignInAt time.Time `bson:"provider_sign_in_at,omitempty" json:"-"`
+
+	TokenVersion int `bson:"token_version" json:"-"`
+
+	PendingEmail         string    `bson:"pending_email,omitempty" json:"pending_email,omitempty"`
+	EmailChangeTokenHash string    `bson:"email_change_token_hash,omitempty" json:"-"`
+	EmailChangeExpiresAt time.Time `bson:"email_change_expires_at,omitempty" json:"-"`
+
+	CreatedAt time.Time `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+type MFASettings struct {
+	Enabled           bool      `bson:"enabled" json:"enabled"`
+	TOTPSecret        string    `bson:"totp_secret,omitempty" json:"-"`
+	PendingTOTPSecret string    `bson:"pending_totp_secret,omitempty" json:"-"`
+	LastUsedStep      int64     `bson:"last_used_step,omitempty" json:"-"`
+	RecoveryCodes     []string  `bson:"recovery_codes,omitempty" json:"-"` // SHA-256 hashes
+	EnrolledAt        time.Time `bson:"enrolled_at,omitempty" json:"enrolled_at,omitempty"`
+}
+
+// ExternalIdentity links a user to an account at an OpenID Connect provider.
+type ExternalIdentity struct {
+	Provider string    `bson:"provider" json:"provider"`
+	Subject  string    `bson:"subject" json:"-"`
+	Email    string    `bson:"email" json:"email"`
+	LinkedAt time.Time `bson:"linked_at" json:"linked_at"`
+}
+
+// OIDCLoginState holds what is needed to finish an authorization code flow
+// between the redirect to the provider and the callback.
+type OIDCLoginState struct {
+	State    string `bson:"_id"`
+	Provider string `bson:"provider"`
+	Verifier string `bson:"verifier"`
+	Nonce    string `bson:"nonce"`
+	// UserID is the signed-in user the provider account is being linked
+	// to, unset for sign-ins
+	UserID    primitive.ObjectID `bson:"user_id,omitempty"`
+	ExpiresAt time.Time          `bson:"expires_at"`
 }
 
 type Doctor struct {
@@ -37,21 +82,127 @@ type RealDoctor struct {
 }
 
 type ChatSession struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
-	DoctorID  string             `bson:"doctor_id" json:"doctor_id"`
-	Messages  []Message          `bson:"messages" json:"messages"`
-	Status    string             `bson:"status" json:"status"` // active, completed, doctor_recommended
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID   primitive.ObjectID `bson:"user_id" json:"user_id"`
+	DoctorID string             `bson:"doctor_id" json:"doctor_id"`
+	// DependentID is set when the user consults on behalf of a dependent
+	DependentID primitive.ObjectID `bson:"dependent_id,omitempty" json:"dependent_id,omitempty"`
+	Status      string             `bson:"status" json:"status"` // active, completed, doctor_recommended, closed
+	// MessageCount is the sequence number of the latest message
+	MessageCount int64           `bson:"message_count" json:"message_count"`
+	LastMessage  *MessagePreview `bson:"last_message,omitempty" json:"last_message,omitempty"`
+	CreatedAt    time.Time       `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time       `bson:"updated_at" json:"updated_at"`
+
+	// Messages are stored in their own collection. Responses include the
+	// latest page of them.
+	Messages        []Message `bson:"-" json:"messages,omitempty"`
+	HasMoreMessages bool      `bson:"-" json:"has_more_messages,omitempty"`
+}
+
+// MessagePreview summarizes the latest message of a session for session
+// lists.
+type MessagePreview struct {
+	Seq       int64     `bson:"seq" json:"seq"`
+	Content   string    `bson:"content" json:"content"`
+	Sender    string    `bson:"sender" json:"sender"`
+	Timestamp time.Time `bson:"timestamp" json:"timestamp"`
 }
 
+// messagePreviewLength caps the content of message previews, in bytes.
+const messagePreviewLength = 200
+
 type Message struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
-	Content   string             `bson:"content" json:"content"`
-	Sender    string             `bson:"sender" json:"sender"` // user, ai, system
-	ImageURL  string             `bson:"image_url,omitempty" json:"image_url,omitempty"`
-	Timestamp time.Time          `bson:"timestamp" json:"timestamp"`
+	SessionID primitive.ObjectID `bson:"session_id" json:"session_id"`
+	// Seq orders the messages of a session, starting at 1
+	Seq         int64        `bson:"seq" json:"seq"`
+	Content     string       `bson:"content" json:"content"`
+	Sender      string       `bson:"sender" json:"sender"` // user, ai, system
+	Attachments []Attachment `bson:"attachments,omitempty" json:"attachments,omitempty"`
+	Timestamp   time.Time    `bson:"timestamp" json:"timestamp"`
+	// ReplyStatus is pending on a user message the AI doctor has not
+	// answered yet; the reply can be generated again without resending it
+	ReplyStatus string `bson:"reply_status,omitempty" json:"reply_status,omitempty"`
+	// ReplyClaim and ReplyClaimedUntil keep other requests from generating
+	// the same reply while one is under way. The claim is a random token
+	// only its holder knows, so an expired holder cannot release a claim
+	// taken over by another request
+	ReplyClaim        string    `bson:"reply_claim,omitempty" json:"-"`
+	ReplyClaimedUntil time.Time `bson:"reply_claimed_until,omitempty" json:"-"`
+}
+
+// Preview summarizes the message for the session it belongs to.
+func (m Message) Preview() *MessagePreview {
+	content := m.Content
+	if len(content) > messagePreviewLength {
+		content = strings.ToValidUTF8(content[:messagePreviewLength], "") + "…"
+	}
+
+	return &MessagePreview{
+		Seq:       m.Seq,
+		Content:   content,
+		Sender:    m.Sender,
+		Timestamp: m.Timestamp,
+	}
+}
+
+// Attachment kinds.
+const (
+	AttachmentKindImage    = "image"
+	AttachmentKindDocument = "document"
+)
+
+// Attachment processing states. Images are pending until their variants
+// have been generated; documents are ready once stored.
+const (
+	AttachmentStatusPending     = "pending"
+	AttachmentStatusReady       = "ready"
+	AttachmentStatusFailed      = "failed"
+	AttachmentStatusUnsupported = "unsupported"
+)
+
+// Attachment is a file shared with a message. Attachments with the same
+// checksum within a session share one stored object.
+type Attachment struct {
+	ID   primitive.ObjectID `bson:"_id" json:"id"`
+	Kind string             `bson:"kind" json:"kind"` // image, document
+	Key  string             `bson:"key" json:"-"`
+	// URL is a short-lived signed link filled in per request for the
+	// session owner; it is never stored
+	URL         string `bson:"-" json:"url,omitempty"`
+	FileName    string `bson:"file_name,omitempty" json:"file_name,omitempty"`
+	ContentType string `bson:"content_type" json:"content_type"`
+	Size        int64  `bson:"size" json:"size"`
+	Width       int    `bson:"width,omitempty" json:"width,omitempty"`
+	Height      int    `bson:"height,omitempty" json:"height,omitempty"`
+	Checksum    string `bson:"checksum,omitempty" json:"checksum,omitempty"` // SHA-256, hex
+	// Status tracks background processing such as thumbnail generation
+	Status   string         `bson:"status" json:"status"` // pending, ready, failed, unsupported
+	Variants []ImageVariant `bson:"variants,omitempty" json:"variants,omitempty"`
+
+	// Documents only. HasText is false for scanned documents without a
+	// text layer.
+	PageCount int            `bson:"page_count,omitempty" json:"page_count,omitempty"`
+	HasText   bool           `bson:"has_text,omitempty" json:"has_text,omitempty"`
+	Pages     []DocumentPage `bson:"pages,omitempty" json:"-"`
+}
+
+type DocumentPage struct {
+	Number int    `bson:"number" json:"number"`
+	Text   string `bson:"text" json:"text"`
+}
+
+// ImageVariant is a resized rendition of an uploaded image, stored next to
+// the original.
+type ImageVariant struct {
+	Name        string `bson:"name" json:"name"` // thumbnail, web
+	Key         string `bson:"key" json:"-"`
+	URL         string `bson:"-" json:"url,omitempty"`
+	ContentType string `bson:"content_type" json:"content_type"`
+	Width       int    `bson:"width" json:"width"`
+	Height      int    `bson:"height" json:"height"`
+	Size        int64  `bson:"size" json:"size"`
 }
 
 type Appointment struct {
@@ -67,6 +218,59 @@ type Appointment struct {
 	UpdatedAt        time.Time          `bson:"updated_at" json:"updated_at"`
 }
 
+// HealthProfile holds the medical background of a user and the dependents
+// they consult for. It is only shared with the AI when AIContextConsent is set.
+type HealthProfile struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID           primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Self             HealthDetails      `bson:"self" json:"self"`
+	Dependents       []Dependent        `bson:"dependents" json:"dependents"`
+	AIContextConsent bool               `bson:"ai_context_consent" json:"ai_context_consent"`
+	ConsentUpdatedAt time.Time          `bson:"consent_updated_at,omitempty" json:"consent_updated_at,omitempty"`
+	CreatedAt        time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt        time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+type HealthDetails struct {
+	DateOfBirth        *time.Time   `bson:"date_of_birth,omitempty" json:"date_of_birth,omitempty"`
+	Sex                string       `bson:"sex,omitempty" json:"sex,omitempty"` // female, male, intersex
+	Allergies          []string     `bson:"allergies" json:"allergies"`
+	ChronicConditions  []string     `bson:"chronic_conditions" json:"chronic_conditions"`
+	CurrentMedications []Medication `bson:"current_medications" json:"current_medications"`
+}
+
+type Medication struct {
+	Name      string `bson:"name" json:"name" binding:"required"`
+	Dosage    string `bson:"dosage,omitempty" json:"dosage,omitempty"`
+	Frequency string `bson:"frequency,omitempty" json:"frequency,omitempty"`
+}
+
+type Dependent struct {
+	ID            primitive.ObjectID `bson:"_id" json:"id"`
+	Name          string             `bson:"name" json:"name"`
+	Relationship  string             `bson:"relationship" json:"relationship"` // child, parent, spouse, other
+	HealthDetails `bson:",inline"`
+}
+
+type LoginAttempt struct {
+	Key         string    `bson:"_id" json:"key"`           // email:<address> or ip:<address>
+	Failures    int       `bson:"failures" json:"failures"` // including attempts still being checked
+	WindowStart time.Time `bson:"window_start" json:"window_start"`
+	LastFailure time.Time `bson:"last_failure" json:"last_failure"`
+	LockedUntil time.Time `bson:"locked_until,omitempty" json:"locked_until,omitempty"`
+	ExpiresAt   time.Time `bson:"expires_at" json:"expires_at"`
+}
+
+type AuditEvent struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	Type      string             `bson:"type" json:"type"` // account_locked, ip_throttled
+	UserID    primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Email     string             `bson:"email,omitempty" json:"email,omitempty"`
+	IP        string             `bson:"ip,omitempty" json:"ip,omitempty"`
+	Details   map[string]string  `bson:"details,omitempty" json:"details,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
 // Request/Response DTOs
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
@@ -79,9 +283,85 @@ type RegisterRequest struct {
 	Password string `json:"password" binding:"required,min=6"`
 }
 
+// AuthResponse carries either an access token or, when a second factor is
+// needed, an MFA token to present to the MFA endpoints.
 type AuthResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token                 string `json:"token,omitempty"`
+	User                  User   `json:"user"`
+	MFARequired           bool   `json:"mfa_required,omitempty"`
+	MFAEnrollmentRequired bool   `json:"mfa_enrollment_required,omitempty"`
+	MFAToken              string `json:"mfa_token,omitempty"`
+}
+
+type UpdateProfileRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" binding:"required,min=6"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email"`
+	// Password is required unless the account has none
+	Password string `json:"password"`
+}
+
+type VerifyEmailChangeRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+type MFAVerifyRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"` // TOTP code or recovery code
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type MFADisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type MFAEnrollmentResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+type MFARecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+	Token         string   `json:"token,omitempty"`
+}
+
+type HealthDetailsRequest struct {
+	DateOfBirth        *time.Time   `json:"date_of_birth"`
+	Sex                string       `json:"sex" binding:"omitempty,oneof=female male intersex"`
+	Allergies          []string     `json:"allergies"`
+	ChronicConditions  []string     `json:"chronic_conditions"`
+	CurrentMedications []Medication `json:"current_medications" binding:"dive"`
+}
+
+type HealthProfileRequest struct {
+	HealthDetailsRequest
+	AIContextConsent bool `json:"ai_context_consent"`
+}
+
+type DependentRequest struct {
+	Name         string `json:"name" binding:"required"`
+	Relationship string `json:"relationship" binding:"required,oneof=child parent spouse other"`
+	HealthDetailsRequest
 }
 
 type ChatMessageRequest struct {
@@ -94,3 +374,91 @@ type AppointmentRequest struct {
 	Symptoms         string    `json:"symptoms"`
 	AIRecommendation string    `json:"ai_recommendation"`
 }
+
+// MessagePage is a page of a session's messages, oldest first. HasMore tells
+// whether older messages remain; request them with the Seq of the first
+// message as before.
+type MessagePage struct {
+	Messages []Message `json:"messages"`
+	HasMore  bool      `json:"has_more"`
+}
+
+// ErrorResponse is the body of every error answer. Error is a message for
+// people; Code is stable and meant for programs to branch on.
+type ErrorResponse struct {
+	Error     string       `json:"error"`
+	Code      string       `json:"code"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+// FieldError describes why one field of a request was rejected. Field is
+// the name the client sent, such as a JSON key or a path parameter.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// DailyUsage counts what a user consumed of their daily quotas on one UTC
+// day. Documents expire once the day is long over.
+type DailyUsage struct {
+	Key       string             `bson:"_id" json:"key"` // <user ID>:<YYYY-MM-DD>
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Day       string             `bson:"day" json:"day"`
+	Messages  int                `bson:"messages" json:"messages"`
+	Uploads   int                `bson:"uploads" json:"uploads"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+}
+
+// LLMUsage records one LLM call made for a consultation. CostUSD is
+// estimated from the price table in effect when the call was made; failed
+// calls are recorded too, with the tokens the API reported.
+type LLMUsage struct {
+	ID               primitive.ObjectID `bson:"_id,omitempty" json:"id,omitempty"`
+	UserID           primitive.ObjectID `bson:"user_id" json:"user_id"`
+	SessionID        primitive.ObjectID `bson:"session_id" json:"session_id"`
+	Persona          string             `bson:"persona" json:"persona"` // AI doctor ID
+	Model            string             `bson:"model" json:"model"`
+	PromptTokens     int                `bson:"prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int                `bson:"completion_tokens" json:"completion_tokens"`
+	LatencyMs        int64              `bson:"latency_ms" json:"latency_ms"`
+	CostUSD          float64            `bson:"cost_usd" json:"cost_usd"`
+	Outcome          string             `bson:"outcome" json:"outcome"` // success, error
+	CreatedAt        time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// UsageSummary totals the LLM usage of one day (YYYY-MM-DD), user ID or
+// persona, named by Key.
+type UsageSummary struct {
+	Key              string  `bson:"_id" json:"key"`
+	Calls            int     `bson:"calls" json:"calls"`
+	FailedCalls      int     `bson:"failed_calls" json:"failed_calls"`
+	PromptTokens     int     `bson:"prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int     `bson:"completion_tokens" json:"completion_tokens"`
+	CostUSD          float64 `bson:"cost_usd" json:"cost_usd"`
+	AvgLatencyMs     float64 `bson:"avg_latency_ms" json:"avg_latency_ms"`
+}
+
+// UsageBudget overrides the default monthly LLM budget of one user.
+type UsageBudget struct {
+	UserID     primitive.ObjectID `bson:"_id" json:"user_id"`
+	MonthlyUSD float64            `bson:"monthly_usd" json:"monthly_usd"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// BudgetStatus is a user's monthly LLM budget and what they have spent of
+// it. MonthlyUSD is zero when the user has no budget; Custom tells whether
+// it was set for the user rather than taken from the default.
+type BudgetStatus struct {
+	UserID       primitive.ObjectID `json:"user_id"`
+	MonthlyUSD   float64            `json:"monthly_usd"`
+	SpentUSD     float64            `json:"spent_usd"`
+	RemainingUSD float64            `json:"remaining_usd"`
+	Custom       bool               `json:"custom"`
+	ResetsAt     time.Time          `json:"resets_at"`
+}
+
+type UsageBudgetRequest struct {
+	MonthlyUSD *float64 `json:"monthly_usd" binding:"required,min=0"`
+}
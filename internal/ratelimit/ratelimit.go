@@ -0,0 +1,244 @@
+// Package ratelimit implements in-memory token buckets and the policies that
+// say how many requests a client IP and a user may make. Buckets live in the
+// memory of one instance, so with several replicas behind a load balancer
+// each of them enforces the limits on its own share of the traffic.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate allows Requests per Per, in bursts of up to Requests. A zero Rate
+// places no limit.
+type Rate struct {
+	Requests int
+	Per      time.Duration
+}
+
+// Unlimited reports whether r places no limit.
+func (r Rate) Unlimited() bool {
+	return r.Requests <= 0 || r.Per <= 0
+}
+
+func (r Rate) String() string {
+	if r.Unlimited() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", r.Requests, r.Per)
+}
+
+// Policy is the limit of a route group. IP applies to every request by
+// client address, User to authenticated requests by user ID unless Roles
+// has a rate for the user's role.
+type Policy struct {
+	IP    Rate
+	User  Rate
+	Roles map[string]Rate
+}
+
+// UserRate is the rate applying to a user with role.
+func (p Policy) UserRate(role string) Rate {
+	if rate, ok := p.Roles[role]; ok {
+		return rate
+	}
+	return p.User
+}
+
+// ParsePolicy parses a comma-separated list of scope=rate pairs, such as
+// "ip=60/m,user=20/m,admin=off". Scopes other than ip and user name roles.
+// Rates are a count per s, m, h or d, or off.
+func ParsePolicy(spec string) (Policy, error) {
+	policy := Policy{Roles: map[string]Rate{}}
+
+	err := parsePairs(spec, func(scope, value string) error {
+		rate, err := parseRate(value)
+		if err != nil {
+			return err
+		}
+
+		switch scope {
+		case "ip":
+			policy.IP = rate
+		case "user":
+			policy.User = rate
+		default:
+			policy.Roles[scope] = rate
+		}
+		return nil
+	})
+	if err != nil {
+		return Policy{}, err
+	}
+	return policy, nil
+}
+
+// Quota is a number of actions per day. Default applies to roles missing
+// from Roles; zero places no limit.
+type Quota struct {
+	Default int
+	Roles   map[string]int
+}
+
+// For returns the quota of role.
+func (q Quota) For(role string) int {
+	if limit, ok := q.Roles[role]; ok {
+		return limit
+	}
+	return q.Default
+}
+
+// ParseQuota parses a comma-separated list of scope=count pairs, such as
+// "user=50,doctor=200,admin=off". The user scope is the default for roles
+// that are not listed.
+func ParseQuota(spec string) (Quota, error) {
+	quota := Quota{Roles: map[string]int{}}
+
+	err := parsePairs(spec, func(scope, value string) error {
+		limit := 0
+		if value != "off" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed <= 0 {
+				return fmt.Errorf("invalid quota %q", value)
+			}
+			limit = parsed
+		}
+
+		if scope == "user" {
+			quota.Default = limit
+		} else {
+			quota.Roles[scope] = limit
+		}
+		return nil
+	})
+	if err != nil {
+		return Quota{}, err
+	}
+	return quota, nil
+}
+
+func parsePairs(spec string, parse func(scope, value string) error) error {
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		scope, value, ok := strings.Cut(pair, "=")
+		scope, value = strings.TrimSpace(scope), strings.TrimSpace(value)
+		if !ok || scope == "" {
+			return fmt.Errorf("invalid limit %q, expected scope=value", pair)
+		}
+		if err := parse(scope, value); err != nil {
+			return fmt.Errorf("%s: %w", scope, err)
+		}
+	}
+	return nil
+}
+
+var ratePeriods = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+}
+
+func parseRate(value string) (Rate, error) {
+	if value == "off" {
+		return Rate{}, nil
+	}
+
+	count, period, ok := strings.Cut(value, "/")
+	requests, err := strconv.Atoi(count)
+	per, known := ratePeriods[period]
+	if !ok || err != nil || requests <= 0 || !known {
+		return Rate{}, fmt.Errorf("invalid rate %q, expected a count per s, m, h or d", value)
+	}
+	return Rate{Requests: requests, Per: per}, nil
+}
+
+// Result is the outcome of taking a token. RetryAfter is set when the
+// request was refused.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // when the bucket will be full again
+}
+
+// sweepInterval is how often buckets that have refilled are dropped; a full
+// bucket is the same as none.
+const sweepInterval = time.Minute
+
+// Limiter holds a token bucket per key.
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{buckets: make(map[string]*bucket)}
+}
+
+// Allow takes a token from the bucket of key, which holds up to
+// rate.Requests tokens and refills at rate.
+func (l *Limiter) Allow(key string, rate Rate, now time.Time) Result {
+	if rate.Unlimited() {
+		return Result{Allowed: true}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	capacity := float64(rate.Requests)
+	perToken := rate.Per / time.Duration(rate.Requests)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		l.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = min(capacity, b.tokens+elapsed.Seconds()/perToken.Seconds())
+		b.updated = now
+	}
+
+	if b.tokens < 1 {
+		missing := time.Duration((1 - b.tokens) * float64(perToken))
+		return Result{Limit: rate.Requests, RetryAfter: max(missing, time.Second)}
+	}
+
+	b.tokens--
+	b.full = now.Add(time.Duration((capacity - b.tokens) * float64(perToken)))
+	return Result{
+		Allowed:   true,
+		Limit:     rate.Requests,
+		Remaining: int(math.Floor(b.tokens)),
+	}
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if !now.Before(b.full) {
+			delete(l.buckets, key)
+		}
+	}
+}
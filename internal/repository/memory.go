@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"slices"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// NewMemoryRepositories returns empty in-memory repositories.
+func NewMemoryRepositories() *Repositories {
+	return &Repositories{
+		Users:           NewMemoryUserRepository(),
+		Doctors:         NewMemoryDoctorRepository(),
+		RealDoctors:     NewMemoryRealDoctorRepository(),
+		ChatSessions:    NewMemoryChatSessionRepository(),
+		Messages:        NewMemoryMessageRepository(),
+		Appointments:    NewMemoryAppointmentRepository(),
+		HealthProfiles:  NewMemoryHealthProfileRepository(),
+		LoginAttempts:   NewMemoryLoginAttemptRepository(),
+		AuditEvents:     NewMemoryAuditEventRepository(),
+		DailyUsage:      NewMemoryDailyUsageRepository(),
+		LLMUsage:        NewMemoryLLMUsageRepository(),
+		UsageBudgets:    NewMemoryUsageBudgetRepository(),
+		OIDCLoginStates: NewMemoryOIDCLoginStateRepository(),
+	}
+}
+
+// memoryCollection is a list of documents guarded by a mutex. Documents are
+// copied through BSON on the way in and out, so callers cannot change stored
+// state through the values they hold and fields behave as they would in
+// MongoDB (omitempty, time precision, bson:"-").
+type memoryCollection[T any] struct {
+	mu   sync.RWMutex
+	docs []T
+}
+
+// insert adds doc unless a stored document conflicts with it.
+func (c *memoryCollection[T]) insert(doc *T, conflicts func(stored, doc *T) bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if conflicts != nil {
+		for i := range c.docs {
+			if conflicts(&c.docs[i], doc) {
+				return ErrDuplicate
+			}
+		}
+	}
+
+	c.docs = append(c.docs, clone(*doc))
+	return nil
+}
+
+// findOne returns a copy of the first document that matches.
+func (c *memoryCollection[T]) findOne(match func(*T) bool) (*T, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for i := range c.docs {
+		if match(&c.docs[i]) {
+			doc := clone(c.docs[i])
+			return &doc, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// findAll returns copies of the matching documents in insertion order.
+func (c *memoryCollection[T]) findAll(match func(*T) bool) []T {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var docs []T
+	for i := range c.docs {
+		if match(&c.docs[i]) {
+			docs = append(docs, clone(c.docs[i]))
+		}
+	}
+	return docs
+}
+
+// update applies apply to every matching document and returns how many
+// matched. apply may reject the change with an error, which stops the
+// update; documents already changed stay changed, like in MongoDB.
+func (c *memoryCollection[T]) update(match func(*T) bool, apply func(*T) error) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	matched := 0
+	for i := range c.docs {
+		if !match(&c.docs[i]) {
+			continue
+		}
+
+		doc := clone(c.docs[i])
+		if err := apply(&doc); err != nil {
+			return matched, err
+		}
+		c.docs[i] = clone(doc)
+		matched++
+	}
+	return matched, nil
+}
+
+// updateOne is update for lookups by a unique field; it returns ErrNotFound
+// if nothing matched.
+func (c *memoryCollection[T]) updateOne(match func(*T) bool, apply func(*T) error) error {
+	matched, err := c.update(match, apply)
+	if err != nil {
+		return err
+	}
+	if matched == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// upsert applies apply to the first matching document, or to the document
+// returned by create when none matches and stores it, and returns a copy of
+// the result. Both happen under one lock, like an upsert in MongoDB.
+func (c *memoryCollection[T]) upsert(match func(*T) bool, create func() T, apply func(*T) error) (*T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.docs {
+		if !match(&c.docs[i]) {
+			continue
+		}
+
+		doc := clone(c.docs[i])
+		if err := apply(&doc); err != nil {
+			return nil, err
+		}
+		c.docs[i] = clone(doc)
+		return &doc, nil
+	}
+
+	doc := create()
+	if err := apply(&doc); err != nil {
+		return nil, err
+	}
+	c.docs = append(c.docs, clone(doc))
+	return &doc, nil
+}
+
+// takeOne removes the first document that matches and returns it.
+func (c *memoryCollection[T]) takeOne(match func(*T) bool) (*T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.docs {
+		if match(&c.docs[i]) {
+			doc := c.docs[i]
+			c.docs = slices.Delete(c.docs, i, i+1)
+			return &doc, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (c *memoryCollection[T]) delete(match func(*T) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	kept := c.docs[:0]
+	for i := range c.docs {
+		if !match(&c.docs[i]) {
+			kept = append(kept, c.docs[i])
+		}
+	}
+	clear(c.docs[len(kept):])
+	c.docs = kept
+}
+
+// clone deep-copies a document through its BSON encoding.
+func clone[T any](doc T) T {
+	data, err := bson.Marshal(doc)
+	if err != nil {
+		panic("repository: cannot encode document: " + err.Error())
+	}
+
+	var copied T
+	if err := bson.Unmarshal(data, &copied); err != nil {
+		panic("repository: cannot decode document: " + err.Error())
+	}
+	return copied
+}
+
+var (
+	_ UserRepository           = (*MemoryUserRepository)(nil)
+	_ DoctorRepository         = (*MemoryDoctorRepository)(nil)
+	_ RealDoctorRepository     = (*MemoryRealDoctorRepository)(nil)
+	_ ChatSessionRepository    = (*MemoryChatSessionRepository)(nil)
+	_ MessageRepository        = (*MemoryMessageRepository)(nil)
+	_ AppointmentRepository    = (*MemoryAppointmentRepository)(nil)
+	_ HealthProfileRepository  = (*MemoryHealthProfileRepository)(nil)
+	_ LoginAttemptRepository   = (*MemoryLoginAttemptRepository)(nil)
+	_ AuditEventRepository     = (*MemoryAuditEventRepository)(nil)
+	_ DailyUsageRepository     = (*MemoryDailyUsageRepository)(nil)
+	_ LLMUsageRepository       = (*MemoryLLMUsageRepository)(nil)
+	_ UsageBudgetRepository    = (*MemoryUsageBudgetRepository)(nil)
+	_ OIDCLoginStateRepository = (*MemoryOIDCLoginStateRepository)(nil)
+)
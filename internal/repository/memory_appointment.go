@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MemoryAppointmentRepository struct {
+	appointments memoryCollection[models.Appointment]
+}
+
+func NewMemoryAppointmentRepository() *MemoryAppointmentRepository {
+	return &MemoryAppointmentRepository{}
+}
+
+func (r *MemoryAppointmentRepository) Create(ctx context.Context, appointment *models.Appointment) error {
+	if appointment.ID.IsZero() {
+		appointment.ID = primitive.NewObjectID()
+	}
+	return r.appointments.insert(appointment, nil)
+}
+
+func (r *MemoryAppointmentRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Appointment, error) {
+	return r.appointments.findOne(func(appointment *models.Appointment) bool { return appointment.ID == id })
+}
+
+func (r *MemoryAppointmentRepository) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.Appointment, error) {
+	return r.appointments.findAll(appointmentUser(userID)), nil
+}
+
+func (r *MemoryAppointmentRepository) UpdateStatus(ctx context.Context, id primitive.ObjectID, status string) (string, error) {
+	var previous string
+	err := r.appointments.updateOne(func(appointment *models.Appointment) bool {
+		return appointment.ID == id
+	}, func(appointment *models.Appointment) error {
+		previous = appointment.Status
+		appointment.Status = status
+		appointment.UpdatedAt = time.Now()
+		return nil
+	})
+	return previous, err
+}
+
+func (r *MemoryAppointmentRepository) AnonymizeByUser(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.appointments.update(appointmentUser(userID), func(appointment *models.Appointment) error {
+		appointment.UserID = primitive.NilObjectID
+		appointment.ChatSessionID = primitive.NilObjectID
+		appointment.Symptoms = ""
+		appointment.AIRecommendation = ""
+		appointment.UpdatedAt = time.Now()
+		return nil
+	})
+	return err
+}
+
+func (r *MemoryAppointmentRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	r.appointments.delete(appointmentUser(userID))
+	return nil
+}
+
+func appointmentUser(userID primitive.ObjectID) func(*models.Appointment) bool {
+	return func(appointment *models.Appointment) bool { return appointment.UserID == userID }
+}
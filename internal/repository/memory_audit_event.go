@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MemoryAuditEventRepository struct {
+	events memoryCollection[models.AuditEvent]
+}
+
+func NewMemoryAuditEventRepository() *MemoryAuditEventRepository {
+	return &MemoryAuditEventRepository{}
+}
+
+func (r *MemoryAuditEventRepository) Insert(ctx context.Context, event *models.AuditEvent) error {
+	if event.ID.IsZero() {
+		event.ID = primitive.NewObjectID()
+	}
+	return r.events.insert(event, nil)
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MemoryChatSessionRepository struct {
+	sessions memoryCollection[models.ChatSession]
+}
+
+func NewMemoryChatSessionRepository() *MemoryChatSessionRepository {
+	return &MemoryChatSessionRepository{}
+}
+
+func (r *MemoryChatSessionRepository) Create(ctx context.Context, session *models.ChatSession) error {
+	if session.ID.IsZero() {
+		session.ID = primitive.NewObjectID()
+	}
+	// Mirrors the unique index allowing one active session per user,
+	// doctor and patient
+	return r.sessions.insert(session, func(stored, session *models.ChatSession) bool {
+		return session.Status == "active" && stored.Status == "active" &&
+			stored.UserID == session.UserID && stored.DoctorID == session.DoctorID &&
+			stored.DependentID == session.DependentID
+	})
+}
+
+func (r *MemoryChatSessionRepository) FindByID(ctx context.Context, id, userID primitive.ObjectID) (*models.ChatSession, error) {
+	return r.sessions.findOne(func(session *models.ChatSession) bool {
+		return session.ID == id && session.UserID == userID
+	})
+}
+
+func (r *MemoryChatSessionRepository) FindActive(ctx context.Context, userID primitive.ObjectID, doctorID string, dependentID primitive.ObjectID) (*models.ChatSession, error) {
+	return r.sessions.findOne(func(session *models.ChatSession) bool {
+		return session.UserID == userID && session.DoctorID == doctorID &&
+			session.DependentID == dependentID && session.Status == "active"
+	})
+}
+
+func (r *MemoryChatSessionRepository) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.ChatSession, error) {
+	sessions := r.sessions.findAll(func(session *models.ChatSession) bool { return session.UserID == userID })
+	sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt) })
+	return sessions, nil
+}
+
+func (r *MemoryChatSessionRepository) ReserveMessageSeqs(ctx context.Context, id primitive.ObjectID, count int) (int64, error) {
+	var first int64
+	err := r.sessions.updateOne(sessionID(id), func(session *models.ChatSession) error {
+		first = session.MessageCount + 1
+		session.MessageCount += int64(count)
+		session.UpdatedAt = time.Now()
+		return nil
+	})
+	return first, err
+}
+
+func (r *MemoryChatSessionRepository) SetLastMessage(ctx context.Context, id primitive.ObjectID, preview models.MessagePreview) error {
+	_, err := r.sessions.update(sessionID(id), func(session *models.ChatSession) error {
+		if session.LastMessage == nil || session.LastMessage.Seq < preview.Seq {
+			session.LastMessage = &preview
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *MemoryChatSessionRepository) UpdateStatus(ctx context.Context, id primitive.ObjectID, status string) error {
+	return r.sessions.updateOne(sessionID(id), func(session *models.ChatSession) error {
+		session.Status = status
+		session.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+func (r *MemoryChatSessionRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	r.sessions.delete(func(session *models.ChatSession) bool { return session.UserID == userID })
+	return nil
+}
+
+func sessionID(id primitive.ObjectID) func(*models.ChatSession) bool {
+	return func(session *models.ChatSession) bool { return session.ID == id }
+}
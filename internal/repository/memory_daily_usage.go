@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MemoryDailyUsageRepository struct {
+	usage memoryCollection[models.DailyUsage]
+}
+
+func NewMemoryDailyUsageRepository() *MemoryDailyUsageRepository {
+	return &MemoryDailyUsageRepository{}
+}
+
+func (r *MemoryDailyUsageRepository) Increment(ctx context.Context, usage models.DailyUsage, kind string, limit int) (*models.DailyUsage, error) {
+	return r.usage.upsert(usageKey(usage.Key), func() models.DailyUsage {
+		return models.DailyUsage{
+			Key:       usage.Key,
+			UserID:    usage.UserID,
+			Day:       usage.Day,
+			ExpiresAt: usage.ExpiresAt,
+		}
+	}, func(stored *models.DailyUsage) error {
+		count, err := usageCounter(stored, kind)
+		if err != nil {
+			return err
+		}
+		if *count >= limit {
+			return ErrLimitReached
+		}
+		*count++
+		return nil
+	})
+}
+
+func (r *MemoryDailyUsageRepository) Decrement(ctx context.Context, key, kind string) error {
+	_, err := r.usage.update(usageKey(key), func(stored *models.DailyUsage) error {
+		count, err := usageCounter(stored, kind)
+		if err != nil {
+			return err
+		}
+		if *count > 0 {
+			*count--
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *MemoryDailyUsageRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	r.usage.delete(func(usage *models.DailyUsage) bool { return usage.UserID == userID })
+	return nil
+}
+
+func usageKey(key string) func(*models.DailyUsage) bool {
+	return func(usage *models.DailyUsage) bool { return usage.Key == key }
+}
+
+func usageCounter(usage *models.DailyUsage, kind string) (*int, error) {
+	switch kind {
+	case "messages":
+		return &usage.Messages, nil
+	case "uploads":
+		return &usage.Uploads, nil
+	default:
+		return nil, fmt.Errorf("unknown usage counter %q", kind)
+	}
+}
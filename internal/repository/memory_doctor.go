@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MemoryDoctorRepository struct {
+	doctors memoryCollection[models.Doctor]
+}
+
+// NewMemoryDoctorRepository returns a repository holding doctors.
+func NewMemoryDoctorRepository(doctors ...models.Doctor) *MemoryDoctorRepository {
+	r := &MemoryDoctorRepository{}
+	for i := range doctors {
+		r.doctors.insert(&doctors[i], nil)
+	}
+	return r
+}
+
+func (r *MemoryDoctorRepository) ListAI(ctx context.Context) ([]models.Doctor, error) {
+	return r.doctors.findAll(func(doctor *models.Doctor) bool { return doctor.IsAI }), nil
+}
+
+func (r *MemoryDoctorRepository) FindByID(ctx context.Context, id string) (*models.Doctor, error) {
+	return r.doctors.findOne(func(doctor *models.Doctor) bool { return doctor.ID == id })
+}
+
+type MemoryRealDoctorRepository struct {
+	doctors memoryCollection[models.RealDoctor]
+}
+
+// NewMemoryRealDoctorRepository returns a repository holding doctors.
+func NewMemoryRealDoctorRepository(doctors ...models.RealDoctor) *MemoryRealDoctorRepository {
+	r := &MemoryRealDoctorRepository{}
+	for i := range doctors {
+		if doctors[i].ID.IsZero() {
+			doctors[i].ID = primitive.NewObjectID()
+		}
+		r.doctors.insert(&doctors[i], nil)
+	}
+	return r
+}
+
+func (r *MemoryRealDoctorRepository) List(ctx context.Context) ([]models.RealDoctor, error) {
+	return r.doctors.findAll(func(*models.RealDoctor) bool { return true }), nil
+}
+
+func (r *MemoryRealDoctorRepository) ListBySpecialty(ctx context.Context, specialty string) ([]models.RealDoctor, error) {
+	return r.doctors.findAll(func(doctor *models.RealDoctor) bool { return doctor.Specialty == specialty }), nil
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MemoryHealthProfileRepository struct {
+	profiles memoryCollection[models.HealthProfile]
+}
+
+func NewMemoryHealthProfileRepository() *MemoryHealthProfileRepository {
+	return &MemoryHealthProfileRepository{}
+}
+
+func (r *MemoryHealthProfileRepository) FindByUser(ctx context.Context, userID primitive.ObjectID) (*models.HealthProfile, error) {
+	return r.profiles.findOne(profileOf(userID))
+}
+
+func (r *MemoryHealthProfileRepository) UpdateSelf(ctx context.Context, userID primitive.ObjectID, self models.HealthDetails, consent bool, consentUpdatedAt time.Time) error {
+	now := time.Now()
+	_, err := r.profiles.upsert(profileOf(userID), func() models.HealthProfile {
+		return models.HealthProfile{
+			ID:         primitive.NewObjectID(),
+			UserID:     userID,
+			Dependents: []models.Dependent{},
+			CreatedAt:  now,
+		}
+	}, func(profile *models.HealthProfile) error {
+		profile.Self = self
+		profile.AIContextConsent = consent
+		profile.UpdatedAt = now
+		if !consentUpdatedAt.IsZero() {
+			profile.ConsentUpdatedAt = consentUpdatedAt
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *MemoryHealthProfileRepository) AddDependent(ctx context.Context, userID primitive.ObjectID, dependent models.Dependent) error {
+	now := time.Now()
+	_, err := r.profiles.upsert(profileOf(userID), func() models.HealthProfile {
+		return models.HealthProfile{
+			ID:         primitive.NewObjectID(),
+			UserID:     userID,
+			Self:       emptyHealthDetails(),
+			Dependents: []models.Dependent{},
+			CreatedAt:  now,
+		}
+	}, func(profile *models.HealthProfile) error {
+		profile.Dependents = append(profile.Dependents, dependent)
+		profile.UpdatedAt = now
+		return nil
+	})
+	return err
+}
+
+func (r *MemoryHealthProfileRepository) UpdateDependent(ctx context.Context, userID primitive.ObjectID, dependent models.Dependent) error {
+	return r.profiles.updateOne(hasDependent(userID, dependent.ID), func(profile *models.HealthProfile) error {
+		i := slices.IndexFunc(profile.Dependents, func(stored models.Dependent) bool { return stored.ID == dependent.ID })
+		profile.Dependents[i] = dependent
+		profile.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+func (r *MemoryHealthProfileRepository) RemoveDependent(ctx context.Context, userID, dependentID primitive.ObjectID) error {
+	return r.profiles.updateOne(hasDependent(userID, dependentID), func(profile *models.HealthProfile) error {
+		profile.Dependents = slices.DeleteFunc(profile.Dependents, func(stored models.Dependent) bool { return stored.ID == dependentID })
+		profile.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+func (r *MemoryHealthProfileRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	r.profiles.delete(profileOf(userID))
+	return nil
+}
+
+func profileOf(userID primitive.ObjectID) func(*models.HealthProfile) bool {
+	return func(profile *models.HealthProfile) bool { return profile.UserID == userID }
+}
+
+func hasDependent(userID, dependentID primitive.ObjectID) func(*models.HealthProfile) bool {
+	return func(profile *models.HealthProfile) bool {
+		return profile.UserID == userID && slices.ContainsFunc(profile.Dependents, func(dependent models.Dependent) bool {
+			return dependent.ID == dependentID
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MemoryLLMUsageRepository struct {
+	usage memoryCollection[models.LLMUsage]
+}
+
+func NewMemoryLLMUsageRepository() *MemoryLLMUsageRepository {
+	return &MemoryLLMUsageRepository{}
+}
+
+func (r *MemoryLLMUsageRepository) Insert(ctx context.Context, usage *models.LLMUsage) error {
+	if usage.ID.IsZero() {
+		usage.ID = primitive.NewObjectID()
+	}
+	return r.usage.insert(usage, nil)
+}
+
+func (r *MemoryLLMUsageRepository) Summarize(ctx context.Context, groupBy string, filter UsageFilter, limit int) ([]models.UsageSummary, error) {
+	var key func(*models.LLMUsage) string
+	switch groupBy {
+	case "day":
+		key = func(usage *models.LLMUsage) string { return usage.CreatedAt.UTC().Format(time.DateOnly) }
+	case "user":
+		key = func(usage *models.LLMUsage) string { return usage.UserID.Hex() }
+	case "persona":
+		key = func(usage *models.LLMUsage) string { return usage.Persona }
+	default:
+		return nil, fmt.Errorf("unknown usage grouping %q", groupBy)
+	}
+
+	calls := r.usage.findAll(func(usage *models.LLMUsage) bool {
+		return !usage.CreatedAt.Before(filter.From) && usage.CreatedAt.Before(filter.To) &&
+			(filter.UserID.IsZero() || usage.UserID == filter.UserID) &&
+			(filter.Persona == "" || usage.Persona == filter.Persona)
+	})
+
+	groups := map[string]*models.UsageSummary{}
+	latency := map[string]int64{}
+	for i := range calls {
+		call := &calls[i]
+		group := groups[key(call)]
+		if group == nil {
+			group = &models.UsageSummary{Key: key(call)}
+			groups[group.Key] = group
+		}
+
+		group.Calls++
+		if call.Outcome == "error" {
+			group.FailedCalls++
+		}
+		group.PromptTokens += call.PromptTokens
+		group.CompletionTokens += call.CompletionTokens
+		group.CostUSD += call.CostUSD
+		latency[group.Key] += call.LatencyMs
+	}
+
+	summaries := []models.UsageSummary{}
+	for _, group := range groups {
+		group.AvgLatencyMs = float64(latency[group.Key]) / float64(group.Calls)
+		summaries = append(summaries, *group)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if groupBy != "day" && summaries[i].CostUSD != summaries[j].CostUSD {
+			return summaries[i].CostUSD > summaries[j].CostUSD
+		}
+		return summaries[i].Key < summaries[j].Key
+	})
+
+	if len(summaries) > limit {
+		summaries = summaries[:limit]
+	}
+	return summaries, nil
+}
+
+func (r *MemoryLLMUsageRepository) TotalCost(ctx context.Context, userID primitive.ObjectID, since time.Time) (float64, error) {
+	var total float64
+	for _, usage := range r.usage.findAll(func(usage *models.LLMUsage) bool {
+		return usage.UserID == userID && !usage.CreatedAt.Before(since)
+	}) {
+		total += usage.CostUSD
+	}
+	return total, nil
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/models"
+)
+
+type MemoryLoginAttemptRepository struct {
+	attempts memoryCollection[models.LoginAttempt]
+}
+
+func NewMemoryLoginAttemptRepository() *MemoryLoginAttemptRepository {
+	return &MemoryLoginAttemptRepository{}
+}
+
+func (r *MemoryLoginAttemptRepository) Find(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	return r.attempts.findOne(attemptKey(key))
+}
+
+func (r *MemoryLoginAttemptRepository) RecordAttempt(ctx context.Context, key string, now, expiresAt time.Time) (*models.LoginAttempt, error) {
+	before, created := models.LoginAttempt{Key: key}, false
+	_, err := r.attempts.upsert(attemptKey(key), func() models.LoginAttempt {
+		created = true
+		return models.LoginAttempt{Key: key, WindowStart: now}
+	}, func(attempt *models.LoginAttempt) error {
+		if !created {
+			before = *attempt
+		}
+		if now.Before(attempt.LockedUntil) {
+			return ErrLocked
+		}
+		attempt.Failures++
+		attempt.LastFailure = now
+		attempt.ExpiresAt = expiresAt
+		return nil
+	})
+	return &before, err
+}
+
+func (r *MemoryLoginAttemptRepository) Decrement(ctx context.Context, key string) error {
+	_, err := r.attempts.update(attemptKey(key), func(attempt *models.LoginAttempt) error {
+		if attempt.Failures > 0 {
+			attempt.Failures--
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *MemoryLoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	return r.attempts.updateOne(attemptKey(key), func(attempt *models.LoginAttempt) error {
+		attempt.Failures = 0
+		attempt.LockedUntil = until
+		return nil
+	})
+}
+
+func (r *MemoryLoginAttemptRepository) RestartWindow(ctx context.Context, key string, now time.Time) error {
+	return r.attempts.updateOne(attemptKey(key), func(attempt *models.LoginAttempt) error {
+		attempt.Failures = 1
+		attempt.WindowStart = now
+		return nil
+	})
+}
+
+func (r *MemoryLoginAttemptRepository) Delete(ctx context.Context, key string) error {
+	r.attempts.delete(attemptKey(key))
+	return nil
+}
+
+func attemptKey(key string) func(*models.LoginAttempt) bool {
+	return func(attempt *models.LoginAttempt) bool { return attempt.Key == key }
+}
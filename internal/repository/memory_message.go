@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MemoryMessageRepository struct {
+	messages memoryCollection[models.Message]
+}
+
+func NewMemoryMessageRepository() *MemoryMessageRepository {
+	return &MemoryMessageRepository{}
+}
+
+func (r *MemoryMessageRepository) Insert(ctx context.Context, messages []models.Message) error {
+	for i := range messages {
+		if messages[i].ID.IsZero() {
+			messages[i].ID = primitive.NewObjectID()
+		}
+
+		// Mirrors the unique index on session and sequence number
+		err := r.messages.insert(&messages[i], func(stored, message *models.Message) bool {
+			return stored.ID == message.ID ||
+				(stored.SessionID == message.SessionID && stored.Seq == message.Seq)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MemoryMessageRepository) List(ctx context.Context, sessionID primitive.ObjectID, before int64, limit int) ([]models.Message, error) {
+	messages := r.messages.findAll(func(message *models.Message) bool {
+		return message.SessionID == sessionID && (before <= 0 || message.Seq < before)
+	})
+	sort.Slice(messages, func(i, j int) bool { return messages[i].Seq < messages[j].Seq })
+
+	if limit > 0 && len(messages) > limit {
+		messages = messages[len(messages)-limit:]
+	}
+	return messages, nil
+}
+
+func (r *MemoryMessageRepository) FindAttachment(ctx context.Context, sessionID primitive.ObjectID, checksum string) (*models.Attachment, error) {
+	var found *models.Attachment
+	_, err := r.messages.findOne(func(message *models.Message) bool {
+		if message.SessionID != sessionID {
+			return false
+		}
+		for i := range message.Attachments {
+			if message.Attachments[i].Checksum == checksum {
+				attachment := message.Attachments[i]
+				found = &attachment
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+func (r *MemoryMessageRepository) FindProcessedAttachment(ctx context.Context, sessionID primitive.ObjectID, key string) (*models.Attachment, error) {
+	var found *models.Attachment
+	_, err := r.messages.findOne(func(message *models.Message) bool {
+		if message.SessionID != sessionID {
+			return false
+		}
+		for i := range message.Attachments {
+			if message.Attachments[i].Key == key && message.Attachments[i].Status != "pending" {
+				attachment := message.Attachments[i]
+				found = &attachment
+				return true
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+func (r *MemoryMessageRepository) ListWithPendingAttachments(ctx context.Context) ([]models.Message, error) {
+	return r.messages.findAll(func(message *models.Message) bool {
+		for _, attachment := range message.Attachments {
+			if attachment.Status == "pending" {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (r *MemoryMessageRepository) UpdateAttachments(ctx context.Context, sessionID primitive.ObjectID, key, status string, variants []models.ImageVariant) error {
+	matched, err := r.messages.update(func(message *models.Message) bool {
+		if message.SessionID != sessionID {
+			return false
+		}
+		for _, attachment := range message.Attachments {
+			if attachment.Key == key {
+				return true
+			}
+		}
+		return false
+	}, func(message *models.Message) error {
+		for i := range message.Attachments {
+			attachment := &message.Attachments[i]
+			if attachment.Key != key {
+				continue
+			}
+
+			attachment.Status = status
+			if variants != nil {
+				attachment.Variants = variants
+			}
+		}
+		return nil
+	})
+	if err == nil && matched == 0 {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (r *MemoryMessageRepository) DeleteBySessions(ctx context.Context, sessionIDs []primitive.ObjectID) error {
+	sessions := make(map[primitive.ObjectID]bool, len(sessionIDs))
+	for _, id := range sessionIDs {
+		sessions[id] = true
+	}
+
+	r.messages.delete(func(message *models.Message) bool { return sessions[message.SessionID] })
+	return nil
+}
+
+func (r *MemoryMessageRepository) FindPendingReply(ctx context.Context, sessionID primitive.ObjectID) (*models.Message, error) {
+	messages := r.messages.findAll(func(message *models.Message) bool {
+		return message.SessionID == sessionID && message.ReplyStatus == "pending"
+	})
+	if len(messages) == 0 {
+		return nil, ErrNotFound
+	}
+
+	latest := &messages[0]
+	for i := range messages {
+		if messages[i].Seq > latest.Seq {
+			latest = &messages[i]
+		}
+	}
+	return latest, nil
+}
+
+func (r *MemoryMessageRepository) ClaimReply(ctx context.Context, id primitive.ObjectID, claim string, now, until time.Time) error {
+	return r.messages.updateOne(func(message *models.Message) bool {
+		return message.ID == id && message.ReplyStatus == "pending" && !message.ReplyClaimedUntil.After(now)
+	}, func(message *models.Message) error {
+		message.ReplyClaim = claim
+		message.ReplyClaimedUntil = until
+		return nil
+	})
+}
+
+func (r *MemoryMessageRepository) ReleaseReply(ctx context.Context, id primitive.ObjectID, claim string) error {
+	return r.messages.updateOne(func(message *models.Message) bool {
+		return message.ID == id && message.ReplyClaim == claim
+	}, func(message *models.Message) error {
+		message.ReplyClaim = ""
+		message.ReplyClaimedUntil = time.Time{}
+		return nil
+	})
+}
+
+func (r *MemoryMessageRepository) CompleteReplies(ctx context.Context, sessionID primitive.ObjectID, seq int64) error {
+	_, err := r.messages.update(func(message *models.Message) bool {
+		return message.SessionID == sessionID && message.ReplyStatus == "pending" && message.Seq <= seq
+	}, func(message *models.Message) error {
+		message.ReplyStatus = ""
+		message.ReplyClaim = ""
+		message.ReplyClaimedUntil = time.Time{}
+		return nil
+	})
+	return err
+}
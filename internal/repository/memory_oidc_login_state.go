@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/subhammahanty235/medai/internal/models"
+)
+
+type MemoryOIDCLoginStateRepository struct {
+	states memoryCollection[models.OIDCLoginState]
+}
+
+func NewMemoryOIDCLoginStateRepository() *MemoryOIDCLoginStateRepository {
+	return &MemoryOIDCLoginStateRepository{}
+}
+
+func (r *MemoryOIDCLoginStateRepository) Create(ctx context.Context, state *models.OIDCLoginState) error {
+	return r.states.insert(state, func(stored, state *models.OIDCLoginState) bool {
+		return stored.State == state.State
+	})
+}
+
+func (r *MemoryOIDCLoginStateRepository) Take(ctx context.Context, state, provider string) (*models.OIDCLoginState, error) {
+	return r.states.takeOne(func(stored *models.OIDCLoginState) bool {
+		return stored.State == state && stored.Provider == provider
+	})
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MemoryUsageBudgetRepository struct {
+	budgets memoryCollection[models.UsageBudget]
+}
+
+func NewMemoryUsageBudgetRepository() *MemoryUsageBudgetRepository {
+	return &MemoryUsageBudgetRepository{}
+}
+
+func (r *MemoryUsageBudgetRepository) FindByUser(ctx context.Context, userID primitive.ObjectID) (*models.UsageBudget, error) {
+	return r.budgets.findOne(budgetOf(userID))
+}
+
+func (r *MemoryUsageBudgetRepository) Save(ctx context.Context, budget *models.UsageBudget) error {
+	_, err := r.budgets.upsert(budgetOf(budget.UserID), func() models.UsageBudget {
+		return models.UsageBudget{UserID: budget.UserID}
+	}, func(stored *models.UsageBudget) error {
+		*stored = *budget
+		return nil
+	})
+	return err
+}
+
+func (r *MemoryUsageBudgetRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	r.budgets.delete(budgetOf(userID))
+	return nil
+}
+
+func budgetOf(userID primitive.ObjectID) func(*models.UsageBudget) bool {
+	return func(budget *models.UsageBudget) bool { return budget.UserID == userID }
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MemoryUserRepository struct {
+	users memoryCollection[models.User]
+}
+
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{}
+}
+
+func (r *MemoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	return r.users.insert(user, sameEmail)
+}
+
+func (r *MemoryUserRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return r.users.findOne(userID(id))
+}
+
+func (r *MemoryUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.users.findOne(func(user *models.User) bool { return user.Email == email })
+}
+
+func (r *MemoryUserRepository) FindByIdentity(ctx context.Context, provider, subject string) (*models.User, error) {
+	return r.users.findOne(func(user *models.User) bool {
+		return slices.ContainsFunc(user.Identities, func(identity models.ExternalIdentity) bool {
+			return identity.Provider == provider && identity.Subject == subject
+		})
+	})
+}
+
+func (r *MemoryUserRepository) FindByEmailChangeToken(ctx context.Context, tokenHash string, now time.Time) (*models.User, error) {
+	return r.users.findOne(func(user *models.User) bool {
+		return user.EmailChangeTokenHash != "" && user.EmailChangeTokenHash == tokenHash &&
+			user.EmailChangeExpiresAt.After(now)
+	})
+}
+
+func (r *MemoryUserRepository) GetTokenVersion(ctx context.Context, id primitive.ObjectID) (int, error) {
+	user, err := r.FindByID(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	return user.TokenVersion, nil
+}
+
+func (r *MemoryUserRepository) UpdateName(ctx context.Context, id primitive.ObjectID, name string) error {
+	return r.set(id, func(user *models.User) { user.Name = name })
+}
+
+func (r *MemoryUserRepository) UpdatePassword(ctx context.Context, id primitive.ObjectID, passwordHash string) error {
+	return r.set(id, func(user *models.User) {
+		user.Password = passwordHash
+		user.TokenVersion++
+	})
+}
+
+func (r *MemoryUserRepository) SetPendingEmail(ctx context.Context, id primitive.ObjectID, email, tokenHash string, expiresAt time.Time) error {
+	return r.set(id, func(user *models.User) {
+		user.PendingEmail = email
+		user.EmailChangeTokenHash = tokenHash
+		user.EmailChangeExpiresAt = expiresAt
+	})
+}
+
+func (r *MemoryUserRepository) ApplyEmailChange(ctx context.Context, id primitive.ObjectID, email string) error {
+	return r.users.updateOne(userID(id), func(user *models.User) error {
+		// apply runs under the collection lock, so the conflict check and the
+		// change are atomic like with the unique index in MongoDB
+		for _, other := range r.users.docs {
+			if other.ID != id && other.Email == email {
+				return ErrDuplicate
+			}
+		}
+
+		user.Email = email
+		user.EmailVerified = true
+		user.TokenVersion++
+		user.PendingEmail = ""
+		user.EmailChangeTokenHash = ""
+		user.EmailChangeExpiresAt = time.Time{}
+		user.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+func (r *MemoryUserRepository) AddIdentity(ctx context.Context, id primitive.ObjectID, identity models.ExternalIdentity) error {
+	return r.set(id, func(user *models.User) { user.Identities = append(user.Identities, identity) })
+}
+
+func (r *MemoryUserRepository) SetProviderSignIn(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	return r.set(id, func(user *models.User) { user.ProviderSignInAt = at })
+}
+
+func (r *MemoryUserRepository) SetMFA(ctx context.Context, id primitive.ObjectID, mfa models.MFASettings) error {
+	return r.set(id, func(user *models.User) { user.MFA = mfa })
+}
+
+func (r *MemoryUserRepository) SetPendingTOTPSecret(ctx context.Context, id primitive.ObjectID, secret string) error {
+	return r.set(id, func(user *models.User) { user.MFA.PendingTOTPSecret = secret })
+}
+
+func (r *MemoryUserRepository) SetRecoveryCodes(ctx context.Context, id primitive.ObjectID, hashes []string) error {
+	return r.set(id, func(user *models.User) { user.MFA.RecoveryCodes = hashes })
+}
+
+func (r *MemoryUserRepository) ConsumeTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) (bool, error) {
+	matched, err := r.users.update(func(user *models.User) bool {
+		return user.ID == id && user.MFA.LastUsedStep != 0 && user.MFA.LastUsedStep < step
+	}, func(user *models.User) error {
+		user.MFA.LastUsedStep = step
+		return nil
+	})
+	return matched == 1, err
+}
+
+func (r *MemoryUserRepository) ConsumeRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) (bool, error) {
+	matched, err := r.users.update(func(user *models.User) bool {
+		return user.ID == id && slices.Contains(user.MFA.RecoveryCodes, hash)
+	}, func(user *models.User) error {
+		user.MFA.RecoveryCodes = slices.DeleteFunc(user.MFA.RecoveryCodes, func(code string) bool { return code == hash })
+		return nil
+	})
+	return matched == 1, err
+}
+
+func (r *MemoryUserRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.users.delete(userID(id))
+	return nil
+}
+
+func (r *MemoryUserRepository) set(id primitive.ObjectID, apply func(*models.User)) error {
+	return r.users.updateOne(userID(id), func(user *models.User) error {
+		apply(user)
+		user.UpdatedAt = time.Now()
+		return nil
+	})
+}
+
+func userID(id primitive.ObjectID) func(*models.User) bool {
+	return func(user *models.User) bool { return user.ID == id }
+}
+
+func sameEmail(stored, user *models.User) bool {
+	return stored.Email == user.Email
+}
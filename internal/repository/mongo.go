@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/subhammahanty235/medai/internal/db"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NewMongoRepositories returns the MongoDB implementation of every
+// repository.
+func NewMongoRepositories(database *db.Database) *Repositories {
+	return &Repositories{
+		Users:           NewMongoUserRepository(database),
+		Doctors:         NewMongoDoctorRepository(database),
+		RealDoctors:     NewMongoRealDoctorRepository(database),
+		ChatSessions:    NewMongoChatSessionRepository(database),
+		Messages:        NewMongoMessageRepository(database),
+		Appointments:    NewMongoAppointmentRepository(database),
+		HealthProfiles:  NewMongoHealthProfileRepository(database),
+		LoginAttempts:   NewMongoLoginAttemptRepository(database),
+		AuditEvents:     NewMongoAuditEventRepository(database),
+		DailyUsage:      NewMongoDailyUsageRepository(database),
+		LLMUsage:        NewMongoLLMUsageRepository(database),
+		UsageBudgets:    NewMongoUsageBudgetRepository(database),
+		OIDCLoginStates: NewMongoOIDCLoginStateRepository(database),
+	}
+}
+
+func findOne(ctx context.Context, collection *mongo.Collection, filter interface{}, result interface{}, opts ...*options.FindOneOptions) error {
+	err := collection.FindOne(ctx, filter, opts...).Decode(result)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func findAll[T any](ctx context.Context, collection *mongo.Collection, filter interface{}, opts ...*options.FindOptions) ([]T, error) {
+	cursor, err := collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var results []T
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// updateOne applies update to the document matching filter and returns
+// ErrNotFound if there is none.
+func updateOne(ctx context.Context, collection *mongo.Collection, filter, update interface{}, opts ...*options.UpdateOptions) error {
+	result, err := collection.UpdateOne(ctx, filter, update, opts...)
+	if err != nil {
+		return writeError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func writeError(err error) error {
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+var (
+	_ UserRepository           = (*MongoUserRepository)(nil)
+	_ DoctorRepository         = (*MongoDoctorRepository)(nil)
+	_ RealDoctorRepository     = (*MongoRealDoctorRepository)(nil)
+	_ ChatSessionRepository    = (*MongoChatSessionRepository)(nil)
+	_ MessageRepository        = (*MongoMessageRepository)(nil)
+	_ AppointmentRepository    = (*MongoAppointmentRepository)(nil)
+	_ HealthProfileRepository  = (*MongoHealthProfileRepository)(nil)
+	_ LoginAttemptRepository   = (*MongoLoginAttemptRepository)(nil)
+	_ AuditEventRepository     = (*MongoAuditEventRepository)(nil)
+	_ DailyUsageRepository     = (*MongoDailyUsageRepository)(nil)
+	_ LLMUsageRepository       = (*MongoLLMUsageRepository)(nil)
+	_ UsageBudgetRepository    = (*MongoUsageBudgetRepository)(nil)
+	_ OIDCLoginStateRepository = (*MongoOIDCLoginStateRepository)(nil)
+)
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/db"
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoAppointmentRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoAppointmentRepository(database *db.Database) *MongoAppointmentRepository {
+	return &MongoAppointmentRepository{
+		collection: database.GetCollection("appointments"),
+	}
+}
+
+func (r *MongoAppointmentRepository) Create(ctx context.Context, appointment *models.Appointment) error {
+	result, err := r.collection.InsertOne(ctx, appointment)
+	if err != nil {
+		return writeError(err)
+	}
+
+	appointment.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *MongoAppointmentRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Appointment, error) {
+	var appointment models.Appointment
+	if err := findOne(ctx, r.collection, bson.M{"_id": id}, &appointment); err != nil {
+		return nil, err
+	}
+	return &appointment, nil
+}
+
+func (r *MongoAppointmentRepository) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.Appointment, error) {
+	return findAll[models.Appointment](ctx, r.collection, bson.M{"user_id": userID})
+}
+
+func (r *MongoAppointmentRepository) UpdateStatus(ctx context.Context, id primitive.ObjectID, status string) (string, error) {
+	var previous struct {
+		Status string `bson:"status"`
+	}
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{"$set": bson.M{"status": status, "updated_at": time.Now()}},
+		options.FindOneAndUpdate().
+			SetReturnDocument(options.Before).
+			SetProjection(bson.M{"status": 1}),
+	).Decode(&previous)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return previous.Status, nil
+}
+
+func (r *MongoAppointmentRepository) AnonymizeByUser(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{
+			"user_id":           primitive.NilObjectID,
+			"chat_session_id":   primitive.NilObjectID,
+			"symptoms":          "",
+			"ai_recommendation": "",
+			"updated_at":        time.Now(),
+		}},
+	)
+	return err
+}
+
+func (r *MongoAppointmentRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
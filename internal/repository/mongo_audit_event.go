@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/subhammahanty235/medai/internal/db"
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MongoAuditEventRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoAuditEventRepository(database *db.Database) *MongoAuditEventRepository {
+	return &MongoAuditEventRepository{
+		collection: database.GetCollection("audit_events"),
+	}
+}
+
+func (r *MongoAuditEventRepository) Insert(ctx context.Context, event *models.AuditEvent) error {
+	result, err := r.collection.InsertOne(ctx, event)
+	if err != nil {
+		return err
+	}
+
+	event.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
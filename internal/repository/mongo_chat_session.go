@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/db"
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoChatSessionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoChatSessionRepository(database *db.Database) *MongoChatSessionRepository {
+	return &MongoChatSessionRepository{
+		collection: database.GetCollection("chat_sessions"),
+	}
+}
+
+func (r *MongoChatSessionRepository) Create(ctx context.Context, session *models.ChatSession) error {
+	result, err := r.collection.InsertOne(ctx, session)
+	if err != nil {
+		return writeError(err)
+	}
+
+	session.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *MongoChatSessionRepository) FindByID(ctx context.Context, id, userID primitive.ObjectID) (*models.ChatSession, error) {
+	var session models.ChatSession
+	if err := findOne(ctx, r.collection, bson.M{"_id": id, "user_id": userID}, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *MongoChatSessionRepository) FindActive(ctx context.Context, userID primitive.ObjectID, doctorID string, dependentID primitive.ObjectID) (*models.ChatSession, error) {
+	dependentFilter := interface{}(dependentID)
+	if dependentID.IsZero() {
+		dependentFilter = bson.M{"$exists": false}
+	}
+
+	var session models.ChatSession
+	err := findOne(ctx, r.collection, bson.M{
+		"user_id":      userID,
+		"doctor_id":    doctorID,
+		"dependent_id": dependentFilter,
+		"status":       "active",
+	}, &session)
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (r *MongoChatSessionRepository) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.ChatSession, error) {
+	return findAll[models.ChatSession](ctx, r.collection, bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "updated_at", Value: -1}}))
+}
+
+func (r *MongoChatSessionRepository) ReserveMessageSeqs(ctx context.Context, id primitive.ObjectID, count int) (int64, error) {
+	var session struct {
+		MessageCount int64 `bson:"message_count"`
+	}
+	err := r.collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id},
+		bson.M{
+			"$inc": bson.M{"message_count": count},
+			"$set": bson.M{"updated_at": time.Now()},
+		},
+		options.FindOneAndUpdate().
+			SetReturnDocument(options.After).
+			SetProjection(bson.M{"message_count": 1}),
+	).Decode(&session)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return session.MessageCount - int64(count) + 1, nil
+}
+
+func (r *MongoChatSessionRepository) SetLastMessage(ctx context.Context, id primitive.ObjectID, preview models.MessagePreview) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "$or": bson.A{
+			bson.M{"last_message": bson.M{"$exists": false}},
+			bson.M{"last_message.seq": bson.M{"$lt": preview.Seq}},
+		}},
+		bson.M{"$set": bson.M{"last_message": preview}},
+	)
+	return err
+}
+
+func (r *MongoChatSessionRepository) UpdateStatus(ctx context.Context, id primitive.ObjectID, status string) error {
+	return updateOne(ctx, r.collection, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{"status": status, "updated_at": time.Now()},
+	})
+}
+
+func (r *MongoChatSessionRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
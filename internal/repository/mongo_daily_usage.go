@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/subhammahanty235/medai/internal/db"
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoDailyUsageRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoDailyUsageRepository(database *db.Database) *MongoDailyUsageRepository {
+	return &MongoDailyUsageRepository{
+		collection: database.GetCollection("daily_usage"),
+	}
+}
+
+func (r *MongoDailyUsageRepository) Increment(ctx context.Context, usage models.DailyUsage, kind string, limit int) (*models.DailyUsage, error) {
+	// The upsert only matches while the count is below the limit, or not
+	// counted yet when the day started with the other counter. At the
+	// limit it tries to insert a second document for the day, which the
+	// unique _id refuses. Two first requests of the day can also race to
+	// insert, so a duplicate is checked once more before refusing.
+	for attempt := 0; attempt < 2; attempt++ {
+		var counted models.DailyUsage
+		err := r.collection.FindOneAndUpdate(
+			ctx,
+			bson.M{"_id": usage.Key, "$or": bson.A{
+				bson.M{kind: bson.M{"$lt": limit}},
+				bson.M{kind: bson.M{"$exists": false}},
+			}},
+			bson.M{
+				"$inc": bson.M{kind: 1},
+				"$setOnInsert": bson.M{
+					"user_id":    usage.UserID,
+					"day":        usage.Day,
+					"expires_at": usage.ExpiresAt,
+				},
+			},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		).Decode(&counted)
+		if err == nil {
+			return &counted, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, err
+		}
+	}
+
+	return nil, ErrLimitReached
+}
+
+func (r *MongoDailyUsageRepository) Decrement(ctx context.Context, key, kind string) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": key, kind: bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{kind: -1}},
+	)
+	return err
+}
+
+func (r *MongoDailyUsageRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	// Keys start with the user ID, so the prefix match uses the _id index
+	_, err := r.collection.DeleteMany(ctx, bson.M{"_id": primitive.Regex{Pattern: "^" + userID.Hex() + ":"}})
+	return err
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/subhammahanty235/medai/internal/db"
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MongoDoctorRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoDoctorRepository(database *db.Database) *MongoDoctorRepository {
+	return &MongoDoctorRepository{
+		collection: database.GetCollection("doctors"),
+	}
+}
+
+func (r *MongoDoctorRepository) ListAI(ctx context.Context) ([]models.Doctor, error) {
+	return findAll[models.Doctor](ctx, r.collection, bson.M{"is_ai": true})
+}
+
+func (r *MongoDoctorRepository) FindByID(ctx context.Context, id string) (*models.Doctor, error) {
+	var doctor models.Doctor
+	if err := findOne(ctx, r.collection, bson.M{"_id": id}, &doctor); err != nil {
+		return nil, err
+	}
+	return &doctor, nil
+}
+
+type MongoRealDoctorRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoRealDoctorRepository(database *db.Database) *MongoRealDoctorRepository {
+	return &MongoRealDoctorRepository{
+		collection: database.GetCollection("real_doctors"),
+	}
+}
+
+func (r *MongoRealDoctorRepository) List(ctx context.Context) ([]models.RealDoctor, error) {
+	return findAll[models.RealDoctor](ctx, r.collection, bson.M{})
+}
+
+func (r *MongoRealDoctorRepository) ListBySpecialty(ctx context.Context, specialty string) ([]models.RealDoctor, error) {
+	return findAll[models.RealDoctor](ctx, r.collection, bson.M{"specialty": specialty})
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/db"
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoHealthProfileRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoHealthProfileRepository(database *db.Database) *MongoHealthProfileRepository {
+	return &MongoHealthProfileRepository{
+		collection: database.GetCollection("health_profiles"),
+	}
+}
+
+func (r *MongoHealthProfileRepository) FindByUser(ctx context.Context, userID primitive.ObjectID) (*models.HealthProfile, error) {
+	var profile models.HealthProfile
+	if err := findOne(ctx, r.collection, bson.M{"user_id": userID}, &profile); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (r *MongoHealthProfileRepository) UpdateSelf(ctx context.Context, userID primitive.ObjectID, self models.HealthDetails, consent bool, consentUpdatedAt time.Time) error {
+	now := time.Now()
+	set := bson.M{
+		"self":               self,
+		"ai_context_consent": consent,
+		"updated_at":         now,
+	}
+	if !consentUpdatedAt.IsZero() {
+		set["consent_updated_at"] = consentUpdatedAt
+	}
+
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"user_id": userID},
+		bson.M{
+			"$set":         set,
+			"$setOnInsert": bson.M{"dependents": []models.Dependent{}, "created_at": now},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *MongoHealthProfileRepository) AddDependent(ctx context.Context, userID primitive.ObjectID, dependent models.Dependent) error {
+	now := time.Now()
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"user_id": userID},
+		bson.M{
+			"$push":        bson.M{"dependents": dependent},
+			"$set":         bson.M{"updated_at": now},
+			"$setOnInsert": bson.M{"self": emptyHealthDetails(), "ai_context_consent": false, "created_at": now},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *MongoHealthProfileRepository) UpdateDependent(ctx context.Context, userID primitive.ObjectID, dependent models.Dependent) error {
+	return updateOne(
+		ctx,
+		r.collection,
+		bson.M{"user_id": userID, "dependents._id": dependent.ID},
+		bson.M{"$set": bson.M{"dependents.$": dependent, "updated_at": time.Now()}},
+	)
+}
+
+func (r *MongoHealthProfileRepository) RemoveDependent(ctx context.Context, userID, dependentID primitive.ObjectID) error {
+	return updateOne(
+		ctx,
+		r.collection,
+		bson.M{"user_id": userID, "dependents._id": dependentID},
+		bson.M{
+			"$pull": bson.M{"dependents": bson.M{"_id": dependentID}},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+}
+
+func (r *MongoHealthProfileRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+// emptyHealthDetails stores empty lists rather than null so clients can rely
+// on arrays.
+func emptyHealthDetails() models.HealthDetails {
+	return models.HealthDetails{
+		Allergies:          []string{},
+		ChronicConditions:  []string{},
+		CurrentMedications: []models.Medication{},
+	}
+}
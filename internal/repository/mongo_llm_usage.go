@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/db"
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MongoLLMUsageRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoLLMUsageRepository(database *db.Database) *MongoLLMUsageRepository {
+	return &MongoLLMUsageRepository{
+		collection: database.GetCollection("llm_usage"),
+	}
+}
+
+func (r *MongoLLMUsageRepository) Insert(ctx context.Context, usage *models.LLMUsage) error {
+	result, err := r.collection.InsertOne(ctx, usage)
+	if err != nil {
+		return err
+	}
+
+	usage.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *MongoLLMUsageRepository) Summarize(ctx context.Context, groupBy string, filter UsageFilter, limit int) ([]models.UsageSummary, error) {
+	var key any
+	sort := bson.D{{Key: "cost_usd", Value: -1}, {Key: "_id", Value: 1}}
+	switch groupBy {
+	case "day":
+		key = bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$created_at"}}
+		sort = bson.D{{Key: "_id", Value: 1}}
+	case "user":
+		key = bson.M{"$toString": "$user_id"}
+	case "persona":
+		key = "$persona"
+	default:
+		return nil, fmt.Errorf("unknown usage grouping %q", groupBy)
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: usageMatch(filter)}},
+		{{Key: "$group", Value: bson.M{
+			"_id":               key,
+			"calls":             bson.M{"$sum": 1},
+			"failed_calls":      bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$outcome", "error"}}, 1, 0}}},
+			"prompt_tokens":     bson.M{"$sum": "$prompt_tokens"},
+			"completion_tokens": bson.M{"$sum": "$completion_tokens"},
+			"cost_usd":          bson.M{"$sum": "$cost_usd"},
+			"avg_latency_ms":    bson.M{"$avg": "$latency_ms"},
+		}}},
+		{{Key: "$sort", Value: sort}},
+		{{Key: "$limit", Value: limit}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	summaries := []models.UsageSummary{}
+	if err := cursor.All(ctx, &summaries); err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+func (r *MongoLLMUsageRepository) TotalCost(ctx context.Context, userID primitive.ObjectID, since time.Time) (float64, error) {
+	cursor, err := r.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userID, "created_at": bson.M{"$gte": since}}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "cost_usd": bson.M{"$sum": "$cost_usd"}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var totals []struct {
+		CostUSD float64 `bson:"cost_usd"`
+	}
+	if err := cursor.All(ctx, &totals); err != nil || len(totals) == 0 {
+		return 0, err
+	}
+	return totals[0].CostUSD, nil
+}
+
+func usageMatch(filter UsageFilter) bson.M {
+	match := bson.M{"created_at": bson.M{"$gte": filter.From, "$lt": filter.To}}
+	if !filter.UserID.IsZero() {
+		match["user_id"] = filter.UserID
+	}
+	if filter.Persona != "" {
+		match["persona"] = filter.Persona
+	}
+	return match
+}
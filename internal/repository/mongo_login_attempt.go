@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/db"
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoLoginAttemptRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoLoginAttemptRepository(database *db.Database) *MongoLoginAttemptRepository {
+	return &MongoLoginAttemptRepository{
+		collection: database.GetCollection("login_attempts"),
+	}
+}
+
+func (r *MongoLoginAttemptRepository) Find(ctx context.Context, key string) (*models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	if err := findOne(ctx, r.collection, bson.M{"_id": key}, &attempt); err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+func (r *MongoLoginAttemptRepository) RecordAttempt(ctx context.Context, key string, now, expiresAt time.Time) (*models.LoginAttempt, error) {
+	// The upsert only matches while the key is not locked. When locked it
+	// tries to insert a second document with the key, which the unique _id
+	// refuses. Two first attempts can also race to insert, so a duplicate
+	// is checked once more before refusing.
+	for attempt := 0; attempt < 2; attempt++ {
+		var before models.LoginAttempt
+		err := r.collection.FindOneAndUpdate(
+			ctx,
+			bson.M{"_id": key, "locked_until": bson.M{"$not": bson.M{"$gt": now}}},
+			bson.M{
+				"$inc":         bson.M{"failures": 1},
+				"$set":         bson.M{"last_failure": now, "expires_at": expiresAt},
+				"$setOnInsert": bson.M{"window_start": now},
+			},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before),
+		).Decode(&before)
+		if err == nil {
+			return &before, nil
+		}
+		// Inserted, so there was nothing before
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return &models.LoginAttempt{Key: key}, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return nil, err
+		}
+	}
+
+	locked, err := r.Find(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return locked, ErrLocked
+}
+
+func (r *MongoLoginAttemptRepository) Decrement(ctx context.Context, key string) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": key, "failures": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"failures": -1}},
+	)
+	return err
+}
+
+func (r *MongoLoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	return updateOne(ctx, r.collection, bson.M{"_id": key}, bson.M{"$set": bson.M{"failures": 0, "locked_until": until}})
+}
+
+func (r *MongoLoginAttemptRepository) RestartWindow(ctx context.Context, key string, now time.Time) error {
+	return updateOne(ctx, r.collection, bson.M{"_id": key}, bson.M{"$set": bson.M{"failures": 1, "window_start": now}})
+}
+
+func (r *MongoLoginAttemptRepository) Delete(ctx context.Context, key string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
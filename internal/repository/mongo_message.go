@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/db"
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoMessageRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoMessageRepository(database *db.Database) *MongoMessageRepository {
+	return &MongoMessageRepository{
+		collection: database.GetCollection("messages"),
+	}
+}
+
+func (r *MongoMessageRepository) Insert(ctx context.Context, messages []models.Message) error {
+	docs := make([]interface{}, len(messages))
+	for i := range messages {
+		docs[i] = messages[i]
+	}
+
+	_, err := r.collection.InsertMany(ctx, docs)
+	return writeError(err)
+}
+
+func (r *MongoMessageRepository) List(ctx context.Context, sessionID primitive.ObjectID, before int64, limit int) ([]models.Message, error) {
+	filter := bson.M{"session_id": sessionID}
+	if before > 0 {
+		filter["seq"] = bson.M{"$lt": before}
+	}
+
+	// Take the latest messages of the page, then return them in order
+	opts := options.Find().SetSort(bson.D{{Key: "seq", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(int64(limit))
+	}
+
+	messages, err := findAll[models.Message](ctx, r.collection, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Reverse(messages)
+	return messages, nil
+}
+
+func (r *MongoMessageRepository) FindAttachment(ctx context.Context, sessionID primitive.ObjectID, checksum string) (*models.Attachment, error) {
+	var message models.Message
+	err := findOne(ctx, r.collection, bson.M{"session_id": sessionID, "attachments.checksum": checksum}, &message,
+		options.FindOne().SetProjection(bson.M{"attachments.$": 1}))
+	if err != nil {
+		return nil, err
+	}
+	if len(message.Attachments) == 0 {
+		return nil, ErrNotFound
+	}
+	return &message.Attachments[0], nil
+}
+
+func (r *MongoMessageRepository) FindProcessedAttachment(ctx context.Context, sessionID primitive.ObjectID, key string) (*models.Attachment, error) {
+	var message models.Message
+	err := findOne(ctx, r.collection,
+		bson.M{"session_id": sessionID, "attachments": bson.M{"$elemMatch": bson.M{"key": key, "status": bson.M{"$ne": "pending"}}}},
+		&message, options.FindOne().SetProjection(bson.M{"attachments.$": 1}))
+	if err != nil {
+		return nil, err
+	}
+	if len(message.Attachments) == 0 {
+		return nil, ErrNotFound
+	}
+	return &message.Attachments[0], nil
+}
+
+func (r *MongoMessageRepository) ListWithPendingAttachments(ctx context.Context) ([]models.Message, error) {
+	return findAll[models.Message](ctx, r.collection, bson.M{"attachments.status": "pending"})
+}
+
+func (r *MongoMessageRepository) UpdateAttachments(ctx context.Context, sessionID primitive.ObjectID, key, status string, variants []models.ImageVariant) error {
+	set := bson.M{"attachments.$[a].status": status}
+	if variants != nil {
+		set["attachments.$[a].variants"] = variants
+	}
+
+	result, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"session_id": sessionID, "attachments.key": key},
+		bson.M{"$set": set},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+			bson.M{"a.key": key},
+		}}),
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *MongoMessageRepository) DeleteBySessions(ctx context.Context, sessionIDs []primitive.ObjectID) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+
+	_, err := r.collection.DeleteMany(ctx, bson.M{"session_id": bson.M{"$in": sessionIDs}})
+	return err
+}
+
+func (r *MongoMessageRepository) FindPendingReply(ctx context.Context, sessionID primitive.ObjectID) (*models.Message, error) {
+	var message models.Message
+	err := findOne(ctx, r.collection, bson.M{"session_id": sessionID, "reply_status": "pending"}, &message,
+		options.FindOne().SetSort(bson.D{{Key: "seq", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (r *MongoMessageRepository) ClaimReply(ctx context.Context, id primitive.ObjectID, claim string, now, until time.Time) error {
+	return updateOne(ctx, r.collection,
+		bson.M{
+			"_id":          id,
+			"reply_status": "pending",
+			"$or": bson.A{
+				bson.M{"reply_claimed_until": bson.M{"$exists": false}},
+				bson.M{"reply_claimed_until": bson.M{"$lte": now}},
+			},
+		},
+		bson.M{"$set": bson.M{"reply_claim": claim, "reply_claimed_until": until}},
+	)
+}
+
+func (r *MongoMessageRepository) ReleaseReply(ctx context.Context, id primitive.ObjectID, claim string) error {
+	return updateOne(ctx, r.collection,
+		bson.M{"_id": id, "reply_claim": claim},
+		bson.M{"$unset": bson.M{"reply_claim": "", "reply_claimed_until": ""}},
+	)
+}
+
+func (r *MongoMessageRepository) CompleteReplies(ctx context.Context, sessionID primitive.ObjectID, seq int64) error {
+	_, err := r.collection.UpdateMany(
+		ctx,
+		bson.M{"session_id": sessionID, "reply_status": "pending", "seq": bson.M{"$lte": seq}},
+		bson.M{"$unset": bson.M{"reply_status": "", "reply_claim": "", "reply_claimed_until": ""}},
+	)
+	return err
+}
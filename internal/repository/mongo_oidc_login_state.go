@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/subhammahanty235/medai/internal/db"
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type MongoOIDCLoginStateRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoOIDCLoginStateRepository(database *db.Database) *MongoOIDCLoginStateRepository {
+	return &MongoOIDCLoginStateRepository{
+		collection: database.GetCollection("oidc_login_states"),
+	}
+}
+
+func (r *MongoOIDCLoginStateRepository) Create(ctx context.Context, state *models.OIDCLoginState) error {
+	_, err := r.collection.InsertOne(ctx, state)
+	return writeError(err)
+}
+
+func (r *MongoOIDCLoginStateRepository) Take(ctx context.Context, state, provider string) (*models.OIDCLoginState, error) {
+	var loginState models.OIDCLoginState
+	err := r.collection.FindOneAndDelete(ctx, bson.M{"_id": state, "provider": provider}).Decode(&loginState)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &loginState, nil
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/subhammahanty235/medai/internal/db"
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoUsageBudgetRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoUsageBudgetRepository(database *db.Database) *MongoUsageBudgetRepository {
+	return &MongoUsageBudgetRepository{
+		collection: database.GetCollection("usage_budgets"),
+	}
+}
+
+func (r *MongoUsageBudgetRepository) FindByUser(ctx context.Context, userID primitive.ObjectID) (*models.UsageBudget, error) {
+	var budget models.UsageBudget
+	if err := findOne(ctx, r.collection, bson.M{"_id": userID}, &budget); err != nil {
+		return nil, err
+	}
+	return &budget, nil
+}
+
+func (r *MongoUsageBudgetRepository) Save(ctx context.Context, budget *models.UsageBudget) error {
+	_, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": budget.UserID},
+		bson.M{"$set": bson.M{"monthly_usd": budget.MonthlyUSD, "updated_at": budget.UpdatedAt}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (r *MongoUsageBudgetRepository) DeleteByUser(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": userID})
+	return err
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/db"
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoUserRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoUserRepository(database *db.Database) *MongoUserRepository {
+	return &MongoUserRepository{
+		collection: database.GetCollection("users"),
+	}
+}
+
+func (r *MongoUserRepository) Create(ctx context.Context, user *models.User) error {
+	result, err := r.collection.InsertOne(ctx, user)
+	if err != nil {
+		return writeError(err)
+	}
+
+	user.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *MongoUserRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	var user models.User
+	if err := findOne(ctx, r.collection, bson.M{"_id": id}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *MongoUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := findOne(ctx, r.collection, bson.M{"email": email}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *MongoUserRepository) FindByIdentity(ctx context.Context, provider, subject string) (*models.User, error) {
+	var user models.User
+	err := findOne(ctx, r.collection, bson.M{
+		"identities": bson.M{"$elemMatch": bson.M{"provider": provider, "subject": subject}},
+	}, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *MongoUserRepository) FindByEmailChangeToken(ctx context.Context, tokenHash string, now time.Time) (*models.User, error) {
+	var user models.User
+	err := findOne(ctx, r.collection, bson.M{
+		"email_change_token_hash": tokenHash,
+		"email_change_expires_at": bson.M{"$gt": now},
+	}, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *MongoUserRepository) GetTokenVersion(ctx context.Context, id primitive.ObjectID) (int, error) {
+	var user models.User
+	err := findOne(ctx, r.collection, bson.M{"_id": id}, &user,
+		options.FindOne().SetProjection(bson.M{"token_version": 1}))
+	if err != nil {
+		return 0, err
+	}
+	return user.TokenVersion, nil
+}
+
+func (r *MongoUserRepository) UpdateName(ctx context.Context, id primitive.ObjectID, name string) error {
+	return r.set(ctx, id, bson.M{"name": name})
+}
+
+func (r *MongoUserRepository) UpdatePassword(ctx context.Context, id primitive.ObjectID, passwordHash string) error {
+	return updateOne(ctx, r.collection, bson.M{"_id": id}, bson.M{
+		"$set": bson.M{"password": passwordHash, "updated_at": time.Now()},
+		"$inc": bson.M{"token_version": 1},
+	})
+}
+
+func (r *MongoUserRepository) SetPendingEmail(ctx context.Context, id primitive.ObjectID, email, tokenHash string, expiresAt time.Time) error {
+	return r.set(ctx, id, bson.M{
+		"pending_email":           email,
+		"email_change_token_hash": tokenHash,
+		"email_change_expires_at": expiresAt,
+	})
+}
+
+func (r *MongoUserRepository) ApplyEmailChange(ctx context.Context, id primitive.ObjectID, email string) error {
+	return updateOne(ctx, r.collection, bson.M{"_id": id}, bson.M{
+		"$set":   bson.M{"email": email, "email_verified": true, "updated_at": time.Now()},
+		"$inc":   bson.M{"token_version": 1},
+		"$unset": bson.M{"pending_email": "", "email_change_token_hash": "", "email_change_expires_at": ""},
+	})
+}
+
+func (r *MongoUserRepository) AddIdentity(ctx context.Context, id primitive.ObjectID, identity models.ExternalIdentity) error {
+	return updateOne(ctx, r.collection, bson.M{"_id": id}, bson.M{
+		"$push": bson.M{"identities": identity},
+		"$set":  bson.M{"updated_at": time.Now()},
+	})
+}
+
+func (r *MongoUserRepository) SetProviderSignIn(ctx context.Context, id primitive.ObjectID, at time.Time) error {
+	return r.set(ctx, id, bson.M{"provider_sign_in_at": at})
+}
+
+func (r *MongoUserRepository) SetMFA(ctx context.Context, id primitive.ObjectID, mfa models.MFASettings) error {
+	return r.set(ctx, id, bson.M{"mfa": mfa})
+}
+
+func (r *MongoUserRepository) SetPendingTOTPSecret(ctx context.Context, id primitive.ObjectID, secret string) error {
+	return r.set(ctx, id, bson.M{"mfa.pending_totp_secret": secret})
+}
+
+func (r *MongoUserRepository) SetRecoveryCodes(ctx context.Context, id primitive.ObjectID, hashes []string) error {
+	return r.set(ctx, id, bson.M{"mfa.recovery_codes": hashes})
+}
+
+func (r *MongoUserRepository) ConsumeTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) (bool, error) {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "mfa.last_used_step": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{"mfa.last_used_step": step}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+func (r *MongoUserRepository) ConsumeRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) (bool, error) {
+	result, err := r.collection.UpdateOne(
+		ctx,
+		bson.M{"_id": id, "mfa.recovery_codes": hash},
+		bson.M{"$pull": bson.M{"mfa.recovery_codes": hash}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+func (r *MongoUserRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	return err
+}
+
+func (r *MongoUserRepository) set(ctx context.Context, id primitive.ObjectID, fields bson.M) error {
+	fields["updated_at"] = time.Now()
+	return updateOne(ctx, r.collection, bson.M{"_id": id}, bson.M{"$set": fields})
+}
@@ -0,0 +1,259 @@
+// Package repository provides storage for the application's aggregates
+// behind interfaces, so services do not depend on MongoDB directly. Each
+// repository has a MongoDB implementation and a thread-safe in-memory one
+// that is useful for tests and local tooling.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	// ErrNotFound is returned when no document matches a lookup or update.
+	ErrNotFound = errors.New("not found")
+	// ErrDuplicate is returned when a write would violate a uniqueness
+	// constraint, such as a second account with the same email.
+	ErrDuplicate = errors.New("duplicate")
+	// ErrLimitReached is returned when a counter is already at its limit.
+	ErrLimitReached = errors.New("limit reached")
+	// ErrLocked is returned when a locked key refuses a login attempt.
+	ErrLocked = errors.New("locked")
+)
+
+type UserRepository interface {
+	// Create stores a new user and sets its ID. It returns ErrDuplicate if
+	// the email is taken.
+	Create(ctx context.Context, user *models.User) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	FindByIdentity(ctx context.Context, provider, subject string) (*models.User, error)
+	// FindByEmailChangeToken finds the user with an unexpired pending email
+	// change for the token hash.
+	FindByEmailChangeToken(ctx context.Context, tokenHash string, now time.Time) (*models.User, error)
+	GetTokenVersion(ctx context.Context, id primitive.ObjectID) (int, error)
+
+	UpdateName(ctx context.Context, id primitive.ObjectID, name string) error
+	// UpdatePassword sets the password hash and bumps the token version.
+	UpdatePassword(ctx context.Context, id primitive.ObjectID, passwordHash string) error
+	SetPendingEmail(ctx context.Context, id primitive.ObjectID, email, tokenHash string, expiresAt time.Time) error
+	// ApplyEmailChange sets the email, marks it verified, clears the pending
+	// change and bumps the token version.
+	ApplyEmailChange(ctx context.Context, id primitive.ObjectID, email string) error
+	AddIdentity(ctx context.Context, id primitive.ObjectID, identity models.ExternalIdentity) error
+	SetProviderSignIn(ctx context.Context, id primitive.ObjectID, at time.Time) error
+
+	SetMFA(ctx context.Context, id primitive.ObjectID, mfa models.MFASettings) error
+	SetPendingTOTPSecret(ctx context.Context, id primitive.ObjectID, secret string) error
+	SetRecoveryCodes(ctx context.Context, id primitive.ObjectID, hashes []string) error
+	// ConsumeTOTPStep records step as used unless it or a later step was
+	// used before, and reports whether it was recorded.
+	ConsumeTOTPStep(ctx context.Context, id primitive.ObjectID, step int64) (bool, error)
+	// ConsumeRecoveryCode removes the recovery code hash and reports whether
+	// it was present.
+	ConsumeRecoveryCode(ctx context.Context, id primitive.ObjectID, hash string) (bool, error)
+
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+// DoctorRepository holds the AI doctors.
+type DoctorRepository interface {
+	ListAI(ctx context.Context) ([]models.Doctor, error)
+	FindByID(ctx context.Context, id string) (*models.Doctor, error)
+}
+
+type RealDoctorRepository interface {
+	List(ctx context.Context) ([]models.RealDoctor, error)
+	ListBySpecialty(ctx context.Context, specialty string) ([]models.RealDoctor, error)
+}
+
+type ChatSessionRepository interface {
+	// Create stores a new session and sets its ID. It returns ErrDuplicate
+	// if the session is active and the user already has an active session
+	// with the doctor about the same patient.
+	Create(ctx context.Context, session *models.ChatSession) error
+	FindByID(ctx context.Context, id, userID primitive.ObjectID) (*models.ChatSession, error)
+	// FindActive returns the active session of a user with a doctor about
+	// the user themselves (dependentID is NilObjectID) or a dependent.
+	FindActive(ctx context.Context, userID primitive.ObjectID, doctorID string, dependentID primitive.ObjectID) (*models.ChatSession, error)
+	ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.ChatSession, error)
+
+	// ReserveMessageSeqs allocates count consecutive message sequence
+	// numbers in the session and returns the first. Concurrent callers get
+	// distinct numbers.
+	ReserveMessageSeqs(ctx context.Context, id primitive.ObjectID, count int) (int64, error)
+	// SetLastMessage stores the preview unless a later message's preview is
+	// already stored.
+	SetLastMessage(ctx context.Context, id primitive.ObjectID, preview models.MessagePreview) error
+	UpdateStatus(ctx context.Context, id primitive.ObjectID, status string) error
+
+	DeleteByUser(ctx context.Context, userID primitive.ObjectID) error
+}
+
+// MessageRepository holds the messages of chat sessions.
+type MessageRepository interface {
+	// Insert stores messages, which must have their session and sequence
+	// number set.
+	Insert(ctx context.Context, messages []models.Message) error
+	// List returns up to limit messages of a session with a sequence number
+	// below before, oldest first. A before of 0 starts from the latest
+	// message and a limit of 0 returns all.
+	List(ctx context.Context, sessionID primitive.ObjectID, before int64, limit int) ([]models.Message, error)
+	// FindAttachment returns an attachment shared in the session with the
+	// checksum.
+	FindAttachment(ctx context.Context, sessionID primitive.ObjectID, checksum string) (*models.Attachment, error)
+	// FindProcessedAttachment returns an attachment in the session stored
+	// under key that is no longer pending, or ErrNotFound.
+	FindProcessedAttachment(ctx context.Context, sessionID primitive.ObjectID, key string) (*models.Attachment, error)
+	// ListWithPendingAttachments returns messages with attachments still
+	// waiting for background processing.
+	ListWithPendingAttachments(ctx context.Context) ([]models.Message, error)
+	// UpdateAttachments sets the status, and the variants if not nil, of
+	// every attachment in the session stored under key. It returns
+	// ErrNotFound if there is none.
+	UpdateAttachments(ctx context.Context, sessionID primitive.ObjectID, key, status string, variants []models.ImageVariant) error
+	DeleteBySessions(ctx context.Context, sessionIDs []primitive.ObjectID) error
+
+	// FindPendingReply returns the latest message of the session still
+	// waiting for a reply.
+	FindPendingReply(ctx context.Context, sessionID primitive.ObjectID) (*models.Message, error)
+	// ClaimReply reserves generating the reply to a pending message under
+	// claim until the given time. It returns ErrNotFound if the message is
+	// not pending or another claim has not expired by now.
+	ClaimReply(ctx context.Context, id primitive.ObjectID, claim string, now, until time.Time) error
+	// ReleaseReply gives up a claim, leaving the message pending. It
+	// returns ErrNotFound if the claim is no longer held.
+	ReleaseReply(ctx context.Context, id primitive.ObjectID, claim string) error
+	// CompleteReplies marks the pending messages of the session up to seq
+	// as answered.
+	CompleteReplies(ctx context.Context, sessionID primitive.ObjectID, seq int64) error
+}
+
+type AppointmentRepository interface {
+	// Create stores a new appointment and sets its ID.
+	Create(ctx context.Context, appointment *models.Appointment) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Appointment, error)
+	ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.Appointment, error)
+	// UpdateStatus sets the status of an appointment and returns the status
+	// it had before.
+	UpdateStatus(ctx context.Context, id primitive.ObjectID, status string) (string, error)
+	// AnonymizeByUser detaches the user's appointments from them and clears
+	// their personal details.
+	AnonymizeByUser(ctx context.Context, userID primitive.ObjectID) error
+	DeleteByUser(ctx context.Context, userID primitive.ObjectID) error
+}
+
+// HealthProfileRepository holds the health profiles, one per user.
+type HealthProfileRepository interface {
+	FindByUser(ctx context.Context, userID primitive.ObjectID) (*models.HealthProfile, error)
+	// UpdateSelf sets the user's own details and consent, creating the
+	// profile if the user has none. consentUpdatedAt is stored unless zero.
+	UpdateSelf(ctx context.Context, userID primitive.ObjectID, self models.HealthDetails, consent bool, consentUpdatedAt time.Time) error
+	// AddDependent appends a dependent, creating the profile, with empty
+	// details of the user's own and no consent, if the user has none.
+	AddDependent(ctx context.Context, userID primitive.ObjectID, dependent models.Dependent) error
+	// UpdateDependent replaces the dependent with the same ID. It returns
+	// ErrNotFound if the user has no such dependent.
+	UpdateDependent(ctx context.Context, userID primitive.ObjectID, dependent models.Dependent) error
+	// RemoveDependent returns ErrNotFound if the user has no such
+	// dependent.
+	RemoveDependent(ctx context.Context, userID, dependentID primitive.ObjectID) error
+	DeleteByUser(ctx context.Context, userID primitive.ObjectID) error
+}
+
+// LoginAttemptRepository counts failed logins by key, such as an email or a
+// client IP. Attempts are counted before their outcome is known, so that
+// concurrent attempts cannot all pass a check made before any of them
+// failed.
+type LoginAttemptRepository interface {
+	Find(ctx context.Context, key string) (*models.LoginAttempt, error)
+	// RecordAttempt counts an attempt at now, starting the window of a key
+	// without attempts, and returns the attempt as it was before; a key
+	// without attempts is returned empty. A key locked at now is left
+	// unchanged and returned with ErrLocked.
+	RecordAttempt(ctx context.Context, key string, now, expiresAt time.Time) (*models.LoginAttempt, error)
+	// Decrement takes back an attempt that did not fail, unless the count
+	// is zero.
+	Decrement(ctx context.Context, key string) error
+	// Lock clears the failures of the key and locks it until the given
+	// time.
+	Lock(ctx context.Context, key string, until time.Time) error
+	// RestartWindow starts a new window at now with a single failure.
+	RestartWindow(ctx context.Context, key string, now time.Time) error
+	Delete(ctx context.Context, key string) error
+}
+
+type AuditEventRepository interface {
+	Insert(ctx context.Context, event *models.AuditEvent) error
+}
+
+// DailyUsageRepository counts the actions of users per day.
+type DailyUsageRepository interface {
+	// Increment adds one to the counter of kind, messages or uploads, of
+	// the usage document with usage's key, creating it from usage if
+	// missing. It returns the updated document, or ErrLimitReached if the
+	// counter is at limit already.
+	Increment(ctx context.Context, usage models.DailyUsage, kind string, limit int) (*models.DailyUsage, error)
+	// Decrement takes one off the counter unless it is zero.
+	Decrement(ctx context.Context, key, kind string) error
+	DeleteByUser(ctx context.Context, userID primitive.ObjectID) error
+}
+
+// UsageFilter selects LLM calls made between From, included, and To.
+// UserID and Persona narrow them unless zero.
+type UsageFilter struct {
+	From    time.Time
+	To      time.Time
+	UserID  primitive.ObjectID
+	Persona string
+}
+
+// LLMUsageRepository records LLM calls.
+type LLMUsageRepository interface {
+	Insert(ctx context.Context, usage *models.LLMUsage) error
+	// Summarize totals the calls matching filter grouped by day (UTC,
+	// YYYY-MM-DD), user or persona. Days are returned in order, users and
+	// personas largest cost first; at most limit summaries are returned.
+	Summarize(ctx context.Context, groupBy string, filter UsageFilter, limit int) ([]models.UsageSummary, error)
+	// TotalCost sums the cost of the user's calls since the given time.
+	TotalCost(ctx context.Context, userID primitive.ObjectID, since time.Time) (float64, error)
+}
+
+// UsageBudgetRepository holds the LLM budgets set for individual users.
+type UsageBudgetRepository interface {
+	FindByUser(ctx context.Context, userID primitive.ObjectID) (*models.UsageBudget, error)
+	// Save creates or replaces the budget of the user.
+	Save(ctx context.Context, budget *models.UsageBudget) error
+	DeleteByUser(ctx context.Context, userID primitive.ObjectID) error
+}
+
+// OIDCLoginStateRepository holds the state of sign-ins in progress with an
+// OIDC provider.
+type OIDCLoginStateRepository interface {
+	Create(ctx context.Context, state *models.OIDCLoginState) error
+	// Take removes and returns the state of a sign-in with the provider,
+	// so that each can be completed once.
+	Take(ctx context.Context, state, provider string) (*models.OIDCLoginState, error)
+}
+
+// Repositories bundles one implementation of every repository.
+type Repositories struct {
+	Users           UserRepository
+	Doctors         DoctorRepository
+	RealDoctors     RealDoctorRepository
+	ChatSessions    ChatSessionRepository
+	Messages        MessageRepository
+	Appointments    AppointmentRepository
+	HealthProfiles  HealthProfileRepository
+	LoginAttempts   LoginAttemptRepository
+	AuditEvents     AuditEventRepository
+	DailyUsage      DailyUsageRepository
+	LLMUsage        LLMUsageRepository
+	UsageBudgets    UsageBudgetRepository
+	OIDCLoginStates OIDCLoginStateRepository
+}
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/repository"
+	"github.com/subhammahanty235/medai/internal/storage"
+	"github.com/subhammahanty235/medai/internal/tracing"
+	"github.com/subhammahanty235/medai/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const emailChangeTTL = 24 * time.Hour
+
+// providerReauthWindow is how recently an account without a password must
+// have signed in through a provider to confirm a sensitive change.
+const providerReauthWindow = 10 * time.Minute
+
+var (
+	errCurrentPassword = Invalid("incorrect_password", "current password is incorrect",
+		models.FieldError{Field: "current_password", Code: "incorrect", Message: "current password is incorrect"})
+	ErrInvalidVerificationLink = Invalid("invalid_verification_link", "invalid or expired verification link")
+	ErrMailUnavailable         = Unavailable("mail_unavailable", "email delivery is not configured", nil)
+	// ErrReauthenticationRequired asks an account without a password to sign
+	// in through its provider again before the change.
+	ErrReauthenticationRequired = Forbidden("reauthentication_required", "please sign in with your provider again to confirm this change")
+)
+
+// Appointment retention modes applied when an account is deleted.
+const (
+	AppointmentRetentionDelete    = "delete"
+	AppointmentRetentionAnonymize = "anonymize"
+)
+
+// RetentionPolicy decides what happens to a user's data on account deletion.
+// Chat sessions and uploaded images are always removed; appointments may be
+// kept without personal details for the treating practice's records.
+type RetentionPolicy struct {
+	Appointments string
+}
+
+type AccountService struct {
+	users                repository.UserRepository
+	sessions             repository.ChatSessionRepository
+	messages             repository.MessageRepository
+	appointments         repository.AppointmentRepository
+	authService          *AuthService
+	healthProfileService *HealthProfileService
+	usageService         *UsageService
+	quotaService         *QuotaService
+	storage              storage.Storage
+	mailer               utils.Mailer // nil when mail is off
+	audit                *AuditService
+	retention            RetentionPolicy
+	appBaseURL           string
+	logger               *slog.Logger
+}
+
+func NewAccountService(users repository.UserRepository, sessions repository.ChatSessionRepository, messages repository.MessageRepository, appointments repository.AppointmentRepository, authService *AuthService, healthProfileService *HealthProfileService, usageService *UsageService, quotaService *QuotaService, storage storage.Storage, mailer utils.Mailer, audit *AuditService, retention RetentionPolicy, appBaseURL string, logger *slog.Logger) *AccountService {
+	return &AccountService{
+		users:                users,
+		sessions:             sessions,
+		messages:             messages,
+		appointments:         appointments,
+		authService:          authService,
+		healthProfileService: healthProfileService,
+		usageService:         usageService,
+		quotaService:         quotaService,
+		storage:              storage,
+		mailer:               mailer,
+		audit:                audit,
+		retention:            retention,
+		appBaseURL:           appBaseURL,
+		logger:               logger,
+	}
+}
+
+func (s *AccountService) UpdateProfile(ctx context.Context, userID primitive.ObjectID, req models.UpdateProfileRequest) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "AccountService.UpdateProfile")
+	defer span.End()
+
+	if err := s.users.UpdateName(ctx, userID, req.Name); err != nil {
+		return nil, err
+	}
+
+	return s.authService.GetUserByID(ctx, userID)
+}
+
+// ChangePassword sets a new password and bumps the token version, which
+// signs out every other session. The returned token replaces the caller's.
+func (s *AccountService) ChangePassword(ctx context.Context, userID primitive.ObjectID, req models.ChangePasswordRequest) (*models.AuthResponse, error) {
+	ctx, span := tracing.Start(ctx, "AccountService.ChangePassword")
+	defer span.End()
+
+	user, err := s.authService.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := confirmIdentity(user, req.CurrentPassword, errCurrentPassword); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.users.UpdatePassword(ctx, userID, hashedPassword); err != nil {
+		return nil, err
+	}
+	user.TokenVersion++
+
+	return s.reissue(user)
+}
+
+// RequestEmailChange stores the new address as pending and mails it a
+// verification link. The current address stays active until verified. It
+// fails with ErrMailUnavailable when no mailer is configured.
+func (s *AccountService) RequestEmailChange(ctx context.Context, userID primitive.ObjectID, req models.ChangeEmailRequest) error {
+	ctx, span := tracing.Start(ctx, "AccountService.RequestEmailChange")
+	defer span.End()
+
+	user, err := s.authService.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := confirmIdentity(user, req.Password, errPasswordConfirmation); err != nil {
+		return err
+	}
+
+	if s.mailer == nil {
+		return ErrMailUnavailable
+	}
+	if err := s.checkEmailAvailable(ctx, req.NewEmail); err != nil {
+		return err
+	}
+
+	token, err := utils.RandomToken()
+	if err != nil {
+		return err
+	}
+
+	err = s.users.SetPendingEmail(ctx, userID, req.NewEmail, utils.HashToken(token), time.Now().Add(emailChangeTTL))
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", s.appBaseURL, token)
+	return s.mailer.Send(ctx, req.NewEmail, "Confirm your new email address",
+		fmt.Sprintf("Hi %s,\n\nOpen this link within 24 hours to confirm your new email address:\n%s\n", user.Name, link))
+}
+
+// VerifyEmailChange applies a pending email change. Tokens issued for the
+// old address are revoked.
+func (s *AccountService) VerifyEmailChange(ctx context.Context, req models.VerifyEmailChangeRequest) error {
+	ctx, span := tracing.Start(ctx, "AccountService.VerifyEmailChange")
+	defer span.End()
+
+	user, err := s.users.FindByEmailChangeToken(ctx, utils.HashToken(req.Token), time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrInvalidVerificationLink
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkEmailAvailable(ctx, user.PendingEmail); err != nil {
+		return err
+	}
+
+	err = s.users.ApplyEmailChange(ctx, user.ID, user.PendingEmail)
+	if errors.Is(err, repository.ErrDuplicate) {
+		return ErrEmailInUse
+	}
+	return err
+}
+
+// confirmIdentity guards sensitive changes against a stolen access token.
+// Password accounts confirm with their password, failing with mismatch;
+// accounts created through a sign-in provider have no password and must
+// have signed in through it recently instead.
+func confirmIdentity(user *models.User, password string, mismatch error) error {
+	if user.Password == "" {
+		if time.Since(user.ProviderSignInAt) > providerReauthWindow {
+			return ErrReauthenticationRequired
+		}
+		return nil
+	}
+
+	if !utils.CheckPasswordHash(password, user.Password) {
+		return mismatch
+	}
+	return nil
+}
+
+func (s *AccountService) checkEmailAvailable(ctx context.Context, email string) error {
+	_, err := s.users.FindByEmail(ctx, email)
+	if err == nil {
+		return ErrEmailInUse
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// DeleteAccount removes the user and their data according to the retention
+// policy. It is confirmed like an email change.
+func (s *AccountService) DeleteAccount(ctx context.Context, userID primitive.ObjectID, req models.DeleteAccountRequest) error {
+	ctx, span := tracing.Start(ctx, "AccountService.DeleteAccount")
+	defer span.End()
+
+	user, err := s.authService.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := confirmIdentity(user, req.Password, errPasswordConfirmation); err != nil {
+		return err
+	}
+
+	if err := s.deleteChatData(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.applyAppointmentRetention(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.healthProfileService.DeleteProfile(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.usageService.Forget(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.quotaService.Forget(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.authService.loginGuard.Forget(ctx, user.Email); err != nil {
+		return err
+	}
+
+	if err := s.users.Delete(ctx, userID); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, models.AuditEvent{
+		Type:    "account_deleted",
+		UserID:  userID,
+		Details: map[string]string{"appointments": s.retention.Appointments},
+	})
+
+	return nil
+}
+
+// deleteChatData removes the user's chat sessions and the images uploaded
+// in them. Storage failures are logged so a missing object cannot block the
+// deletion of the account itself.
+func (s *AccountService) deleteChatData(ctx context.Context, userID primitive.ObjectID) error {
+	sessions, err := s.sessions.ListByUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	sessionIDs := make([]primitive.ObjectID, 0, len(sessions))
+	for _, session := range sessions {
+		messages, err := s.messages.List(ctx, session.ID, 0, 0)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range attachmentKeys(messages) {
+			if err := s.storage.Delete(ctx, key); err != nil {
+				s.logger.ErrorContext(ctx, "Error deleting file of deleted session", "key", key, "session_id", session.ID.Hex(), "error", err)
+			}
+		}
+		sessionIDs = append(sessionIDs, session.ID)
+	}
+
+	if err := s.messages.DeleteBySessions(ctx, sessionIDs); err != nil {
+		return err
+	}
+
+	return s.sessions.DeleteByUser(ctx, userID)
+}
+
+func (s *AccountService) applyAppointmentRetention(ctx context.Context, userID primitive.ObjectID) error {
+	if s.retention.Appointments == AppointmentRetentionAnonymize {
+		return s.appointments.AnonymizeByUser(ctx, userID)
+	}
+	return s.appointments.DeleteByUser(ctx, userID)
+}
+
+func (s *AccountService) reissue(user *models.User) (*models.AuthResponse, error) {
+	token, err := utils.GenerateToken(user.ID, user.Email, userRole(user), user.TokenVersion, s.authService.jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AuthResponse{
+		Token: token,
+		User:  *user,
+	}, nil
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestChangePassword(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	user := s.register(t, "asha@example.com", "secret1")
+
+	_, err := s.account.ChangePassword(ctx, user.ID, models.ChangePasswordRequest{CurrentPassword: "wrong", NewPassword: "secret2"})
+	if errorCode(err) != "incorrect_password" {
+		t.Errorf("ChangePassword() with a wrong password error = %v, want incorrect_password", err)
+	}
+
+	changed, err := s.account.ChangePassword(ctx, user.ID, models.ChangePasswordRequest{CurrentPassword: "secret1", NewPassword: "secret2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed.Token == "" || changed.User.TokenVersion != user.TokenVersion+1 {
+		t.Errorf("ChangePassword() = %+v, want a new token with the version bumped", changed)
+	}
+
+	if _, err := s.auth.Login(ctx, models.LoginRequest{Email: "asha@example.com", Password: "secret1"}, "192.0.2.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login() with the old password error = %v, want %v", err, ErrInvalidCredentials)
+	}
+	if _, err := s.auth.Login(ctx, models.LoginRequest{Email: "asha@example.com", Password: "secret2"}, "192.0.2.1"); err != nil {
+		t.Errorf("Login() with the new password error = %v", err)
+	}
+}
+
+func TestChangePasswordWithoutPassword(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	user := s.createProviderUser(t, "asha@example.com", time.Now().Add(-time.Hour))
+	request := models.ChangePasswordRequest{NewPassword: "secret1"}
+
+	if _, err := s.account.ChangePassword(ctx, user.ID, request); !errors.Is(err, ErrReauthenticationRequired) {
+		t.Errorf("ChangePassword() after an old sign-in error = %v, want %v", err, ErrReauthenticationRequired)
+	}
+	if _, err := s.auth.Login(ctx, models.LoginRequest{Email: "asha@example.com", Password: "secret1"}, "192.0.2.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("Login() error = %v, want %v as no password was set", err, ErrInvalidCredentials)
+	}
+
+	if err := s.repos.Users.SetProviderSignIn(ctx, user.ID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.account.ChangePassword(ctx, user.ID, request); err != nil {
+		t.Fatalf("ChangePassword() after a recent sign-in error = %v", err)
+	}
+	if _, err := s.auth.Login(ctx, models.LoginRequest{Email: "asha@example.com", Password: "secret1"}, "192.0.2.1"); err != nil {
+		t.Errorf("Login() with the new password error = %v", err)
+	}
+}
+
+func TestChangeEmail(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	user := s.register(t, "asha@example.com", "secret1")
+	s.register(t, "taken@example.com", "secret1")
+
+	err := s.account.RequestEmailChange(ctx, user.ID, models.ChangeEmailRequest{NewEmail: "taken@example.com", Password: "secret1"})
+	if !errors.Is(err, ErrEmailInUse) {
+		t.Errorf("RequestEmailChange() to a used address error = %v, want %v", err, ErrEmailInUse)
+	}
+
+	err = s.account.RequestEmailChange(ctx, user.ID, models.ChangeEmailRequest{NewEmail: "asha@new.example.com", Password: "secret1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.mailer.sent) != 1 || s.mailer.sent[0].to != "asha@new.example.com" {
+		t.Fatalf("sent mail = %+v, want a link to the new address", s.mailer.sent)
+	}
+
+	if err := s.account.VerifyEmailChange(ctx, models.VerifyEmailChangeRequest{Token: "forged"}); !errors.Is(err, ErrInvalidVerificationLink) {
+		t.Errorf("VerifyEmailChange() with a forged token error = %v, want %v", err, ErrInvalidVerificationLink)
+	}
+	if err := s.account.VerifyEmailChange(ctx, models.VerifyEmailChangeRequest{Token: s.mailer.lastToken()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.auth.Login(ctx, models.LoginRequest{Email: "asha@new.example.com", Password: "secret1"}, "192.0.2.1"); err != nil {
+		t.Errorf("Login() with the new address error = %v", err)
+	}
+	if _, err := s.auth.Login(ctx, models.LoginRequest{Email: "asha@example.com", Password: "secret1"}, "192.0.2.1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login() with the old address error = %v, want %v", err, ErrInvalidCredentials)
+	}
+}
+
+func TestChangeEmailWithoutMailer(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	user := s.register(t, "asha@example.com", "secret1")
+	s.account.mailer = nil
+
+	err := s.account.RequestEmailChange(ctx, user.ID, models.ChangeEmailRequest{NewEmail: "asha@new.example.com", Password: "secret1"})
+	if !errors.Is(err, ErrMailUnavailable) {
+		t.Errorf("RequestEmailChange() without a mailer error = %v, want %v", err, ErrMailUnavailable)
+	}
+	if stored, _ := s.repos.Users.FindByID(ctx, user.ID); stored.PendingEmail != "" {
+		t.Errorf("pending email = %q, want none stored", stored.PendingEmail)
+	}
+}
+
+func TestChangeEmailWithoutPassword(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+
+	user := s.createProviderUser(t, "asha@example.com", time.Time{})
+	request := models.ChangeEmailRequest{NewEmail: "asha@new.example.com"}
+
+	if err := s.account.RequestEmailChange(ctx, user.ID, request); !errors.Is(err, ErrReauthenticationRequired) {
+		t.Errorf("RequestEmailChange() without a sign-in error = %v, want %v", err, ErrReauthenticationRequired)
+	}
+
+	if err := s.repos.Users.SetProviderSignIn(ctx, user.ID, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.account.RequestEmailChange(ctx, user.ID, request); !errors.Is(err, ErrReauthenticationRequired) {
+		t.Errorf("RequestEmailChange() after an old sign-in error = %v, want %v", err, ErrReauthenticationRequired)
+	}
+
+	if err := s.repos.Users.SetProviderSignIn(ctx, user.ID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.account.RequestEmailChange(ctx, user.ID, request); err != nil {
+		t.Errorf("RequestEmailChange() after a recent sign-in error = %v", err)
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	user := s.register(t, "asha@example.com", "secret1")
+	other := s.register(t, "ravi@example.com", "secret1")
+
+	session, err := s.chat.StartChatSession(ctx, user.ID, testDoctor.ID, primitive.NilObjectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.chat.SendMessage(ctx, session.ID, user.ID, "I have a mild headache", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.healthProfile.AddDependent(ctx, user.ID, models.DependentRequest{Name: "Mira", Relationship: "child"}); err != nil {
+		t.Fatal(err)
+	}
+	request := models.AppointmentRequest{RealDoctorID: testRealDoctor.ID.Hex(), AppointmentDate: time.Now().Add(time.Hour)}
+	if _, err := s.appointment.BookAppointment(ctx, user.ID, request, session.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.appointment.BookAppointment(ctx, other.ID, request, primitive.NilObjectID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.usage.SetBudget(ctx, user.ID, 5); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.quota.Consume(ctx, user.ID, "user", QuotaMessages); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.quota.Consume(ctx, other.ID, "user", QuotaMessages); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.account.DeleteAccount(ctx, user.ID, models.DeleteAccountRequest{Password: "wrong"}); errorCode(err) == "" {
+		t.Fatalf("DeleteAccount() with a wrong password error = %v, want a confirmation error", err)
+	}
+	if err := s.account.DeleteAccount(ctx, user.ID, models.DeleteAccountRequest{Password: "secret1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.auth.GetUserByID(ctx, user.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUserByID() error = %v, want %v", err, ErrUserNotFound)
+	}
+	if sessions, _ := s.repos.ChatSessions.ListByUser(ctx, user.ID); len(sessions) != 0 {
+		t.Errorf("%d chat sessions left", len(sessions))
+	}
+	if messages, _ := s.repos.Messages.List(ctx, session.ID, 0, 0); len(messages) != 0 {
+		t.Errorf("%d messages left", len(messages))
+	}
+	if _, err := s.repos.HealthProfiles.FindByUser(ctx, user.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("health profile left, FindByUser() error = %v", err)
+	}
+	if appointments, _ := s.appointment.GetUserAppointments(ctx, user.ID); len(appointments) != 0 {
+		t.Errorf("%d appointments left", len(appointments))
+	}
+	if appointments, _ := s.appointment.GetUserAppointments(ctx, other.ID); len(appointments) != 1 {
+		t.Errorf("other user has %d appointments, want theirs kept", len(appointments))
+	}
+	if _, err := s.repos.UsageBudgets.FindByUser(ctx, user.ID); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("usage budget left, FindByUser() error = %v", err)
+	}
+	if usage, err := s.quota.Consume(ctx, user.ID, "user", QuotaMessages); err != nil || usage.Remaining != 99 {
+		t.Errorf("quota after deletion = %+v, %v, want the count of the deleted user gone", usage, err)
+	}
+	if usage, err := s.quota.Consume(ctx, other.ID, "user", QuotaMessages); err != nil || usage.Remaining != 98 {
+		t.Errorf("other user's quota = %+v, %v, want their count kept", usage, err)
+	}
+
+	// The address is free for a new account
+	s.register(t, "asha@example.com", "secret1")
+}
+
+func TestDeleteAccountWithoutPassword(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	user := s.createProviderUser(t, "asha@example.com", time.Now().Add(-time.Hour))
+
+	if err := s.account.DeleteAccount(ctx, user.ID, models.DeleteAccountRequest{}); !errors.Is(err, ErrReauthenticationRequired) {
+		t.Errorf("DeleteAccount() after an old sign-in error = %v, want %v", err, ErrReauthenticationRequired)
+	}
+	if _, err := s.auth.GetUserByID(ctx, user.ID); err != nil {
+		t.Fatalf("account gone after a refused deletion: %v", err)
+	}
+
+	if err := s.repos.Users.SetProviderSignIn(ctx, user.ID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.account.DeleteAccount(ctx, user.ID, models.DeleteAccountRequest{}); err != nil {
+		t.Errorf("DeleteAccount() after a recent sign-in error = %v", err)
+	}
+}
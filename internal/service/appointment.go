@@ -2,31 +2,38 @@ package service
 
 import (
 	"context"
+	"errors"
 	"time"
 
-	"github.com/subhammahanty235/medai/internal/db"
+	"github.com/subhammahanty235/medai/internal/metrics"
 	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/repository"
+	"github.com/subhammahanty235/medai/internal/tracing"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// ErrAppointmentNotFound is returned for unknown appointments.
+var ErrAppointmentNotFound = NotFound("appointment_not_found", "Appointment not found")
+
 type AppointmentService struct {
-	db *db.Database
+	appointments repository.AppointmentRepository
 }
 
-func NewAppointmentService(database *db.Database) *AppointmentService {
+func NewAppointmentService(appointments repository.AppointmentRepository) *AppointmentService {
 	return &AppointmentService{
-		db: database,
+		appointments: appointments,
 	}
 }
 
-func (s *AppointmentService) BookAppointment(userID primitive.ObjectID, req models.AppointmentRequest, chatSessionID primitive.ObjectID) (*models.Appointment, error) {
-	collection := s.db.GetCollection("appointments")
+func (s *AppointmentService) BookAppointment(ctx context.Context, userID primitive.ObjectID, req models.AppointmentRequest, chatSessionID primitive.ObjectID) (*models.Appointment, error) {
+	ctx, span := tracing.Start(ctx, "AppointmentService.BookAppointment")
+	defer span.End()
 
 	realDoctorID, err := primitive.ObjectIDFromHex(req.RealDoctorID)
 	if err != nil {
-		return nil, err
+		return nil, Invalid("invalid_request", "Invalid real doctor ID",
+			models.FieldError{Field: "real_doctor_id", Code: "objectid", Message: "must be a valid ID"})
 	}
 
 	appointment := models.Appointment{
@@ -41,52 +48,46 @@ func (s *AppointmentService) BookAppointment(userID primitive.ObjectID, req mode
 		UpdatedAt:        time.Now(),
 	}
 
-	result, err := collection.InsertOne(context.Background(), appointment)
-	if err != nil {
+	if err := s.appointments.Create(ctx, &appointment); err != nil {
 		return nil, err
 	}
+	metrics.ObserveAppointmentTransition("", appointment.Status)
 
-	appointment.ID = result.InsertedID.(primitive.ObjectID)
 	return &appointment, nil
 }
 
-func (s *AppointmentService) GetUserAppointments(userID primitive.ObjectID) ([]models.Appointment, error) {
-	collection := s.db.GetCollection("appointments")
-
-	cursor, err := collection.Find(context.Background(), bson.M{"user_id": userID})
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(context.Background())
-
-	var appointments []models.Appointment
-	if err = cursor.All(context.Background(), &appointments); err != nil {
-		return nil, err
-	}
+func (s *AppointmentService) GetUserAppointments(ctx context.Context, userID primitive.ObjectID) ([]models.Appointment, error) {
+	ctx, span := tracing.Start(ctx, "AppointmentService.GetUserAppointments")
+	defer span.End()
 
-	return appointments, nil
+	return s.appointments.ListByUser(ctx, userID)
 }
 
-func (s *AppointmentService) UpdateAppointmentStatus(appointmentID primitive.ObjectID, status string) error {
-	collection := s.db.GetCollection("appointments")
+func (s *AppointmentService) UpdateAppointmentStatus(ctx context.Context, appointmentID primitive.ObjectID, status string) error {
+	ctx, span := tracing.Start(ctx, "AppointmentService.UpdateAppointmentStatus")
+	defer span.End()
 
-	_, err := collection.UpdateOne(
-		context.Background(),
-		bson.M{"_id": appointmentID},
-		bson.M{"$set": bson.M{"status": status, "updated_at": time.Now()}},
-	)
+	previous, err := s.appointments.UpdateStatus(ctx, appointmentID, status)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrAppointmentNotFound
+	}
+	if err != nil {
+		return err
+	}
 
-	return err
+	if previous != status {
+		metrics.ObserveAppointmentTransition(previous, status)
+	}
+	return nil
 }
 
-func (s *AppointmentService) GetAppointmentByID(appointmentID primitive.ObjectID) (*models.Appointment, error) {
-	collection := s.db.GetCollection("appointments")
+func (s *AppointmentService) GetAppointmentByID(ctx context.Context, appointmentID primitive.ObjectID) (*models.Appointment, error) {
+	ctx, span := tracing.Start(ctx, "AppointmentService.GetAppointmentByID")
+	defer span.End()
 
-	var appointment models.Appointment
-	err := collection.FindOne(context.Background(), bson.M{"_id": appointmentID}).Decode(&appointment)
-	if err != nil {
-		return nil, err
+	appointment, err := s.appointments.FindByID(ctx, appointmentID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrAppointmentNotFound
 	}
-
-	return &appointment, nil
+	return appointment, err
 }
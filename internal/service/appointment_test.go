@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBookAndUpdateAppointment(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	user := s.register(t, "asha@example.com", "secret1")
+
+	session, err := s.chat.StartChatSession(ctx, user.ID, testDoctor.ID, primitive.NilObjectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	booked, err := s.appointment.BookAppointment(ctx, user.ID, models.AppointmentRequest{
+		RealDoctorID:    testRealDoctor.ID.Hex(),
+		AppointmentDate: time.Now().Add(48 * time.Hour),
+		Symptoms:        "Persistent cough",
+	}, session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if booked.ID.IsZero() || booked.Status != "pending" || booked.ChatSessionID != session.ID {
+		t.Errorf("BookAppointment() = %+v, want a pending appointment for the session", booked)
+	}
+
+	if err := s.appointment.UpdateAppointmentStatus(ctx, booked.ID, "confirmed"); err != nil {
+		t.Fatal(err)
+	}
+
+	appointments, err := s.appointment.GetUserAppointments(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(appointments) != 1 || appointments[0].Status != "confirmed" {
+		t.Errorf("GetUserAppointments() = %+v, want the confirmed appointment", appointments)
+	}
+
+	found, err := s.appointment.GetAppointmentByID(ctx, booked.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.UserID != user.ID || found.RealDoctorID != testRealDoctor.ID {
+		t.Errorf("GetAppointmentByID() = %+v, want the booked appointment", found)
+	}
+}
+
+func TestAppointmentErrors(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	user := s.register(t, "asha@example.com", "secret1")
+
+	_, err := s.appointment.BookAppointment(ctx, user.ID, models.AppointmentRequest{
+		RealDoctorID:    "not-an-id",
+		AppointmentDate: time.Now(),
+	}, primitive.NilObjectID)
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("BookAppointment() with a bad doctor ID error = %v, want %v", err, ErrValidation)
+	}
+
+	missing := primitive.NewObjectID()
+	if err := s.appointment.UpdateAppointmentStatus(ctx, missing, "confirmed"); !errors.Is(err, ErrAppointmentNotFound) {
+		t.Errorf("UpdateAppointmentStatus() error = %v, want %v", err, ErrAppointmentNotFound)
+	}
+	if _, err := s.appointment.GetAppointmentByID(ctx, missing); !errors.Is(err, ErrAppointmentNotFound) {
+		t.Errorf("GetAppointmentByID() error = %v, want %v", err, ErrAppointmentNotFound)
+	}
+}
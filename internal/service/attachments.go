@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/repository"
+	"github.com/subhammahanty235/medai/internal/upload"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Upload is a validated file to attach to a chat message.
+type Upload struct {
+	File     *upload.File
+	FileName string
+}
+
+// storeAttachments stores uploads and describes them as attachments. A file
+// already shared in the session, or twice in the same message, is not stored
+// again: the new attachment points at the existing object. The keys of the
+// objects stored now are returned along with the attachments.
+func (s *ChatService) storeAttachments(ctx context.Context, sessionID primitive.ObjectID, uploads []Upload) ([]models.Attachment, map[string]bool, error) {
+	stored := map[string]models.Attachment{}
+
+	attachments := make([]models.Attachment, 0, len(uploads))
+	for _, u := range uploads {
+		sum := sha256.Sum256(u.File.Data)
+		checksum := hex.EncodeToString(sum[:])
+
+		previous, ok := stored[checksum]
+		if !ok {
+			existing, err := s.messages.FindAttachment(ctx, sessionID, checksum)
+			if err != nil && !errors.Is(err, repository.ErrNotFound) {
+				return nil, nil, err
+			}
+			if existing != nil {
+				previous, ok = *existing, true
+			}
+		}
+		if ok {
+			previous.ID = primitive.NewObjectID()
+			previous.FileName = u.FileName
+			attachments = append(attachments, previous)
+			continue
+		}
+
+		attachment, err := newAttachment(u, checksum)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if err := s.storage.Put(ctx, attachment.Key, u.File.Reader(), u.File.Size(), u.File.ContentType); err != nil {
+			return nil, nil, err
+		}
+
+		stored[checksum] = attachment
+		attachments = append(attachments, attachment)
+	}
+
+	storedKeys := make(map[string]bool, len(stored))
+	for _, attachment := range stored {
+		storedKeys[attachment.Key] = true
+	}
+	return attachments, storedKeys, nil
+}
+
+// processAttachments starts the background processing of the images of a
+// message once it is inserted. Images stored with the message are enqueued.
+// Copies of images that were still pending when copied are synced with the
+// earlier attachment instead: its processing may have finished before the
+// message was inserted and so missed the copies.
+func (s *ChatService) processAttachments(ctx context.Context, sessionID primitive.ObjectID, attachments []models.Attachment, storedKeys map[string]bool) {
+	done := map[string]bool{}
+	for _, attachment := range attachments {
+		if attachment.Kind != models.AttachmentKindImage || attachment.Status != models.AttachmentStatusPending || done[attachment.Key] {
+			continue
+		}
+		done[attachment.Key] = true
+
+		if storedKeys[attachment.Key] {
+			s.imageVariants.Enqueue(ctx, sessionID, attachment.Key)
+			continue
+		}
+
+		// Still pending, the processing under way updates the copies too.
+		// A failure leaves them pending until ResumePending on next start.
+		processed, err := s.messages.FindProcessedAttachment(ctx, sessionID, attachment.Key)
+		if err != nil {
+			continue
+		}
+		_ = s.messages.UpdateAttachments(ctx, sessionID, attachment.Key, processed.Status, processed.Variants)
+	}
+}
+
+func newAttachment(u Upload, checksum string) (models.Attachment, error) {
+	attachment := models.Attachment{
+		ID:          primitive.NewObjectID(),
+		FileName:    u.FileName,
+		ContentType: u.File.ContentType,
+		Size:        u.File.Size(),
+		Width:       u.File.Width,
+		Height:      u.File.Height,
+		Checksum:    checksum,
+	}
+
+	if u.File.IsImage() {
+		attachment.Kind = models.AttachmentKindImage
+		attachment.Key = fmt.Sprintf("chat-images/%s%s", uuid.New().String(), u.File.Extension)
+		attachment.Status = models.AttachmentStatusPending
+		return attachment, nil
+	}
+
+	pages, err := upload.ExtractText(u.File.ContentType, u.File.Data)
+	if err != nil {
+		return models.Attachment{}, upload.ErrCorrupt
+	}
+
+	attachment.Kind = models.AttachmentKindDocument
+	attachment.Key = fmt.Sprintf("chat-documents/%s%s", uuid.New().String(), u.File.Extension)
+	attachment.Status = models.AttachmentStatusReady
+	attachment.PageCount = len(pages)
+	for _, page := range pages {
+		attachment.Pages = append(attachment.Pages, models.DocumentPage{Number: page.Number, Text: page.Text})
+		if page.Text != "" {
+			attachment.HasText = true
+		}
+	}
+
+	return attachment, nil
+}
+
+// attachmentKeys returns every stored object referenced by the messages,
+// including image variants, without duplicates.
+func attachmentKeys(messages []models.Message) []string {
+	seen := map[string]bool{}
+	var keys []string
+	add := func(key string) {
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	for _, message := range messages {
+		for _, attachment := range message.Attachments {
+			add(attachment.Key)
+			for _, variant := range attachment.Variants {
+				add(variant.Key)
+			}
+		}
+	}
+
+	return keys
+}
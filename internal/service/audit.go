@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/repository"
+	"github.com/subhammahanty235/medai/internal/tracing"
+)
+
+type AuditService struct {
+	events repository.AuditEventRepository
+	logger *slog.Logger
+}
+
+func NewAuditService(events repository.AuditEventRepository, logger *slog.Logger) *AuditService {
+	return &AuditService{
+		events: events,
+		logger: logger,
+	}
+}
+
+// Record stores a security-relevant event. Failures are logged rather than
+// returned so that auditing never blocks the request that triggered it.
+func (s *AuditService) Record(ctx context.Context, event models.AuditEvent) {
+	ctx, span := tracing.Start(ctx, "AuditService.Record")
+	defer span.End()
+
+	// Events are kept even if the client has gone away
+	event.CreatedAt = time.Now()
+	if err := s.events.Insert(context.WithoutCancel(ctx), &event); err != nil {
+		s.logger.ErrorContext(ctx, "Error recording audit event", "event_type", event.Type, "error", err)
+	}
+}
@@ -3,39 +3,62 @@ package service
 import (
 	"context"
 	"errors"
+	"slices"
 	"time"
 
-	"github.com/subhammahanty235/medai/internal/db"
 	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/repository"
+	"github.com/subhammahanty235/medai/internal/tracing"
 	"github.com/subhammahanty235/medai/internal/utils"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// dummyPasswordHash is compared against when a login names an unknown email
+// so that both paths spend the same time in bcrypt.
+var dummyPasswordHash, _ = utils.HashPassword("medai-dummy-password")
+
+type MFAConfig struct {
+	Issuer        string
+	RequiredRoles []string
+}
+
 type AuthService struct {
-	db        *db.Database
-	jwtSecret string
+	users      repository.UserRepository
+	jwtSecret  string
+	loginGuard *LoginGuard
+	mfa        MFAConfig
 }
 
-func NewAuthService(database *db.Database, jwtSecret string) *AuthService {
+func NewAuthService(users repository.UserRepository, jwtSecret string, loginGuard *LoginGuard, mfa MFAConfig) *AuthService {
 	return &AuthService{
-		db:        database,
-		jwtSecret: jwtSecret,
+		users:      users,
+		jwtSecret:  jwtSecret,
+		loginGuard: loginGuard,
+		mfa:        mfa,
 	}
 }
 
-func (s *AuthService) Register(req models.RegisterRequest) (*models.AuthResponse, error) {
-	collection := s.db.GetCollection("users")
+var (
+	// ErrEmailInUse is returned when an account already has the email
+	// address.
+	ErrEmailInUse = Conflict("email_in_use", "email is already in use")
+	// ErrInvalidCredentials is the same for unknown emails and wrong
+	// passwords.
+	ErrInvalidCredentials = Unauthorized("invalid_credentials", "invalid credentials")
+	ErrUserNotFound       = NotFound("user_not_found", "User not found")
+)
+
+func (s *AuthService) Register(ctx context.Context, req models.RegisterRequest) (*models.AuthResponse, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.Register")
+	defer span.End()
 
 	// Check if user already exists
-	var existingUser models.User
-	err := collection.FindOne(context.Background(), bson.M{"email": req.Email}).Decode(&existingUser)
+	_, err := s.users.FindByEmail(ctx, req.Email)
 	if err == nil {
-		return nil, errors.New("user already exists")
+		return nil, ErrEmailInUse
 	}
-	if err != mongo.ErrNoDocuments {
+	if !errors.Is(err, repository.ErrNotFound) {
 		return nil, err
 	}
 
@@ -50,36 +73,40 @@ func (s *AuthService) Register(req models.RegisterRequest) (*models.AuthResponse
 		Name:      req.Name,
 		Email:     req.Email,
 		Password:  hashedPassword,
+		Role:      "user",
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 
-	result, err := collection.InsertOne(context.Background(), user)
+	// The unique email index catches a concurrent registration that passed
+	// the check above
+	err = s.users.Create(ctx, &user)
+	if errors.Is(err, repository.ErrDuplicate) {
+		return nil, ErrEmailInUse
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	user.ID = result.InsertedID.(primitive.ObjectID)
+	return s.completeLogin(ctx, &user)
+}
+
+func (s *AuthService) Login(ctx context.Context, req models.LoginRequest, clientIP string) (*models.AuthResponse, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.Login")
+	defer span.End()
 
-	// Generate token
-	token, err := utils.GenerateToken(user.ID, user.Email, s.jwtSecret)
+	login, err := s.loginGuard.Begin(ctx, req.Email, clientIP)
 	if err != nil {
 		return nil, err
 	}
 
-	return &models.AuthResponse{
-		Token: token,
-		User:  user,
-	}, nil
-}
-
-func (s *AuthService) Login(req models.LoginRequest) (*models.AuthResponse, error) {
-	collection := s.db.GetCollection("users")
-
-	var user models.User
-	err := collection.FindOne(context.Background(), bson.M{"email": req.Email}).Decode(&user)
-	if err == mongo.ErrNoDocuments {
-		return nil, errors.New("invalid credentials")
+	user, err := s.users.FindByEmail(ctx, req.Email)
+	if errors.Is(err, repository.ErrNotFound) {
+		utils.CheckPasswordHash(req.Password, dummyPasswordHash)
+		if err := s.loginGuard.RecordFailure(ctx, login, primitive.NilObjectID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
 	}
 	if err != nil {
 		return nil, err
@@ -87,29 +114,86 @@ func (s *AuthService) Login(req models.LoginRequest) (*models.AuthResponse, erro
 
 	// Check password
 	if !utils.CheckPasswordHash(req.Password, user.Password) {
-		return nil, errors.New("invalid credentials")
+		if err := s.loginGuard.RecordFailure(ctx, login, user.ID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := s.loginGuard.RecordSuccess(ctx, login); err != nil {
+		return nil, err
+	}
+
+	return s.completeLogin(ctx, user)
+}
+
+// completeLogin issues the access token for an authenticated user, or the
+// MFA token for the next step when a second factor or enrollment is needed.
+func (s *AuthService) completeLogin(ctx context.Context, user *models.User) (*models.AuthResponse, error) {
+	if user.MFA.Enabled {
+		mfaToken, err := utils.GenerateMFAChallengeToken(user.ID, user.Email, s.jwtSecret)
+		if err != nil {
+			return nil, err
+		}
+
+		return &models.AuthResponse{
+			User:        *user,
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		}, nil
+	}
+
+	if s.mfaRequired(user) {
+		mfaToken, err := utils.GenerateMFAEnrollmentToken(user.ID, user.Email, userRole(user), user.TokenVersion, s.jwtSecret)
+		if err != nil {
+			return nil, err
+		}
+
+		return &models.AuthResponse{
+			User:                  *user,
+			MFAEnrollmentRequired: true,
+			MFAToken:              mfaToken,
+		}, nil
 	}
 
-	// Generate token
-	token, err := utils.GenerateToken(user.ID, user.Email, s.jwtSecret)
+	token, err := utils.GenerateToken(user.ID, user.Email, userRole(user), user.TokenVersion, s.jwtSecret)
 	if err != nil {
 		return nil, err
 	}
 
 	return &models.AuthResponse{
 		Token: token,
-		User:  user,
+		User:  *user,
 	}, nil
 }
 
-func (s *AuthService) GetUserByID(userID primitive.ObjectID) (*models.User, error) {
-	collection := s.db.GetCollection("users")
+func (s *AuthService) mfaRequired(user *models.User) bool {
+	return slices.Contains(s.mfa.RequiredRoles, userRole(user))
+}
+
+// userRole treats accounts created before roles existed as regular users.
+func userRole(user *models.User) string {
+	if user.Role == "" {
+		return "user"
+	}
+	return user.Role
+}
 
-	var user models.User
-	err := collection.FindOne(context.Background(), bson.M{"_id": userID}).Decode(&user)
-	if err != nil {
-		return nil, err
+func (s *AuthService) GetUserByID(ctx context.Context, userID primitive.ObjectID) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.GetUserByID")
+	defer span.End()
+
+	user, err := s.users.FindByID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
 	}
+	return user, err
+}
+
+// GetTokenVersion returns the token version tokens for userID must carry.
+func (s *AuthService) GetTokenVersion(ctx context.Context, userID primitive.ObjectID) (int, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.GetTokenVersion")
+	defer span.End()
 
-	return &user, nil
+	return s.users.GetTokenVersion(ctx, userID)
 }
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/utils"
+)
+
+func TestRegisterAndLogin(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+
+	registered, err := s.auth.Register(ctx, models.RegisterRequest{Name: "Asha", Email: "asha@example.com", Password: "secret1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registered.Token == "" || registered.User.ID.IsZero() {
+		t.Fatalf("Register() = %+v, want a token and a stored user", registered)
+	}
+	if registered.User.Role != "user" {
+		t.Errorf("Role = %q, want user", registered.User.Role)
+	}
+
+	_, err = s.auth.Register(ctx, models.RegisterRequest{Name: "Asha", Email: "asha@example.com", Password: "other1"})
+	if !errors.Is(err, ErrEmailInUse) {
+		t.Errorf("second Register() error = %v, want %v", err, ErrEmailInUse)
+	}
+
+	loggedIn, err := s.auth.Login(ctx, models.LoginRequest{Email: "asha@example.com", Password: "secret1"}, "192.0.2.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := utils.ValidateToken(loggedIn.Token, testJWTSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserID != registered.User.ID {
+		t.Errorf("token user = %s, want %s", claims.UserID.Hex(), registered.User.ID.Hex())
+	}
+}
+
+func TestLoginRejectsWrongPasswordAndUnknownEmail(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	s.register(t, "asha@example.com", "secret1")
+
+	for _, req := range []models.LoginRequest{
+		{Email: "asha@example.com", Password: "wrong"},
+		{Email: "nobody@example.com", Password: "secret1"},
+	} {
+		if _, err := s.auth.Login(ctx, req, "192.0.2.1"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("Login(%s) error = %v, want %v", req.Email, err, ErrInvalidCredentials)
+		}
+	}
+}
+
+func TestLoginLocksAccountAfterRepeatedFailures(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	s.register(t, "asha@example.com", "secret1")
+
+	for i := 0; i < 3; i++ {
+		_, err := s.auth.Login(ctx, models.LoginRequest{Email: "asha@example.com", Password: "wrong"}, "192.0.2.1")
+		if !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("failure %d: error = %v, want %v", i+1, err, ErrInvalidCredentials)
+		}
+	}
+
+	// Locked even with the right password, and for any spelling of the email
+	_, err := s.auth.Login(ctx, models.LoginRequest{Email: " ASHA@example.com", Password: "secret1"}, "192.0.2.2")
+	var throttled *LoginThrottledError
+	if !errors.As(err, &throttled) || throttled.RetryAfter <= 0 {
+		t.Fatalf("Login() after lockout error = %v, want *LoginThrottledError", err)
+	}
+
+	attempt, err := s.repos.LoginAttempts.Find(ctx, emailKey("asha@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attempt.LockedUntil.IsZero() || attempt.ExpiresAt.Before(attempt.LockedUntil) {
+		t.Errorf("attempt = %+v, want locked and kept past the lockout", attempt)
+	}
+}
+
+func TestConcurrentLoginsCannotPassLockout(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	s.register(t, "asha@example.com", "secret1")
+
+	// A burst of guesses sent at once, none of which has failed yet when
+	// the others start
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		checked   int
+		throttled int
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.auth.Login(ctx, models.LoginRequest{Email: "asha@example.com", Password: "wrong"}, "192.0.2.1")
+
+			mu.Lock()
+			defer mu.Unlock()
+			var throttledErr *LoginThrottledError
+			switch {
+			case errors.Is(err, ErrInvalidCredentials):
+				checked++
+			case errors.As(err, &throttledErr):
+				throttled++
+			default:
+				t.Errorf("Login() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if checked > 3 || checked+throttled != 10 {
+		t.Errorf("%d passwords checked and %d attempts throttled, want at most 3 checked", checked, throttled)
+	}
+	if _, err := s.auth.Login(ctx, models.LoginRequest{Email: "asha@example.com", Password: "secret1"}, "192.0.2.1"); err == nil {
+		t.Error("Login() after the burst succeeded, want the account locked")
+	}
+}
+
+func TestLoginSuccessClearsFailures(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	s.register(t, "asha@example.com", "secret1")
+
+	for i := 0; i < 2; i++ {
+		s.auth.Login(ctx, models.LoginRequest{Email: "asha@example.com", Password: "wrong"}, "192.0.2.1")
+	}
+	if _, err := s.auth.Login(ctx, models.LoginRequest{Email: "asha@example.com", Password: "secret1"}, "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two more failures stay below the limit once the count was reset
+	for i := 0; i < 2; i++ {
+		s.auth.Login(ctx, models.LoginRequest{Email: "asha@example.com", Password: "wrong"}, "192.0.2.1")
+	}
+	if _, err := s.auth.Login(ctx, models.LoginRequest{Email: "asha@example.com", Password: "secret1"}, "192.0.2.1"); err != nil {
+		t.Errorf("Login() error = %v, want the count reset by the earlier success", err)
+	}
+}
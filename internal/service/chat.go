@@ -2,200 +2,535 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
-	"github.com/subhammahanty235/medai/internal/db"
+	"github.com/google/uuid"
+	"github.com/subhammahanty235/medai/internal/llm"
+	"github.com/subhammahanty235/medai/internal/metrics"
 	"github.com/subhammahanty235/medai/internal/models"
-	"github.com/subhammahanty235/medai/internal/utils"
+	"github.com/subhammahanty235/medai/internal/repository"
+	"github.com/subhammahanty235/medai/internal/storage"
+	"github.com/subhammahanty235/medai/internal/tracing"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Messages are returned a page at a time; the AI doctor sees the latest
+// conversationContextMessages of a session.
+const (
+	defaultMessagePageSize      = 50
+	maxMessagePageSize          = 200
+	conversationContextMessages = 100
+)
+
+// ReplyStatusPending marks a user message still waiting for its reply.
+const ReplyStatusPending = "pending"
+
+// replyStoreTime is kept of a reply claim's lease for storing the reply
+// once it is generated.
+const replyStoreTime = 15 * time.Second
+
+// CheckReplyLease returns an error unless a reply claim's lease leaves time
+// to generate the reply before the part kept for storing it.
+func CheckReplyLease(lease time.Duration) error {
+	if lease <= replyStoreTime {
+		return fmt.Errorf("%s is not longer than the %s kept for storing the reply", lease, replyStoreTime)
+	}
+	return nil
+}
+
+var (
+	// ErrChatSessionNotFound is also returned for sessions of other users.
+	ErrChatSessionNotFound = NotFound("chat_session_not_found", "Chat session not found")
+	ErrNoPendingReply      = NotFound("no_pending_reply", "No message is waiting for a reply")
+	ErrReplyInProgress     = Conflict("reply_in_progress", "A reply to this message is already being generated")
+)
+
 type ChatService struct {
-	db            *db.Database
-	geminiClient  *utils.GeminiClient
-	doctorService *DoctorService
+	sessions             repository.ChatSessionRepository
+	messages             repository.MessageRepository
+	llm                  *llm.Client
+	doctorService        *DoctorService
+	healthProfileService *HealthProfileService
+	storage              storage.Storage
+	imageVariants        *ImageVariantService
+	usage                *UsageService
+	signedURLTTL         time.Duration
+	replyLease           time.Duration
 }
 
-func NewChatService(database *db.Database, geminiClient *utils.GeminiClient, doctorService *DoctorService) *ChatService {
+func NewChatService(sessions repository.ChatSessionRepository, messages repository.MessageRepository, llmClient *llm.Client, doctorService *DoctorService, healthProfileService *HealthProfileService, storage storage.Storage, imageVariants *ImageVariantService, usage *UsageService, signedURLTTL, replyLease time.Duration) *ChatService {
 	return &ChatService{
-		db:            database,
-		geminiClient:  geminiClient,
-		doctorService: doctorService,
+		sessions:             sessions,
+		messages:             messages,
+		llm:                  llmClient,
+		doctorService:        doctorService,
+		healthProfileService: healthProfileService,
+		storage:              storage,
+		imageVariants:        imageVariants,
+		usage:                usage,
+		signedURLTTL:         signedURLTTL,
+		replyLease:           replyLease,
 	}
 }
 
-func (s *ChatService) StartChatSession(userID primitive.ObjectID, doctorID string) (*models.ChatSession, error) {
-	collection := s.db.GetCollection("chat_sessions")
+// StartChatSession opens (or resumes) a consultation with an AI doctor.
+// dependentID is NilObjectID when the user consults about themselves.
+func (s *ChatService) StartChatSession(ctx context.Context, userID primitive.ObjectID, doctorID string, dependentID primitive.ObjectID) (*models.ChatSession, error) {
+	ctx, span := tracing.Start(ctx, "ChatService.StartChatSession")
+	defer span.End()
 
-	// Check if there's an active session for this user and doctor
-	var existingSession models.ChatSession
-	err := collection.FindOne(context.Background(), bson.M{
-		"user_id":   userID,
-		"doctor_id": doctorID,
-		"status":    "active",
-	}).Decode(&existingSession)
-
-	if err == nil {
-		return &existingSession, nil // Return existing active session
+	if !dependentID.IsZero() {
+		if _, err := s.healthProfileService.GetDependent(ctx, userID, dependentID); err != nil {
+			return nil, err
+		}
 	}
 
-	// Create new session
-	session := models.ChatSession{
-		UserID:    userID,
-		DoctorID:  doctorID,
-		Messages:  []models.Message{},
-		Status:    "active",
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	// Check if there's an active session for this user, doctor and patient
+	existingSession, err := s.sessions.FindActive(ctx, userID, doctorID, dependentID)
+	if err == nil {
+		// Return existing active session
+		if err := s.loadLatestMessages(ctx, existingSession); err != nil {
+			return nil, err
+		}
+		return existingSession, nil
 	}
-
-	result, err := collection.InsertOne(context.Background(), session)
-	if err != nil {
+	if !errors.Is(err, repository.ErrNotFound) {
 		return nil, err
 	}
 
-	session.ID = result.InsertedID.(primitive.ObjectID)
-
-	// Add welcome message
-	doctor, err := s.doctorService.GetDoctorByID(doctorID)
+	doctor, err := s.doctorService.GetDoctorByID(ctx, doctorID)
 	if err != nil {
 		return nil, err
 	}
 
+	// Create new session with a welcome message
 	welcomeMessage := models.Message{
 		ID:        primitive.NewObjectID(),
+		Seq:       1,
 		Content:   fmt.Sprintf("Hello! I'm %s, your AI %s. How can I help you today? Please tell me about your symptoms or concerns.", doctor.Name, doctor.Specialty),
 		Sender:    "ai",
 		Timestamp: time.Now(),
 	}
 
-	session.Messages = append(session.Messages, welcomeMessage)
+	session := models.ChatSession{
+		UserID:       userID,
+		DoctorID:     doctorID,
+		DependentID:  dependentID,
+		Status:       "active",
+		MessageCount: welcomeMessage.Seq,
+		LastMessage:  welcomeMessage.Preview(),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
 
-	// Update session with welcome message
-	_, err = collection.UpdateOne(
-		context.Background(),
-		bson.M{"_id": session.ID},
-		bson.M{"$set": bson.M{"messages": session.Messages, "updated_at": time.Now()}},
-	)
+	err = s.sessions.Create(ctx, &session)
+	if errors.Is(err, repository.ErrDuplicate) {
+		// Started concurrently by another request
+		return s.StartChatSession(ctx, userID, doctorID, dependentID)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	welcomeMessage.SessionID = session.ID
+	if err := s.messages.Insert(ctx, []models.Message{welcomeMessage}); err != nil {
+		return nil, err
+	}
+
+	session.Messages = []models.Message{welcomeMessage}
 	return &session, nil
 }
 
-func (s *ChatService) SendMessage(sessionID primitive.ObjectID, userID primitive.ObjectID, content string, imageURL string) (*models.Message, error) {
-	collection := s.db.GetCollection("chat_sessions")
+// SendMessage adds the user's message with any uploaded files attached and
+// returns the AI doctor's reply.
+func (s *ChatService) SendMessage(ctx context.Context, sessionID primitive.ObjectID, userID primitive.ObjectID, content string, uploads []Upload) (*models.Message, error) {
+	ctx, span := tracing.Start(ctx, "ChatService.SendMessage")
+	defer span.End()
+
+	message, _, err := s.SendMessageWithAttachments(ctx, sessionID, userID, content, uploads)
+	return message, err
+}
+
+// SendMessageWithAttachments is SendMessage that also returns the stored
+// attachments, with signed URLs, of the user's message. The user's message
+// is saved before the reply is generated; if that fails, it stays pending
+// and RetryReply generates the reply later.
+func (s *ChatService) SendMessageWithAttachments(ctx context.Context, sessionID primitive.ObjectID, userID primitive.ObjectID, content string, uploads []Upload) (*models.Message, []models.Attachment, error) {
+	ctx, span := tracing.Start(ctx, "ChatService.SendMessageWithAttachments")
+	defer span.End()
 
 	// Get session
-	var session models.ChatSession
-	err := collection.FindOne(context.Background(), bson.M{"_id": sessionID, "user_id": userID}).Decode(&session)
+	session, err := s.findSession(ctx, sessionID, userID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	// Refused before anything is stored, as no reply would follow
+	if err := s.usage.CheckBudget(ctx, userID); err != nil {
+		return nil, nil, err
 	}
 
-	// Add user message
+	// Get doctor info for AI response
+	doctor, err := s.doctorService.GetDoctorByID(ctx, session.DoctorID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	attachments, storedKeys, err := s.storeAttachments(ctx, sessionID, uploads)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Messages go to their own collection under sequence numbers reserved
+	// atomically, so concurrent sends to a session cannot overwrite each
+	// other
+	seq, err := s.sessions.ReserveMessageSeqs(ctx, sessionID, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Add user message, claimed for the reply generated below
+	now := time.Now()
 	userMessage := models.Message{
-		ID:        primitive.NewObjectID(),
-		Content:   content,
-		Sender:    "user",
-		ImageURL:  imageURL,
-		Timestamp: time.Now(),
+		ID:                primitive.NewObjectID(),
+		SessionID:         sessionID,
+		Seq:               seq,
+		Content:           content,
+		Sender:            "user",
+		Attachments:       attachments,
+		Timestamp:         now,
+		ReplyStatus:       ReplyStatusPending,
+		ReplyClaim:        uuid.NewString(),
+		ReplyClaimedUntil: now.Add(s.replyLease),
+	}
+	if err := s.messages.Insert(ctx, []models.Message{userMessage}); err != nil {
+		return nil, nil, err
 	}
 
-	session.Messages = append(session.Messages, userMessage)
+	if err := s.sessions.SetLastMessage(ctx, sessionID, *userMessage.Preview()); err != nil {
+		return nil, nil, err
+	}
 
-	// Get doctor info for AI response
-	doctor, err := s.doctorService.GetDoctorByID(session.DoctorID)
+	s.processAttachments(ctx, sessionID, attachments, storedKeys)
+
+	aiMessage, err := s.reply(ctx, session, doctor, userMessage)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	userMessages := []models.Message{userMessage}
+	if err := s.signAttachmentURLs(userMessages); err != nil {
+		return nil, nil, err
+	}
+
+	return aiMessage, userMessages[0].Attachments, nil
+}
+
+// RetryReply generates the reply to the latest message of the session that
+// is still waiting for one.
+func (s *ChatService) RetryReply(ctx context.Context, sessionID primitive.ObjectID, userID primitive.ObjectID) (*models.Message, error) {
+	ctx, span := tracing.Start(ctx, "ChatService.RetryReply")
+	defer span.End()
+
+	session, err := s.findSession(ctx, sessionID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.usage.CheckBudget(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	doctor, err := s.doctorService.GetDoctorByID(ctx, session.DoctorID)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := s.messages.FindPendingReply(ctx, sessionID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrNoPendingReply
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	message.ReplyClaim, message.ReplyClaimedUntil = uuid.NewString(), now.Add(s.replyLease)
+	err = s.messages.ClaimReply(ctx, message.ID, message.ReplyClaim, now, message.ReplyClaimedUntil)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrReplyInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.reply(ctx, session, doctor, *message)
+}
+
+// reply asks the AI doctor to answer userMessage, which the caller has
+// claimed, and stores the answer. On failure the claim is released and the
+// message stays pending. Generation is cut short so that the answer is
+// stored before the claim expires and another request may take it over.
+func (s *ChatService) reply(ctx context.Context, session *models.ChatSession, doctor *models.Doctor, userMessage models.Message) (aiMessage *models.Message, err error) {
+	defer func() {
+		if err != nil {
+			// The claim lapses on its own if this fails too
+			_ = s.messages.ReleaseReply(context.WithoutCancel(ctx), userMessage.ID, userMessage.ReplyClaim)
+		}
+	}()
+
+	history, err := s.messages.List(ctx, session.ID, userMessage.Seq, conversationContextMessages-1)
+	if err != nil {
+		return nil, err
+	}
+	conversation := append(history, userMessage)
+	content := userMessage.Content
+
+	// Include the patient's health profile if the user consented to sharing it
+	patientContext, err := s.healthProfileService.BuildAIContext(ctx, session.UserID, session.DependentID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Build conversation context
-	conversationContext := s.buildConversationContext(session.Messages)
+	conversationContext := s.buildConversationContext(conversation)
 	fullPrompt := fmt.Sprintf("%s\n\nConversation so far:\n%s\n\nLatest user message: %s", doctor.Prompt, conversationContext, content)
+	if patientContext != "" {
+		fullPrompt = fmt.Sprintf("%s\n\nPatient profile (do not ask again for details given here):\n%s\nConversation so far:\n%s\n\nLatest user message: %s", doctor.Prompt, patientContext, conversationContext, content)
+	}
+	if hasDocuments(conversation) {
+		fullPrompt += "\n\nThe patient has shared documents, included above with page markers. When you refer to a value or finding from a document, name the document and the page it is on, for example \"your haemoglobin on page 2 is low\"."
+	}
 
 	// Generate AI response
-	var aiResponse string
-	if imageURL != "" {
-		aiResponse, err = s.geminiClient.GenerateResponseWithImage(doctor.Prompt, fullPrompt, imageURL)
-	} else {
-		aiResponse, err = s.geminiClient.GenerateResponse(doctor.Prompt, fullPrompt)
+	imageURLs, err := s.imageURLs(userMessage.Attachments)
+	if err != nil {
+		return nil, err
 	}
 
+	generateCtx, cancel := context.WithDeadline(ctx, userMessage.ReplyClaimedUntil.Add(-replyStoreTime))
+	defer cancel()
+	generated, err := s.llm.Generate(generateCtx, llm.Request{
+		SystemPrompt: doctor.Prompt,
+		Prompt:       fullPrompt,
+		ImageURLs:    imageURLs,
+	})
+	s.recordAttempts(ctx, session, doctor.ID, generated.Attempts)
 	if err != nil {
-		return nil, err
+		return nil, errReplyPending(err)
 	}
+	aiResponse := generated.Completion.Text
 
 	// Check if AI recommends seeing a real doctor
 	shouldRecommendDoctor := s.shouldRecommendRealDoctor(aiResponse, content)
 	if shouldRecommendDoctor {
-		session.Status = "doctor_recommended"
+		metrics.ObserveTriageEscalation(doctor.ID)
 		aiResponse += "\n\n🏥 Based on your symptoms, I recommend scheduling an appointment with a real doctor for proper examination and treatment. Would you like me to help you find available doctors in my specialty?"
 	}
 
+	seq, err := s.sessions.ReserveMessageSeqs(ctx, session.ID, 1)
+	if err != nil {
+		return nil, err
+	}
+
 	// Add AI response
-	aiMessage := models.Message{
+	aiMessage = &models.Message{
 		ID:        primitive.NewObjectID(),
+		SessionID: session.ID,
+		Seq:       seq,
 		Content:   aiResponse,
 		Sender:    "ai",
 		Timestamp: time.Now(),
 	}
+	if err := s.messages.Insert(ctx, []models.Message{*aiMessage}); err != nil {
+		return nil, err
+	}
 
-	session.Messages = append(session.Messages, aiMessage)
+	// Earlier messages still pending are answered by this reply too, as it
+	// was generated with them in the conversation
+	if err := s.messages.CompleteReplies(ctx, session.ID, userMessage.Seq); err != nil {
+		return nil, err
+	}
 
-	// Update session
-	_, err = collection.UpdateOne(
-		context.Background(),
-		bson.M{"_id": sessionID},
-		bson.M{"$set": bson.M{
-			"messages":   session.Messages,
-			"status":     session.Status,
-			"updated_at": time.Now(),
-		}},
-	)
-	if err != nil {
+	if err := s.sessions.SetLastMessage(ctx, session.ID, *aiMessage.Preview()); err != nil {
 		return nil, err
 	}
 
-	return &aiMessage, nil
+	if shouldRecommendDoctor && session.Status != "doctor_recommended" {
+		if err := s.sessions.UpdateStatus(ctx, session.ID, "doctor_recommended"); err != nil {
+			return nil, err
+		}
+	}
+
+	return aiMessage, nil
+}
+
+// recordAttempts records the metrics and usage of every LLM call made for
+// a reply.
+func (s *ChatService) recordAttempts(ctx context.Context, session *models.ChatSession, persona string, attempts []llm.Attempt) {
+	for _, attempt := range attempts {
+		metrics.ObserveLLMCall(persona, attempt.Model, attempt.Latency, attempt.PromptTokens, attempt.CompletionTokens, attempt.Err)
+
+		outcome := "success"
+		if attempt.Err != nil {
+			outcome = "error"
+		}
+		s.usage.Record(ctx, models.LLMUsage{
+			UserID:           session.UserID,
+			SessionID:        session.ID,
+			Persona:          persona,
+			Model:            attempt.Model,
+			PromptTokens:     attempt.PromptTokens,
+			CompletionTokens: attempt.CompletionTokens,
+			LatencyMs:        attempt.Latency.Milliseconds(),
+			Outcome:          outcome,
+		})
+	}
+}
+
+// errReplyPending reports a user message that was saved without a reply.
+// The cause is flattened so that a timed-out generation is reported as
+// reply_pending too, telling the client to retry the reply rather than
+// send the message again.
+func errReplyPending(cause error) *Error {
+	return Unavailable("reply_pending", "Your message was saved but the AI doctor could not reply, please retry",
+		errors.New(cause.Error()))
+}
+
+// GetChatHistory lists the user's sessions, most recently active first,
+// with a preview of their latest message.
+func (s *ChatService) GetChatHistory(ctx context.Context, userID primitive.ObjectID) ([]models.ChatSession, error) {
+	ctx, span := tracing.Start(ctx, "ChatService.GetChatHistory")
+	defer span.End()
+
+	return s.sessions.ListByUser(ctx, userID)
 }
 
-func (s *ChatService) GetChatHistory(userID primitive.ObjectID) ([]models.ChatSession, error) {
-	collection := s.db.GetCollection("chat_sessions")
+// GetChatSession returns a session with its latest page of messages.
+func (s *ChatService) GetChatSession(ctx context.Context, sessionID primitive.ObjectID, userID primitive.ObjectID) (*models.ChatSession, error) {
+	ctx, span := tracing.Start(ctx, "ChatService.GetChatSession")
+	defer span.End()
 
-	cursor, err := collection.Find(
-		context.Background(),
-		bson.M{"user_id": userID},
-		// Sort by most recent first
-	)
+	session, err := s.findSession(ctx, sessionID, userID)
 	if err != nil {
 		return nil, err
 	}
-	defer cursor.Close(context.Background())
 
-	var sessions []models.ChatSession
-	if err = cursor.All(context.Background(), &sessions); err != nil {
+	if err := s.loadLatestMessages(ctx, session); err != nil {
 		return nil, err
 	}
 
-	return sessions, nil
+	return session, nil
 }
 
-func (s *ChatService) GetChatSession(sessionID primitive.ObjectID, userID primitive.ObjectID) (*models.ChatSession, error) {
-	collection := s.db.GetCollection("chat_sessions")
+// GetMessages returns up to limit messages of a session sent before the
+// message with sequence number before, or the latest ones if before is 0.
+func (s *ChatService) GetMessages(ctx context.Context, sessionID primitive.ObjectID, userID primitive.ObjectID, before int64, limit int) (*models.MessagePage, error) {
+	ctx, span := tracing.Start(ctx, "ChatService.GetMessages")
+	defer span.End()
+
+	if _, err := s.findSession(ctx, sessionID, userID); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = defaultMessagePageSize
+	}
+	limit = min(limit, maxMessagePageSize)
 
-	var session models.ChatSession
-	err := collection.FindOne(context.Background(), bson.M{"_id": sessionID, "user_id": userID}).Decode(&session)
+	messages, hasMore, err := s.messagePage(ctx, sessionID, before, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	return &session, nil
+	return &models.MessagePage{Messages: messages, HasMore: hasMore}, nil
+}
+
+// findSession returns the user's session, or ErrChatSessionNotFound.
+func (s *ChatService) findSession(ctx context.Context, sessionID, userID primitive.ObjectID) (*models.ChatSession, error) {
+	session, err := s.sessions.FindByID(ctx, sessionID, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrChatSessionNotFound
+	}
+	return session, err
+}
+
+func (s *ChatService) loadLatestMessages(ctx context.Context, session *models.ChatSession) error {
+	messages, hasMore, err := s.messagePage(ctx, session.ID, 0, defaultMessagePageSize)
+	if err != nil {
+		return err
+	}
+
+	session.Messages = messages
+	session.HasMoreMessages = hasMore
+	return nil
+}
+
+// messagePage fetches one message more than asked for to tell whether
+// older messages remain.
+func (s *ChatService) messagePage(ctx context.Context, sessionID primitive.ObjectID, before int64, limit int) ([]models.Message, bool, error) {
+	messages, err := s.messages.List(ctx, sessionID, before, limit+1)
+	if err != nil {
+		return nil, false, err
+	}
+
+	hasMore := len(messages) > limit
+	if hasMore {
+		messages = messages[1:]
+	}
+
+	if err := s.signAttachmentURLs(messages); err != nil {
+		return nil, false, err
+	}
+	return messages, hasMore, nil
+}
+
+// SignedFileURL returns a short-lived link to an uploaded image, image
+// variant or document. Callers must only hand it to the owner of the session
+// the file belongs to.
+func (s *ChatService) SignedFileURL(key string) (string, error) {
+	return s.storage.SignedURL(key, s.signedURLTTL)
+}
+
+func (s *ChatService) signAttachmentURLs(messages []models.Message) error {
+	var err error
+	for i := range messages {
+		for j := range messages[i].Attachments {
+			attachment := &messages[i].Attachments[j]
+			if attachment.URL, err = s.SignedFileURL(attachment.Key); err != nil {
+				return err
+			}
+
+			for k := range attachment.Variants {
+				variant := &attachment.Variants[k]
+				if variant.URL, err = s.SignedFileURL(variant.Key); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *ChatService) imageURLs(attachments []models.Attachment) ([]string, error) {
+	var urls []string
+	for _, attachment := range attachments {
+		if attachment.Kind != models.AttachmentKindImage {
+			continue
+		}
+
+		url, err := s.SignedFileURL(attachment.Key)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, url)
+	}
+	return urls, nil
 }
 
 func (s *ChatService) buildConversationContext(messages []models.Message) string {
@@ -203,6 +538,13 @@ func (s *ChatService) buildConversationContext(messages []models.Message) string
 	for _, msg := range messages {
 		if msg.Sender == "user" {
 			context.WriteString(fmt.Sprintf("Patient: %s\n", msg.Content))
+			for _, attachment := range msg.Attachments {
+				if attachment.Kind == models.AttachmentKindDocument {
+					context.WriteString(documentContext(attachment))
+				} else {
+					context.WriteString("[Shared an image]\n")
+				}
+			}
 		} else if msg.Sender == "ai" {
 			context.WriteString(fmt.Sprintf("AI Doctor: %s\n", msg.Content))
 		}
@@ -210,6 +552,54 @@ func (s *ChatService) buildConversationContext(messages []models.Message) string
 	return context.String()
 }
 
+func hasDocuments(messages []models.Message) bool {
+	for _, msg := range messages {
+		for _, attachment := range msg.Attachments {
+			if attachment.Kind == models.AttachmentKindDocument {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// maxDocumentContextChars keeps very long documents from crowding out the
+// rest of the prompt.
+const maxDocumentContextChars = 20000
+
+// documentContext renders a shared document's text for the AI doctor with a
+// marker per page so it can cite page numbers.
+func documentContext(document models.Attachment) string {
+	if !document.HasText {
+		return fmt.Sprintf("[Shared document %q contains no readable text, it may be a scanned image. Ask the patient to type the values they want to discuss.]\n", document.FileName)
+	}
+
+	var context strings.Builder
+	context.WriteString(fmt.Sprintf("[Shared document %q, %d pages]\n", document.FileName, document.PageCount))
+
+	remaining := maxDocumentContextChars
+	for _, page := range document.Pages {
+		if page.Text == "" {
+			continue
+		}
+		if remaining <= 0 {
+			context.WriteString("[Remaining pages omitted]\n")
+			break
+		}
+
+		text := page.Text
+		if len(text) > remaining {
+			text = strings.ToValidUTF8(text[:remaining], "") + " [truncated]"
+		}
+		remaining -= len(page.Text)
+
+		context.WriteString(fmt.Sprintf("--- Page %d ---\n%s\n", page.Number, text))
+	}
+	context.WriteString("[End of document]\n")
+
+	return context.String()
+}
+
 func (s *ChatService) shouldRecommendRealDoctor(aiResponse, userMessage string) bool {
 	// Simple keyword-based detection for recommending real doctor
 	concerningKeywords := []string{
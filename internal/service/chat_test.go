@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestChatConsultation(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	user := s.register(t, "asha@example.com", "secret1")
+
+	session, err := s.chat.StartChatSession(ctx, user.ID, testDoctor.ID, primitive.NilObjectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(session.Messages) != 1 || session.Messages[0].Sender != "ai" {
+		t.Fatalf("new session messages = %+v, want the welcome message", session.Messages)
+	}
+
+	resumed, err := s.chat.StartChatSession(ctx, user.ID, testDoctor.ID, primitive.NilObjectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resumed.ID != session.ID {
+		t.Errorf("StartChatSession() = %s, want the active session %s", resumed.ID.Hex(), session.ID.Hex())
+	}
+
+	reply, err := s.chat.SendMessage(ctx, session.ID, user.ID, "I have a mild headache", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Content != s.llm.reply || reply.Sender != "ai" {
+		t.Errorf("reply = %+v, want the LLM's answer", reply)
+	}
+	if prompt := s.llm.lastPrompt(); !strings.Contains(prompt, "I have a mild headache") {
+		t.Errorf("prompt %q does not contain the user's message", prompt)
+	}
+
+	page, err := s.chat.GetMessages(ctx, session.ID, user.ID, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Messages) != 3 {
+		t.Fatalf("got %d messages, want welcome, question and reply", len(page.Messages))
+	}
+	for _, message := range page.Messages {
+		if message.ReplyStatus != "" {
+			t.Errorf("message %d reply status = %q, want none", message.Seq, message.ReplyStatus)
+		}
+	}
+
+	history, err := s.chat.GetChatHistory(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].LastMessage.Content != s.llm.reply {
+		t.Errorf("history = %+v, want the session previewing the reply", history)
+	}
+
+	summaries, err := s.usage.Summarize(ctx, UsageByPersona, UsageFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 || summaries[0].Key != testDoctor.ID || summaries[0].Calls != 1 || summaries[0].CostUSD == 0 {
+		t.Errorf("usage = %+v, want one priced call of %s", summaries, testDoctor.ID)
+	}
+}
+
+func TestChatSessionsArePrivate(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	owner := s.register(t, "asha@example.com", "secret1")
+	other := s.register(t, "ravi@example.com", "secret1")
+
+	session, err := s.chat.StartChatSession(ctx, owner.ID, testDoctor.ID, primitive.NilObjectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.chat.SendMessage(ctx, session.ID, other.ID, "hello", nil); !errors.Is(err, ErrChatSessionNotFound) {
+		t.Errorf("SendMessage() by another user error = %v, want %v", err, ErrChatSessionNotFound)
+	}
+	if _, err := s.chat.GetMessages(ctx, session.ID, other.ID, 0, 0); !errors.Is(err, ErrChatSessionNotFound) {
+		t.Errorf("GetMessages() by another user error = %v, want %v", err, ErrChatSessionNotFound)
+	}
+}
+
+func TestChatRetriesFailedReply(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	user := s.register(t, "asha@example.com", "secret1")
+
+	session, err := s.chat.StartChatSession(ctx, user.ID, testDoctor.ID, primitive.NilObjectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.llm.fail(errors.New("model overloaded"))
+	_, err = s.chat.SendMessage(ctx, session.ID, user.ID, "I have a mild headache", nil)
+	if code := errorCode(err); code != "reply_pending" {
+		t.Fatalf("SendMessage() error = %v, want reply_pending", err)
+	}
+
+	pending, err := s.repos.Messages.FindPendingReply(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("the message was not kept pending: %v", err)
+	}
+	if !pending.ReplyClaimedUntil.IsZero() {
+		t.Errorf("claim kept until %v after the failure, want released", pending.ReplyClaimedUntil)
+	}
+
+	s.llm.fail(nil)
+	reply, err := s.chat.RetryReply(ctx, session.ID, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Content != s.llm.reply {
+		t.Errorf("reply = %q, want %q", reply.Content, s.llm.reply)
+	}
+
+	if _, err := s.chat.RetryReply(ctx, session.ID, user.ID); !errors.Is(err, ErrNoPendingReply) {
+		t.Errorf("second RetryReply() error = %v, want %v", err, ErrNoPendingReply)
+	}
+}
+
+func TestChatSharesConsentedHealthProfile(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	user := s.register(t, "asha@example.com", "secret1")
+
+	_, err := s.healthProfile.UpdateProfile(ctx, user.ID, models.HealthProfileRequest{
+		HealthDetailsRequest: models.HealthDetailsRequest{Allergies: []string{"penicillin"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dependent, err := s.healthProfile.AddDependent(ctx, user.ID, models.DependentRequest{
+		Name:                 "Mira",
+		Relationship:         "child",
+		HealthDetailsRequest: models.HealthDetailsRequest{ChronicConditions: []string{"asthma"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := s.chat.StartChatSession(ctx, user.ID, testDoctor.ID, dependent.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is shared before the user consents
+	if _, err := s.chat.SendMessage(ctx, session.ID, user.ID, "She is coughing", nil); err != nil {
+		t.Fatal(err)
+	}
+	if prompt := s.llm.lastPrompt(); strings.Contains(prompt, "asthma") {
+		t.Errorf("prompt %q shares the profile without consent", prompt)
+	}
+
+	_, err = s.healthProfile.UpdateProfile(ctx, user.ID, models.HealthProfileRequest{
+		HealthDetailsRequest: models.HealthDetailsRequest{Allergies: []string{"penicillin"}},
+		AIContextConsent:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.chat.SendMessage(ctx, session.ID, user.ID, "She is still coughing", nil); err != nil {
+		t.Fatal(err)
+	}
+	prompt := s.llm.lastPrompt()
+	if !strings.Contains(prompt, "their child, Mira") || !strings.Contains(prompt, "asthma") {
+		t.Errorf("prompt %q does not describe the dependent", prompt)
+	}
+	if strings.Contains(prompt, "penicillin") {
+		t.Errorf("prompt %q describes the user instead of the dependent", prompt)
+	}
+}
+
+func TestChatStopsAtMonthlyBudget(t *testing.T) {
+	s := newTestServices(t)
+	ctx := context.Background()
+	user := s.register(t, "asha@example.com", "secret1")
+
+	session, err := s.chat.StartChatSession(ctx, user.ID, testDoctor.ID, primitive.NilObjectID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One call costs 0.0002 USD at the test prices
+	if _, err := s.usage.SetBudget(ctx, user.ID, 0.0001); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.chat.SendMessage(ctx, session.ID, user.ID, "hello", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.chat.SendMessage(ctx, session.ID, user.ID, "hello again", nil)
+	if !errors.Is(err, ErrRateLimited) || errorCode(err) != "budget_exceeded" {
+		t.Errorf("SendMessage() over budget error = %v, want budget_exceeded", err)
+	}
+
+	status, err := s.usage.ResetBudget(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Custom || status.SpentUSD == 0 {
+		t.Errorf("budget after reset = %+v, want the default with the spending kept", status)
+	}
+}
+
+func TestCheckReplyLease(t *testing.T) {
+	for _, lease := range []time.Duration{0, replyStoreTime} {
+		if err := CheckReplyLease(lease); err == nil {
+			t.Errorf("CheckReplyLease(%s) = nil, want an error as no time is left to generate", lease)
+		}
+	}
+	if err := CheckReplyLease(2 * time.Minute); err != nil {
+		t.Errorf("CheckReplyLease(2m) error = %v", err)
+	}
+}
@@ -2,82 +2,56 @@ package service
 
 import (
 	"context"
+	"errors"
 
-	"github.com/subhammahanty235/medai/internal/db"
 	"github.com/subhammahanty235/medai/internal/models"
-
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/subhammahanty235/medai/internal/repository"
+	"github.com/subhammahanty235/medai/internal/tracing"
 )
 
+// ErrDoctorNotFound is returned for unknown AI doctors.
+var ErrDoctorNotFound = NotFound("doctor_not_found", "Doctor not found")
+
 type DoctorService struct {
-	db *db.Database
+	doctors     repository.DoctorRepository
+	realDoctors repository.RealDoctorRepository
 }
 
-func NewDoctorService(database *db.Database) *DoctorService {
+func NewDoctorService(doctors repository.DoctorRepository, realDoctors repository.RealDoctorRepository) *DoctorService {
 	return &DoctorService{
-		db: database,
+		doctors:     doctors,
+		realDoctors: realDoctors,
 	}
 }
 
-func (s *DoctorService) GetAllDoctors() ([]models.Doctor, error) {
-	collection := s.db.GetCollection("doctors")
-
-	cursor, err := collection.Find(context.Background(), bson.M{"is_ai": true})
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(context.Background())
-
-	var doctors []models.Doctor
-	if err = cursor.All(context.Background(), &doctors); err != nil {
-		return nil, err
-	}
+func (s *DoctorService) GetAllDoctors(ctx context.Context) ([]models.Doctor, error) {
+	ctx, span := tracing.Start(ctx, "DoctorService.GetAllDoctors")
+	defer span.End()
 
-	return doctors, nil
+	return s.doctors.ListAI(ctx)
 }
 
-func (s *DoctorService) GetDoctorByID(doctorID string) (*models.Doctor, error) {
-	collection := s.db.GetCollection("doctors")
+func (s *DoctorService) GetDoctorByID(ctx context.Context, doctorID string) (*models.Doctor, error) {
+	ctx, span := tracing.Start(ctx, "DoctorService.GetDoctorByID")
+	defer span.End()
 
-	var doctor models.Doctor
-	err := collection.FindOne(context.Background(), bson.M{"_id": doctorID}).Decode(&doctor)
-	if err != nil {
-		return nil, err
+	doctor, err := s.doctors.FindByID(ctx, doctorID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrDoctorNotFound
 	}
-
-	return &doctor, nil
+	return doctor, err
 }
 
-func (s *DoctorService) GetRealDoctorsBySpecialty(specialty string) ([]models.RealDoctor, error) {
-	collection := s.db.GetCollection("real_doctors")
+func (s *DoctorService) GetRealDoctorsBySpecialty(ctx context.Context, specialty string) ([]models.RealDoctor, error) {
+	ctx, span := tracing.Start(ctx, "DoctorService.GetRealDoctorsBySpecialty")
+	defer span.End()
 
-	cursor, err := collection.Find(context.Background(), bson.M{"specialty": specialty})
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(context.Background())
-
-	var doctors []models.RealDoctor
-	if err = cursor.All(context.Background(), &doctors); err != nil {
-		return nil, err
-	}
-
-	return doctors, nil
+	return s.realDoctors.ListBySpecialty(ctx, specialty)
 }
 
-func (s *DoctorService) GetAllRealDoctors() ([]models.RealDoctor, error) {
-	collection := s.db.GetCollection("real_doctors")
-
-	cursor, err := collection.Find(context.Background(), bson.M{})
-	if err != nil {
-		return nil, err
-	}
-	defer cursor.Close(context.Background())
-
-	var doctors []models.RealDoctor
-	if err = cursor.All(context.Background(), &doctors); err != nil {
-		return nil, err
-	}
+func (s *DoctorService) GetAllRealDoctors(ctx context.Context) ([]models.RealDoctor, error) {
+	ctx, span := tracing.Start(ctx, "DoctorService.GetAllRealDoctors")
+	defer span.End()
 
-	return doctors, nil
+	return s.realDoctors.List(ctx)
 }
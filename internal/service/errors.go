@@ -0,0 +1,80 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/models"
+)
+
+// Kinds of domain errors. errors.Is(err, ErrNotFound) reports whether err is
+// an *Error of that kind.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrForbidden    = errors.New("forbidden")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrValidation   = errors.New("validation failed")
+	ErrUnavailable  = errors.New("upstream unavailable")
+	ErrRateLimited  = errors.New("rate limited")
+)
+
+// Error is a failure the client may be told about. Message is safe to show
+// and Code is a stable identifier for programs; Fields lists the offending
+// fields of invalid input. Err is the underlying cause, which is logged but
+// never shown. RetryAfter tells rate-limited clients when to try again.
+type Error struct {
+	Kind       error
+	Code       string
+	Message    string
+	Fields     []models.FieldError
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap makes both the kind and the cause visible to errors.Is and
+// errors.As, so a timed-out upstream call is still a deadline error.
+func (e *Error) Unwrap() []error {
+	if e.Err != nil {
+		return []error{e.Kind, e.Err}
+	}
+	return []error{e.Kind}
+}
+
+func NotFound(code, message string) *Error {
+	return &Error{Kind: ErrNotFound, Code: code, Message: message}
+}
+
+func Conflict(code, message string) *Error {
+	return &Error{Kind: ErrConflict, Code: code, Message: message}
+}
+
+func Forbidden(code, message string) *Error {
+	return &Error{Kind: ErrForbidden, Code: code, Message: message}
+}
+
+func Unauthorized(code, message string) *Error {
+	return &Error{Kind: ErrUnauthorized, Code: code, Message: message}
+}
+
+func Invalid(code, message string, fields ...models.FieldError) *Error {
+	return &Error{Kind: ErrValidation, Code: code, Message: message, Fields: fields}
+}
+
+// Unavailable reports that a dependency such as the LLM failed; cause is
+// kept for logging.
+func Unavailable(code, message string, cause error) *Error {
+	return &Error{Kind: ErrUnavailable, Code: code, Message: message, Err: cause}
+}
+
+// RateLimited refuses a request until retryAfter has passed.
+func RateLimited(code, message string, retryAfter time.Duration) *Error {
+	return &Error{Kind: ErrRateLimited, Code: code, Message: message, RetryAfter: retryAfter}
+}
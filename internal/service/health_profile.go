@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/repository"
+	"github.com/subhammahanty235/medai/internal/tracing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrDependentNotFound is returned for dependents the user does not have.
+var ErrDependentNotFound = NotFound("dependent_not_found", "dependent not found")
+
+type HealthProfileService struct {
+	profiles repository.HealthProfileRepository
+}
+
+func NewHealthProfileService(profiles repository.HealthProfileRepository) *HealthProfileService {
+	return &HealthProfileService{
+		profiles: profiles,
+	}
+}
+
+// GetProfile returns the user's health profile, or an empty one if none has
+// been saved yet.
+func (s *HealthProfileService) GetProfile(ctx context.Context, userID primitive.ObjectID) (*models.HealthProfile, error) {
+	ctx, span := tracing.Start(ctx, "HealthProfileService.GetProfile")
+	defer span.End()
+
+	profile, err := s.profiles.FindByUser(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return &models.HealthProfile{
+			UserID:     userID,
+			Self:       healthDetails(models.HealthDetailsRequest{}),
+			Dependents: []models.Dependent{},
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+// UpdateProfile replaces the user's own health details and consent setting.
+func (s *HealthProfileService) UpdateProfile(ctx context.Context, userID primitive.ObjectID, req models.HealthProfileRequest) (*models.HealthProfile, error) {
+	ctx, span := tracing.Start(ctx, "HealthProfileService.UpdateProfile")
+	defer span.End()
+
+	current, err := s.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var consentUpdatedAt time.Time
+	if current.ID.IsZero() || current.AIContextConsent != req.AIContextConsent {
+		consentUpdatedAt = time.Now()
+	}
+
+	err = s.profiles.UpdateSelf(ctx, userID, healthDetails(req.HealthDetailsRequest), req.AIContextConsent, consentUpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetProfile(ctx, userID)
+}
+
+func (s *HealthProfileService) DeleteProfile(ctx context.Context, userID primitive.ObjectID) error {
+	ctx, span := tracing.Start(ctx, "HealthProfileService.DeleteProfile")
+	defer span.End()
+
+	return s.profiles.DeleteByUser(ctx, userID)
+}
+
+func (s *HealthProfileService) AddDependent(ctx context.Context, userID primitive.ObjectID, req models.DependentRequest) (*models.Dependent, error) {
+	ctx, span := tracing.Start(ctx, "HealthProfileService.AddDependent")
+	defer span.End()
+
+	dependent := models.Dependent{
+		ID:            primitive.NewObjectID(),
+		Name:          req.Name,
+		Relationship:  req.Relationship,
+		HealthDetails: healthDetails(req.HealthDetailsRequest),
+	}
+
+	if err := s.profiles.AddDependent(ctx, userID, dependent); err != nil {
+		return nil, err
+	}
+
+	return &dependent, nil
+}
+
+func (s *HealthProfileService) UpdateDependent(ctx context.Context, userID, dependentID primitive.ObjectID, req models.DependentRequest) (*models.Dependent, error) {
+	ctx, span := tracing.Start(ctx, "HealthProfileService.UpdateDependent")
+	defer span.End()
+
+	dependent := models.Dependent{
+		ID:            dependentID,
+		Name:          req.Name,
+		Relationship:  req.Relationship,
+		HealthDetails: healthDetails(req.HealthDetailsRequest),
+	}
+
+	err := s.profiles.UpdateDependent(ctx, userID, dependent)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrDependentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &dependent, nil
+}
+
+func (s *HealthProfileService) DeleteDependent(ctx context.Context, userID, dependentID primitive.ObjectID) error {
+	ctx, span := tracing.Start(ctx, "HealthProfileService.DeleteDependent")
+	defer span.End()
+
+	err := s.profiles.RemoveDependent(ctx, userID, dependentID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrDependentNotFound
+	}
+	return err
+}
+
+// GetDependent returns one of the user's dependents.
+func (s *HealthProfileService) GetDependent(ctx context.Context, userID, dependentID primitive.ObjectID) (*models.Dependent, error) {
+	ctx, span := tracing.Start(ctx, "HealthProfileService.GetDependent")
+	defer span.End()
+
+	profile, err := s.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dependent := range profile.Dependents {
+		if dependent.ID == dependentID {
+			return &dependent, nil
+		}
+	}
+
+	return nil, ErrDependentNotFound
+}
+
+// BuildAIContext describes the person a consultation is about for the AI
+// doctor. It returns "" unless the user has consented to sharing their
+// profile. dependentID selects a dependent instead of the user.
+func (s *HealthProfileService) BuildAIContext(ctx context.Context, userID, dependentID primitive.ObjectID) (string, error) {
+	ctx, span := tracing.Start(ctx, "HealthProfileService.BuildAIContext")
+	defer span.End()
+
+	profile, err := s.GetProfile(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	if !profile.AIContextConsent {
+		return "", nil
+	}
+
+	var context strings.Builder
+	details := profile.Self
+
+	if !dependentID.IsZero() {
+		dependent, err := s.GetDependent(ctx, userID, dependentID)
+		if err != nil {
+			return "", err
+		}
+		details = dependent.HealthDetails
+		context.WriteString(fmt.Sprintf("The user is consulting on behalf of their %s, %s.\n", dependent.Relationship, dependent.Name))
+	} else {
+		context.WriteString("The user is consulting about themselves.\n")
+	}
+
+	if details.DateOfBirth != nil {
+		context.WriteString(fmt.Sprintf("Age: %s\n", describeAge(*details.DateOfBirth, time.Now())))
+	}
+	if details.Sex != "" {
+		context.WriteString(fmt.Sprintf("Sex: %s\n", details.Sex))
+	}
+	if len(details.Allergies) > 0 {
+		context.WriteString(fmt.Sprintf("Allergies: %s\n", strings.Join(details.Allergies, ", ")))
+	}
+	if len(details.ChronicConditions) > 0 {
+		context.WriteString(fmt.Sprintf("Chronic conditions: %s\n", strings.Join(details.ChronicConditions, ", ")))
+	}
+	if len(details.CurrentMedications) > 0 {
+		medications := make([]string, len(details.CurrentMedications))
+		for i, medication := range details.CurrentMedications {
+			medications[i] = strings.TrimSpace(strings.Join([]string{medication.Name, medication.Dosage, medication.Frequency}, " "))
+		}
+		context.WriteString(fmt.Sprintf("Current medications: %s\n", strings.Join(medications, "; ")))
+	}
+
+	return context.String(), nil
+}
+
+func healthDetails(req models.HealthDetailsRequest) models.HealthDetails {
+	details := models.HealthDetails{
+		DateOfBirth:        req.DateOfBirth,
+		Sex:                req.Sex,
+		Allergies:          req.Allergies,
+		ChronicConditions:  req.ChronicConditions,
+		CurrentMedications: req.CurrentMedications,
+	}
+
+	// Store empty lists rather than null so clients can rely on arrays
+	if details.Allergies == nil {
+		details.Allergies = []string{}
+	}
+	if details.ChronicConditions == nil {
+		details.ChronicConditions = []string{}
+	}
+	if details.CurrentMedications == nil {
+		details.CurrentMedications = []models.Medication{}
+	}
+
+	return details
+}
+
+// describeAge renders an age the way a clinician would: in months for
+// infants and in years otherwise.
+func describeAge(dateOfBirth, now time.Time) string {
+	months := (now.Year()-dateOfBirth.Year())*12 + int(now.Month()-dateOfBirth.Month())
+	if now.Day() < dateOfBirth.Day() {
+		months--
+	}
+	if months < 0 {
+		months = 0
+	}
+
+	if months < 24 {
+		return fmt.Sprintf("%d months", months)
+	}
+	return fmt.Sprintf("%d years", months/12)
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/repository"
+	"github.com/subhammahanty235/medai/internal/storage"
+	"github.com/subhammahanty235/medai/internal/tracing"
+	"github.com/subhammahanty235/medai/internal/upload"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ImageVariantService generates thumbnails and web versions of uploaded chat
+// images in the background so uploads are not held up by resizing.
+type ImageVariantService struct {
+	messages repository.MessageRepository
+	storage  storage.Storage
+	slots    chan struct{}
+	logger   *slog.Logger
+
+	mu      sync.Mutex
+	stopped bool
+	stop    chan struct{}
+	running sync.WaitGroup
+}
+
+func NewImageVariantService(messages repository.MessageRepository, storage storage.Storage, workers int, logger *slog.Logger) *ImageVariantService {
+	return &ImageVariantService{
+		messages: messages,
+		storage:  storage,
+		slots:    make(chan struct{}, max(1, workers)),
+		logger:   logger,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Enqueue schedules variant generation for a stored image. Every
+// attachment in the session that references the image is updated. At most
+// the configured number of images are processed at once. Images enqueued
+// after Shutdown stay pending until ResumePending runs on the next start.
+func (s *ImageVariantService) Enqueue(ctx context.Context, sessionID primitive.ObjectID, imageKey string) {
+	ctx, span := tracing.Start(ctx, "ImageVariantService.Enqueue")
+	defer span.End()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return
+	}
+
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+
+		select {
+		case s.slots <- struct{}{}:
+		case <-s.stop:
+			return
+		}
+		defer func() { <-s.slots }()
+
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+
+		s.process(ctx, sessionID, imageKey)
+	}()
+}
+
+// Shutdown stops taking new images and waits for those being processed to
+// finish. Images still waiting for a worker are left pending.
+func (s *ImageVariantService) Shutdown(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "ImageVariantService.Shutdown")
+	defer span.End()
+
+	s.mu.Lock()
+	if !s.stopped {
+		s.stopped = true
+		close(s.stop)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ResumePending re-enqueues images whose processing was interrupted, for
+// example by a restart.
+func (s *ImageVariantService) ResumePending(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "ImageVariantService.ResumePending")
+	defer span.End()
+
+	messages, err := s.messages.ListWithPendingAttachments(ctx)
+	if err != nil {
+		return err
+	}
+
+	type image struct {
+		sessionID primitive.ObjectID
+		key       string
+	}
+	enqueued := map[image]bool{}
+
+	for _, message := range messages {
+		for _, attachment := range message.Attachments {
+			pending := image{message.SessionID, attachment.Key}
+			if attachment.Kind == models.AttachmentKindImage && attachment.Status == models.AttachmentStatusPending && !enqueued[pending] {
+				enqueued[pending] = true
+				s.Enqueue(ctx, message.SessionID, attachment.Key)
+			}
+		}
+	}
+
+	return nil
+}
+
+// process runs in the background, traced separately from the request that
+// enqueued the image.
+func (s *ImageVariantService) process(origin context.Context, sessionID primitive.ObjectID, imageKey string) {
+	ctx, span := tracing.StartBackground(origin, "ImageVariantService.process")
+	defer span.End()
+
+	variants, err := s.generate(ctx, imageKey)
+	tracing.RecordError(span, err)
+
+	status := models.AttachmentStatusReady
+	switch {
+	case errors.Is(err, upload.ErrNoDecoder):
+		status = models.AttachmentStatusUnsupported
+	case err != nil:
+		s.logger.ErrorContext(ctx, "Error generating image variants", "key", imageKey, "error", err)
+		status = models.AttachmentStatusFailed
+	}
+
+	err = s.messages.UpdateAttachments(ctx, sessionID, imageKey, status, variants)
+
+	// The session was deleted while processing, so nothing references the
+	// variants any more
+	if errors.Is(err, repository.ErrNotFound) {
+		for _, variant := range variants {
+			s.storage.Delete(ctx, variant.Key)
+		}
+		return
+	}
+	if err != nil {
+		s.logger.ErrorContext(ctx, "Error saving image variants", "key", imageKey, "error", err)
+	}
+}
+
+func (s *ImageVariantService) generate(ctx context.Context, imageKey string) ([]models.ImageVariant, error) {
+	body, _, err := s.storage.Get(ctx, imageKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	generated, err := upload.GenerateVariants(data, upload.ImageVariants)
+	if err != nil {
+		return nil, err
+	}
+
+	variants := make([]models.ImageVariant, 0, len(generated))
+	for _, variant := range generated {
+		key := variantKey(imageKey, variant.Name)
+		if err := s.storage.Put(ctx, key, variant.Reader(), variant.Size(), upload.TypeJPEG); err != nil {
+			return nil, err
+		}
+
+		variants = append(variants, models.ImageVariant{
+			Name:        variant.Name,
+			Key:         key,
+			ContentType: upload.TypeJPEG,
+			Width:       variant.Width,
+			Height:      variant.Height,
+			Size:        variant.Size(),
+		})
+	}
+
+	return variants, nil
+}
+
+// variantKey stores a variant next to its original, e.g.
+// chat-images/<id>.heic becomes chat-images/<id>_thumbnail.jpg.
+func variantKey(imageKey, name string) string {
+	return strings.TrimSuffix(imageKey, path.Ext(imageKey)) + "_" + name + ".jpg"
+}
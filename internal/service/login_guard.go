@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/repository"
+	"github.com/subhammahanty235/medai/internal/tracing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// loginAttemptRetention is how long failed attempts are remembered after
+// the last one, unless a lockout or IP window lasts longer.
+const loginAttemptRetention = 24 * time.Hour
+
+type LoginGuardConfig struct {
+	MaxAttempts     int
+	LockoutDuration time.Duration
+	BackoffBase     time.Duration
+	BackoffMax      time.Duration
+	IPMaxAttempts   int
+	IPWindow        time.Duration
+}
+
+// LoginThrottledError is returned when a login attempt is refused because of
+// earlier failures. The message is identical for known and unknown emails.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginThrottledError) Error() string {
+	return "too many login attempts, please try again later"
+}
+
+// LoginGuard tracks failed logins per email and per client IP. Repeated
+// failures for an email are slowed down with exponential backoff and end in a
+// temporary lockout; an IP is throttled once it exceeds its failure budget
+// for the current window.
+type LoginGuard struct {
+	attempts repository.LoginAttemptRepository
+	cfg      LoginGuardConfig
+	audit    *AuditService
+}
+
+func NewLoginGuard(attempts repository.LoginAttemptRepository, cfg LoginGuardConfig, audit *AuditService) *LoginGuard {
+	return &LoginGuard{
+		attempts: attempts,
+		cfg:      cfg,
+		audit:    audit,
+	}
+}
+
+// GuardedLogin is a login attempt counted by Begin. End it with
+// RecordFailure or RecordSuccess once the credentials are checked.
+type GuardedLogin struct {
+	email string
+	ip    string
+	// Attempts counted against the email, including this one
+	failures int
+}
+
+// Begin counts a login attempt against the IP and the email before the
+// credentials are checked, and returns a *LoginThrottledError if it may not
+// go ahead. Counting first means concurrent attempts see each other: only
+// one of a burst passes the backoff, and the refused ones count too, so a
+// burst of guesses ends in a lockout.
+func (g *LoginGuard) Begin(ctx context.Context, email, ip string) (*GuardedLogin, error) {
+	ctx, span := tracing.Start(ctx, "LoginGuard.Begin")
+	defer span.End()
+
+	// Count the attempt even if the client disconnects before the answer,
+	// which would otherwise be a way around throttling
+	ctx = context.WithoutCancel(ctx)
+	now := time.Now()
+
+	if err := g.beginIP(ctx, ip, now); err != nil {
+		return nil, err
+	}
+
+	before, err := g.attempts.RecordAttempt(ctx, emailKey(email), now, now.Add(g.retention()))
+	if errors.Is(err, repository.ErrLocked) {
+		return nil, &LoginThrottledError{RetryAfter: before.LockedUntil.Sub(now)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	login := &GuardedLogin{email: email, ip: ip, failures: before.Failures + 1}
+
+	if g.cfg.MaxAttempts > 0 && login.failures > g.cfg.MaxAttempts {
+		lockedUntil, err := g.lock(ctx, login, primitive.NilObjectID, now)
+		if err != nil {
+			return nil, err
+		}
+		return nil, &LoginThrottledError{RetryAfter: lockedUntil.Sub(now)}
+	}
+
+	if before.Failures > 0 {
+		nextAllowed := before.LastFailure.Add(g.backoff(before.Failures))
+		if now.Before(nextAllowed) {
+			return nil, &LoginThrottledError{RetryAfter: nextAllowed.Sub(now)}
+		}
+	}
+
+	return login, nil
+}
+
+// beginIP counts the attempt against the IP's budget for the window.
+func (g *LoginGuard) beginIP(ctx context.Context, ip string, now time.Time) error {
+	before, err := g.attempts.RecordAttempt(ctx, ipKey(ip), now, now.Add(g.retention()))
+	if err != nil {
+		return err
+	}
+
+	// A window that has already elapsed starts over with this attempt
+	windowEnd := before.WindowStart.Add(g.cfg.IPWindow)
+	if !before.WindowStart.IsZero() && now.After(windowEnd) {
+		return g.attempts.RestartWindow(ctx, ipKey(ip), now)
+	}
+
+	failures := before.Failures + 1
+	if g.cfg.IPMaxAttempts <= 0 || failures <= g.cfg.IPMaxAttempts {
+		return nil
+	}
+
+	if failures == g.cfg.IPMaxAttempts+1 {
+		g.audit.Record(ctx, models.AuditEvent{
+			Type: "ip_throttled",
+			IP:   ip,
+			Details: map[string]string{
+				"failures":   fmt.Sprint(g.cfg.IPMaxAttempts),
+				"window_end": windowEnd.UTC().Format(time.RFC3339),
+			},
+		})
+	}
+	return &LoginThrottledError{RetryAfter: windowEnd.Sub(now)}
+}
+
+// RecordFailure ends an attempt whose credentials were wrong, locking the
+// email once it has used up its attempts. userID is NilObjectID when the
+// email does not belong to an account.
+func (g *LoginGuard) RecordFailure(ctx context.Context, login *GuardedLogin, userID primitive.ObjectID) error {
+	ctx, span := tracing.Start(ctx, "LoginGuard.RecordFailure")
+	defer span.End()
+
+	// The attempt is counted already
+	if g.cfg.MaxAttempts > 0 && login.failures >= g.cfg.MaxAttempts {
+		_, err := g.lock(context.WithoutCancel(ctx), login, userID, time.Now())
+		return err
+	}
+	return nil
+}
+
+// RecordSuccess clears the failure history of the email and takes the
+// attempt off the IP's count. The IP's earlier failures are left alone so
+// that logging into one account does not reset the budget for guessing
+// others.
+func (g *LoginGuard) RecordSuccess(ctx context.Context, login *GuardedLogin) error {
+	ctx, span := tracing.Start(ctx, "LoginGuard.RecordSuccess")
+	defer span.End()
+
+	if err := g.attempts.Decrement(ctx, ipKey(login.ip)); err != nil {
+		return err
+	}
+	return g.Forget(ctx, login.email)
+}
+
+// Forget clears the failed attempts recorded for email.
+func (g *LoginGuard) Forget(ctx context.Context, email string) error {
+	ctx, span := tracing.Start(ctx, "LoginGuard.Forget")
+	defer span.End()
+
+	return g.attempts.Delete(ctx, emailKey(email))
+}
+
+// lock locks the email of login for the lockout duration and returns when
+// it ends.
+func (g *LoginGuard) lock(ctx context.Context, login *GuardedLogin, userID primitive.ObjectID, now time.Time) (time.Time, error) {
+	lockedUntil := now.Add(g.cfg.LockoutDuration)
+	if err := g.attempts.Lock(ctx, emailKey(login.email), lockedUntil); err != nil {
+		return time.Time{}, err
+	}
+
+	g.audit.Record(ctx, models.AuditEvent{
+		Type:   "account_locked",
+		UserID: userID,
+		Email:  normalizeEmail(login.email),
+		IP:     login.ip,
+		Details: map[string]string{
+			"failures":     fmt.Sprint(login.failures),
+			"locked_until": lockedUntil.UTC().Format(time.RFC3339),
+		},
+	})
+	return lockedUntil, nil
+}
+
+// retention is how long attempts are kept after a failure, which outlasts
+// any lockout or IP window it starts.
+func (g *LoginGuard) retention() time.Duration {
+	return max(loginAttemptRetention, g.cfg.LockoutDuration, g.cfg.IPWindow)
+}
+
+func (g *LoginGuard) backoff(failures int) time.Duration {
+	delay := g.cfg.BackoffBase
+	for i := 1; i < failures && delay < g.cfg.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > g.cfg.BackoffMax {
+		delay = g.cfg.BackoffMax
+	}
+	return delay
+}
+
+func emailKey(email string) string {
+	return "email:" + normalizeEmail(email)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/tracing"
+	"github.com/subhammahanty235/medai/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	ErrInvalidMFAToken = Unauthorized("invalid_mfa_token", "invalid or expired MFA token")
+	// A wrong code ends a login attempt, but only rejects the request of a
+	// signed-in user
+	errMFALoginCode = Unauthorized("invalid_verification_code", "invalid verification code")
+	errMFACode      = Invalid("invalid_verification_code", "invalid verification code",
+		models.FieldError{Field: "code", Code: "invalid", Message: "invalid verification code"})
+	ErrMFAAlreadyEnabled = Conflict("mfa_already_enabled", "two-factor authentication is already enabled")
+	ErrMFANotEnabled     = Conflict("mfa_not_enabled", "two-factor authentication is not enabled")
+	ErrMFANotEnrolling   = Conflict("mfa_enrollment_not_started", "no pending two-factor enrollment")
+	ErrMFARequired       = Forbidden("mfa_required", "two-factor authentication is required for your role")
+	// errPasswordConfirmation rejects a wrong password given to confirm a
+	// sensitive change
+	errPasswordConfirmation = Invalid("incorrect_password", "invalid credentials",
+		models.FieldError{Field: "password", Code: "incorrect", Message: "password is incorrect"})
+)
+
+// VerifyMFA completes a two-step login by checking a TOTP or recovery code
+// against the account named in the MFA challenge token.
+func (s *AuthService) VerifyMFA(ctx context.Context, req models.MFAVerifyRequest, clientIP string) (*models.AuthResponse, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.VerifyMFA")
+	defer span.End()
+
+	claims, err := utils.ValidateTokenWithPurpose(req.MFAToken, s.jwtSecret, utils.TokenPurposeMFAChallenge)
+	if err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+
+	login, err := s.loginGuard.Begin(ctx, claims.Email, clientIP)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	ok, err := s.checkSecondFactor(ctx, user, req.Code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := s.loginGuard.RecordFailure(ctx, login, user.ID); err != nil {
+			return nil, err
+		}
+		return nil, errMFALoginCode
+	}
+
+	if err := s.loginGuard.RecordSuccess(ctx, login); err != nil {
+		return nil, err
+	}
+
+	token, err := utils.GenerateToken(user.ID, user.Email, userRole(user), user.TokenVersion, s.jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.AuthResponse{
+		Token: token,
+		User:  *user,
+	}, nil
+}
+
+// BeginMFAEnrollment generates a new TOTP secret and keeps it pending until
+// the user proves their authenticator produces valid codes.
+func (s *AuthService) BeginMFAEnrollment(ctx context.Context, userID primitive.ObjectID) (*models.MFAEnrollmentResponse, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.BeginMFAEnrollment")
+	defer span.End()
+
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFA.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.users.SetPendingTOTPSecret(ctx, userID, secret); err != nil {
+		return nil, err
+	}
+
+	return &models.MFAEnrollmentResponse{
+		Secret:          secret,
+		ProvisioningURI: utils.TOTPProvisioningURI(s.mfa.Issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmMFAEnrollment enables two-factor authentication once code matches
+// the pending secret. The plain recovery codes are returned only here, along
+// with a full access token for users who logged in with an enrollment token.
+func (s *AuthService) ConfirmMFAEnrollment(ctx context.Context, userID primitive.ObjectID, code string) (*models.MFARecoveryCodesResponse, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.ConfirmMFAEnrollment")
+	defer span.End()
+
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFA.PendingTOTPSecret == "" {
+		return nil, ErrMFANotEnrolling
+	}
+
+	step, ok := utils.ValidateTOTP(user.MFA.PendingTOTPSecret, code, time.Now())
+	if !ok {
+		return nil, errMFACode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.users.SetMFA(ctx, userID, models.MFASettings{
+		Enabled:       true,
+		TOTPSecret:    user.MFA.PendingTOTPSecret,
+		LastUsedStep:  step,
+		RecoveryCodes: hashes,
+		EnrolledAt:    time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := utils.GenerateToken(user.ID, user.Email, userRole(user), user.TokenVersion, s.jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MFARecoveryCodesResponse{
+		RecoveryCodes: codes,
+		Token:         token,
+	}, nil
+}
+
+// DisableMFA turns two-factor authentication off after re-checking both the
+// password and a current code. Roles that require MFA cannot disable it.
+func (s *AuthService) DisableMFA(ctx context.Context, userID primitive.ObjectID, req models.MFADisableRequest) error {
+	ctx, span := tracing.Start(ctx, "AuthService.DisableMFA")
+	defer span.End()
+
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.MFA.Enabled {
+		return ErrMFANotEnabled
+	}
+	if s.mfaRequired(user) {
+		return ErrMFARequired
+	}
+	if !utils.CheckPasswordHash(req.Password, user.Password) {
+		return errPasswordConfirmation
+	}
+
+	ok, err := s.checkSecondFactor(ctx, user, req.Code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errMFACode
+	}
+
+	return s.users.SetMFA(ctx, userID, models.MFASettings{})
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes after checking a
+// current code.
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID primitive.ObjectID, code string) (*models.MFARecoveryCodesResponse, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.RegenerateRecoveryCodes")
+	defer span.End()
+
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.MFA.Enabled {
+		return nil, ErrMFANotEnabled
+	}
+
+	ok, err := s.checkSecondFactor(ctx, user, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errMFACode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.users.SetRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return &models.MFARecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// checkSecondFactor accepts either a TOTP code that has not been used before
+// or an unused recovery code, consuming it atomically.
+func (s *AuthService) checkSecondFactor(ctx context.Context, user *models.User, code string) (bool, error) {
+	if step, ok := utils.ValidateTOTP(user.MFA.TOTPSecret, code, time.Now()); ok {
+		return s.users.ConsumeTOTPStep(ctx, user.ID, step)
+	}
+
+	return s.users.ConsumeRecoveryCode(ctx, user.ID, utils.HashRecoveryCode(code))
+}
+
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashRecoveryCode(code)
+	}
+
+	return codes, hashes, nil
+}
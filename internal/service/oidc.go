@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/repository"
+	"github.com/subhammahanty235/medai/internal/tracing"
+	"github.com/subhammahanty235/medai/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const oidcStateTTL = 10 * time.Minute
+
+var (
+	ErrUnknownProvider    = NotFound("unknown_provider", "unknown sign-in provider")
+	ErrInvalidSignInState = Unauthorized("invalid_sign_in_state", "invalid or expired sign-in state")
+	ErrUnverifiedEmail    = Forbidden("unverified_email", "sign-in provider did not return a verified email")
+	// ErrLinkRequired is returned for a provider account whose email
+	// belongs to an account that never verified it. Anyone could have
+	// registered that account, so the owner must sign in to it and link
+	// the provider instead.
+	ErrLinkRequired  = Conflict("link_required", "an account with this email already exists, sign in to it to link the provider")
+	ErrIdentityInUse = Conflict("identity_in_use", "this provider account is linked to another account")
+)
+
+// OIDCLogin is a sign-in started with a provider. The browser is sent to
+// AuthorizationURL and keeps Binding in an HttpOnly cookie until ExpiresAt;
+// the callback must present it, which ties the sign-in to the browser that
+// started it.
+type OIDCLogin struct {
+	AuthorizationURL string
+	Binding          string
+	ExpiresAt        time.Time
+}
+
+type OIDCService struct {
+	states      repository.OIDCLoginStateRepository
+	authService *AuthService
+	providers   map[string]*utils.OIDCProvider
+}
+
+func NewOIDCService(states repository.OIDCLoginStateRepository, authService *AuthService, providers ...*utils.OIDCProvider) *OIDCService {
+	byName := make(map[string]*utils.OIDCProvider, len(providers))
+	for _, provider := range providers {
+		byName[provider.Name] = provider
+	}
+
+	return &OIDCService{
+		states:      states,
+		authService: authService,
+		providers:   byName,
+	}
+}
+
+// Providers lists the names of the configured sign-in providers.
+func (s *OIDCService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	return names
+}
+
+// StartLogin records a fresh state, nonce and PKCE verifier for signing in
+// with the provider.
+func (s *OIDCService) StartLogin(ctx context.Context, providerName string) (*OIDCLogin, error) {
+	ctx, span := tracing.Start(ctx, "OIDCService.StartLogin")
+	defer span.End()
+
+	return s.start(ctx, providerName, primitive.NilObjectID)
+}
+
+// StartLink is StartLogin for a signed-in user linking a provider account
+// to theirs.
+func (s *OIDCService) StartLink(ctx context.Context, userID primitive.ObjectID, providerName string) (*OIDCLogin, error) {
+	ctx, span := tracing.Start(ctx, "OIDCService.StartLink")
+	defer span.End()
+
+	if _, err := s.authService.GetUserByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.start(ctx, providerName, userID)
+}
+
+func (s *OIDCService) start(ctx context.Context, providerName string, userID primitive.ObjectID) (*OIDCLogin, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	state, err := utils.RandomToken()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := utils.RandomToken()
+	if err != nil {
+		return nil, err
+	}
+	verifier := utils.NewPKCEVerifier()
+
+	loginState := &models.OIDCLoginState{
+		State:     state,
+		Provider:  providerName,
+		Verifier:  verifier,
+		Nonce:     nonce,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(oidcStateTTL),
+	}
+	if err := s.states.Create(ctx, loginState); err != nil {
+		return nil, err
+	}
+
+	return &OIDCLogin{
+		AuthorizationURL: provider.AuthCodeURL(state, nonce, verifier),
+		Binding:          state + "." + nonce,
+		ExpiresAt:        loginState.ExpiresAt,
+	}, nil
+}
+
+// CompleteLogin redeems the authorization code, resolves the local user and
+// continues with the regular login completion, including MFA. binding is the
+// Binding of the OIDCLogin the browser started; without it the callback
+// could be replayed in another browser, signing its user in to the account
+// of whoever started the sign-in.
+func (s *OIDCService) CompleteLogin(ctx context.Context, providerName string, req models.OIDCCallbackRequest, binding string) (*models.AuthResponse, error) {
+	ctx, span := tracing.Start(ctx, "OIDCService.CompleteLogin")
+	defer span.End()
+
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	state, nonce, _ := strings.Cut(binding, ".")
+	if subtle.ConstantTimeCompare([]byte(state), []byte(req.State)) != 1 {
+		return nil, ErrInvalidSignInState
+	}
+
+	// Each state can be used once
+	loginState, err := s.states.Take(ctx, req.State, providerName)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && time.Now().After(loginState.ExpiresAt)) {
+		return nil, ErrInvalidSignInState
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(nonce), []byte(loginState.Nonce)) != 1 {
+		return nil, ErrInvalidSignInState
+	}
+
+	identity, err := provider.Exchange(ctx, req.Code, loginState.Verifier, loginState.Nonce)
+	if err != nil {
+		return nil, &Error{Kind: ErrUnauthorized, Code: "sign_in_failed", Message: "sign-in with the provider failed", Err: err}
+	}
+
+	user, err := s.resolveUser(ctx, providerName, identity, loginState.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	user.ProviderSignInAt = time.Now()
+	if err := s.authService.users.SetProviderSignIn(ctx, user.ID, user.ProviderSignInAt); err != nil {
+		return nil, err
+	}
+
+	return s.authService.completeLogin(ctx, user)
+}
+
+// resolveUser finds the user already linked to the identity, links it to
+// linkTo, the user linking a provider account, or to an existing account
+// with the same verified email, or creates a new account.
+func (s *OIDCService) resolveUser(ctx context.Context, providerName string, identity *utils.OIDCIdentity, linkTo primitive.ObjectID) (*models.User, error) {
+	users := s.authService.users
+
+	user, err := users.FindByIdentity(ctx, providerName, identity.Subject)
+	if err == nil {
+		if !linkTo.IsZero() && user.ID != linkTo {
+			return nil, ErrIdentityInUse
+		}
+		return user, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	link := models.ExternalIdentity{
+		Provider: providerName,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+		LinkedAt: time.Now(),
+	}
+
+	// The user signed in to their account to link the provider, so the
+	// emails need not match
+	if !linkTo.IsZero() {
+		user, err := s.authService.GetUserByID(ctx, linkTo)
+		if err != nil {
+			return nil, err
+		}
+		if err := users.AddIdentity(ctx, user.ID, link); err != nil {
+			return nil, err
+		}
+
+		user.Identities = append(user.Identities, link)
+		return user, nil
+	}
+
+	// Linking by email is only safe when the provider vouches for it
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, ErrUnverifiedEmail
+	}
+
+	user, err = users.FindByEmail(ctx, identity.Email)
+	if err == nil {
+		// ... and the account's owner has proven the email too
+		if !user.EmailVerified {
+			return nil, ErrLinkRequired
+		}
+		if err := users.AddIdentity(ctx, user.ID, link); err != nil {
+			return nil, err
+		}
+
+		user.Identities = append(user.Identities, link)
+		return user, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+
+	// No password is set, so the account can only sign in through a provider
+	user = &models.User{
+		Name:          identity.Name,
+		Email:         identity.Email,
+		EmailVerified: true,
+		Role:          "user",
+		Identities:    []models.ExternalIdentity{link},
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+	if user.Name == "" {
+		user.Name = identity.Email
+	}
+
+	if err := users.Create(ctx, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
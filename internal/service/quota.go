@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/ratelimit"
+	"github.com/subhammahanty235/medai/internal/repository"
+	"github.com/subhammahanty235/medai/internal/tracing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Daily quotas, named after the counters of models.DailyUsage.
+const (
+	QuotaMessages = "messages"
+	QuotaUploads  = "uploads"
+)
+
+// usageRetention is how long usage documents are kept after their day.
+const usageRetention = 7 * 24 * time.Hour
+
+type QuotaConfig struct {
+	Messages ratelimit.Quota
+	Uploads  ratelimit.Quota
+}
+
+// QuotaUsage describes a quota after an action was counted against it.
+type QuotaUsage struct {
+	Limit     int
+	Remaining int
+	ResetAt   time.Time
+
+	key  string
+	kind string
+}
+
+// QuotaService counts AI messages and uploads per user and UTC day, so that
+// a single account cannot run up the LLM and storage bills. Counts are kept
+// in the database and shared by every instance.
+type QuotaService struct {
+	usage repository.DailyUsageRepository
+	cfg   QuotaConfig
+}
+
+func NewQuotaService(usage repository.DailyUsageRepository, cfg QuotaConfig) *QuotaService {
+	return &QuotaService{
+		usage: usage,
+		cfg:   cfg,
+	}
+}
+
+// Consume counts one action of kind for the user. It returns nil usage when
+// the user's role has no quota for kind, and an error with code
+// quota_exceeded, along with the exhausted usage, once the quota is used up.
+func (s *QuotaService) Consume(ctx context.Context, userID primitive.ObjectID, role, kind string) (*QuotaUsage, error) {
+	ctx, span := tracing.Start(ctx, "QuotaService.Consume")
+	defer span.End()
+
+	limit, err := s.limit(role, kind)
+	if err != nil || limit == 0 {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	day := now.Format(time.DateOnly)
+	resetAt := now.Truncate(24 * time.Hour).Add(24 * time.Hour)
+	usage := &QuotaUsage{
+		Limit:   limit,
+		ResetAt: resetAt,
+		key:     userID.Hex() + ":" + day,
+		kind:    kind,
+	}
+
+	counted, err := s.usage.Increment(ctx, models.DailyUsage{
+		Key:       usage.key,
+		UserID:    userID,
+		Day:       day,
+		ExpiresAt: resetAt.Add(usageRetention),
+	}, kind, limit)
+	if err == nil {
+		usage.Remaining = max(0, limit-usageCount(counted, kind))
+		return usage, nil
+	}
+	if !errors.Is(err, repository.ErrLimitReached) {
+		return nil, err
+	}
+
+	return usage, RateLimited("quota_exceeded",
+		fmt.Sprintf("Daily limit of %d %s reached, please try again tomorrow", limit, kind),
+		time.Until(resetAt))
+}
+
+// Refund gives back an action counted by Consume, for requests that failed
+// before doing what the quota is meant to limit.
+func (s *QuotaService) Refund(ctx context.Context, usage *QuotaUsage) error {
+	ctx, span := tracing.Start(ctx, "QuotaService.Refund")
+	defer span.End()
+
+	// Refunded even if the client has gone away
+	return s.usage.Decrement(context.WithoutCancel(ctx), usage.key, usage.kind)
+}
+
+// Forget removes the user's usage counts, for deleted accounts.
+func (s *QuotaService) Forget(ctx context.Context, userID primitive.ObjectID) error {
+	ctx, span := tracing.Start(ctx, "QuotaService.Forget")
+	defer span.End()
+
+	return s.usage.DeleteByUser(ctx, userID)
+}
+
+func (s *QuotaService) limit(role, kind string) (int, error) {
+	switch kind {
+	case QuotaMessages:
+		return s.cfg.Messages.For(role), nil
+	case QuotaUploads:
+		return s.cfg.Uploads.For(role), nil
+	default:
+		return 0, fmt.Errorf("unknown quota %q", kind)
+	}
+}
+
+func usageCount(usage *models.DailyUsage, kind string) int {
+	if kind == QuotaUploads {
+		return usage.Uploads
+	}
+	return usage.Messages
+}
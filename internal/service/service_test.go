@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/llm"
+	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/ratelimit"
+	"github.com/subhammahanty235/medai/internal/repository"
+	"github.com/subhammahanty235/medai/internal/storage"
+	"github.com/subhammahanty235/medai/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const testJWTSecret = "test-secret"
+
+var testDoctor = models.Doctor{
+	ID:        "general",
+	Name:      "Dr. Test",
+	Specialty: "General Physician",
+	IsAI:      true,
+	Prompt:    "You are a general physician.",
+}
+
+var testRealDoctor = models.RealDoctor{
+	ID:        primitive.NewObjectID(),
+	Name:      "Dr. Real",
+	Specialty: "General Physician",
+	Hospital:  "Test Hospital",
+}
+
+// testServices wires every service to in-memory repositories, local storage
+// in a temporary directory and a fake LLM.
+type testServices struct {
+	repos  *repository.Repositories
+	llm    *fakeLLM
+	mailer *fakeMailer
+
+	audit         *AuditService
+	loginGuard    *LoginGuard
+	auth          *AuthService
+	oidc          *OIDCService
+	healthProfile *HealthProfileService
+	quota         *QuotaService
+	usage         *UsageService
+	chat          *ChatService
+	appointment   *AppointmentService
+	account       *AccountService
+}
+
+func newTestServices(t *testing.T) *testServices {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	repos := repository.NewMemoryRepositories()
+	repos.Doctors = repository.NewMemoryDoctorRepository(testDoctor)
+	repos.RealDoctors = repository.NewMemoryRealDoctorRepository(testRealDoctor)
+
+	blobStorage, err := storage.NewLocalStorage(t.TempDir(), "http://localhost/files", "signing-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := &fakeLLM{reply: "Rest and drink plenty of fluids."}
+	llmClient, err := llm.NewClient(llm.Config{
+		TextChain:   []llm.Target{{Provider: "fake", Model: "fake-text"}},
+		VisionChain: []llm.Target{{Provider: "fake", Model: "fake-vision"}},
+	}, map[string]llm.Provider{"fake": fake})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &testServices{repos: repos, llm: fake, mailer: &fakeMailer{}}
+	s.audit = NewAuditService(repos.AuditEvents, logger)
+	s.loginGuard = NewLoginGuard(repos.LoginAttempts, LoginGuardConfig{
+		MaxAttempts:     3,
+		LockoutDuration: time.Hour,
+		IPMaxAttempts:   100,
+		IPWindow:        time.Hour,
+	}, s.audit)
+	s.auth = NewAuthService(repos.Users, testJWTSecret, s.loginGuard, MFAConfig{Issuer: "MedAI"})
+	s.oidc = NewOIDCService(repos.OIDCLoginStates, s.auth)
+	s.healthProfile = NewHealthProfileService(repos.HealthProfiles)
+	s.quota = NewQuotaService(repos.DailyUsage, QuotaConfig{Messages: ratelimit.Quota{Default: 100}})
+	s.usage = NewUsageService(repos.LLMUsage, repos.UsageBudgets, s.auth, UsageConfig{
+		Prices: PriceTable{"fake-text": {Prompt: 1, Completion: 2}},
+	}, logger)
+	imageVariants := NewImageVariantService(repos.Messages, blobStorage, 1, logger)
+	t.Cleanup(func() { imageVariants.Shutdown(context.Background()) })
+	s.chat = NewChatService(repos.ChatSessions, repos.Messages, llmClient, NewDoctorService(repos.Doctors, repos.RealDoctors),
+		s.healthProfile, blobStorage, imageVariants, s.usage, time.Minute, time.Minute)
+	s.appointment = NewAppointmentService(repos.Appointments)
+	s.account = NewAccountService(repos.Users, repos.ChatSessions, repos.Messages, repos.Appointments, s.auth, s.healthProfile, s.usage, s.quota,
+		blobStorage, s.mailer, s.audit, RetentionPolicy{Appointments: AppointmentRetentionDelete}, "http://localhost", logger)
+	return s
+}
+
+// register creates a password account and returns it.
+func (s *testServices) register(t *testing.T, email, password string) *models.User {
+	t.Helper()
+
+	response, err := s.auth.Register(context.Background(), models.RegisterRequest{Name: "Test User", Email: email, Password: password})
+	if err != nil {
+		t.Fatalf("Register(%s): %v", email, err)
+	}
+	return &response.User
+}
+
+// createProviderUser stores an account created through a sign-in provider,
+// so without a password, that last signed in through it at signedInAt.
+func (s *testServices) createProviderUser(t *testing.T, email string, signedInAt time.Time) *models.User {
+	t.Helper()
+
+	user := &models.User{Name: "Test User", Email: email, EmailVerified: true, Role: "user", ProviderSignInAt: signedInAt}
+	if err := s.repos.Users.Create(context.Background(), user); err != nil {
+		t.Fatalf("Create(%s): %v", email, err)
+	}
+	return user
+}
+
+// fakeLLM answers every prompt with reply, or fails with err while set.
+type fakeLLM struct {
+	mu      sync.Mutex
+	reply   string
+	err     error
+	prompts []string
+}
+
+func (f *fakeLLM) Generate(ctx context.Context, model, systemPrompt, prompt string, imageURLs []string) (*utils.Completion, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.prompts = append(f.prompts, prompt)
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &utils.Completion{Text: f.reply, Model: model, PromptTokens: 100, CompletionTokens: 50}, nil
+}
+
+func (f *fakeLLM) Retryable(err error) bool {
+	return false
+}
+
+func (f *fakeLLM) fail(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+func (f *fakeLLM) lastPrompt() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.prompts) == 0 {
+		return ""
+	}
+	return f.prompts[len(f.prompts)-1]
+}
+
+// fakeMailer keeps the mail it is asked to send.
+type fakeMailer struct {
+	mu    sync.Mutex
+	sent  []sentMail
+	token string
+}
+
+type sentMail struct {
+	to, subject, body string
+}
+
+func (m *fakeMailer) Send(ctx context.Context, to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sent = append(m.sent, sentMail{to, subject, body})
+	if _, token, ok := strings.Cut(body, "token="); ok {
+		m.token = strings.Fields(token)[0]
+	}
+	return nil
+}
+
+// lastToken returns the token of the last link mailed.
+func (m *fakeMailer) lastToken() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.token
+}
+
+func errorCode(err error) string {
+	var serviceErr *Error
+	if errors.As(err, &serviceErr) {
+		return serviceErr.Code
+	}
+	return ""
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/models"
+	"github.com/subhammahanty235/medai/internal/repository"
+	"github.com/subhammahanty235/medai/internal/tracing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Groupings of usage summaries.
+const (
+	UsageByDay     = "day"
+	UsageByUser    = "user"
+	UsageByPersona = "persona"
+)
+
+const (
+	defaultUsagePeriod = 30 * 24 * time.Hour
+	maxUsageSummaries  = 1000
+)
+
+// ModelPrice is what a model costs in USD per million tokens.
+type ModelPrice struct {
+	Prompt     float64
+	Completion float64
+}
+
+// PriceTable maps model names to their prices.
+type PriceTable map[string]ModelPrice
+
+// ParsePriceTable parses a comma-separated list of model=prompt/completion
+// prices in USD per million tokens, such as
+// "gemini-pro=0.5/1.5,gemini-pro-vision=0.5/1.5".
+func ParsePriceTable(spec string) (PriceTable, error) {
+	prices := PriceTable{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		model, price, ok := strings.Cut(entry, "=")
+		prompt, completion, hasBoth := strings.Cut(price, "/")
+		promptPrice, promptErr := strconv.ParseFloat(strings.TrimSpace(prompt), 64)
+		completionPrice, completionErr := strconv.ParseFloat(strings.TrimSpace(completion), 64)
+		if !ok || !hasBoth || promptErr != nil || completionErr != nil || promptPrice < 0 || completionPrice < 0 {
+			return nil, fmt.Errorf("invalid price %q, expected model=prompt/completion", entry)
+		}
+		prices[strings.TrimSpace(model)] = ModelPrice{Prompt: promptPrice, Completion: completionPrice}
+	}
+	return prices, nil
+}
+
+// Cost estimates the cost of a call in USD. Models missing from the table
+// cost nothing.
+func (t PriceTable) Cost(model string, promptTokens, completionTokens int) float64 {
+	price := t[model]
+	return (float64(promptTokens)*price.Prompt + float64(completionTokens)*price.Completion) / 1e6
+}
+
+type UsageConfig struct {
+	Prices PriceTable
+	// MonthlyBudget is the default LLM budget of a user in USD per calendar
+	// month (UTC); zero means no budget
+	MonthlyBudget float64
+}
+
+// UsageFilter narrows usage summaries. From and To bound the time of the
+// calls; zero values leave the last 30 days. Limit caps the number of
+// summaries, largest cost first for users and personas.
+type UsageFilter struct {
+	From    time.Time
+	To      time.Time
+	UserID  primitive.ObjectID
+	Persona string
+	Limit   int
+}
+
+// UsageService records the tokens and estimated cost of LLM calls, sums
+// them up for admins and holds users to their monthly budgets.
+type UsageService struct {
+	usage       repository.LLMUsageRepository
+	budgets     repository.UsageBudgetRepository
+	authService *AuthService
+	cfg         UsageConfig
+	logger      *slog.Logger
+}
+
+func NewUsageService(usage repository.LLMUsageRepository, budgets repository.UsageBudgetRepository, authService *AuthService, cfg UsageConfig, logger *slog.Logger) *UsageService {
+	return &UsageService{
+		usage:       usage,
+		budgets:     budgets,
+		authService: authService,
+		cfg:         cfg,
+		logger:      logger,
+	}
+}
+
+// Record stores an LLM call, pricing it from the price table. Failures are
+// logged rather than returned so that accounting never costs the patient
+// their reply.
+func (s *UsageService) Record(ctx context.Context, usage models.LLMUsage) {
+	ctx, span := tracing.Start(ctx, "UsageService.Record")
+	defer span.End()
+
+	usage.CostUSD = s.cfg.Prices.Cost(usage.Model, usage.PromptTokens, usage.CompletionTokens)
+	usage.CreatedAt = time.Now()
+
+	// Recorded even if the client has gone away, the call was paid for
+	if err := s.usage.Insert(context.WithoutCancel(ctx), &usage); err != nil {
+		s.logger.ErrorContext(ctx, "Error recording LLM usage", "model", usage.Model, "persona", usage.Persona, "error", err)
+	}
+}
+
+// Summarize totals usage by day, user or persona, one of the UsageBy
+// constants.
+func (s *UsageService) Summarize(ctx context.Context, groupBy string, filter UsageFilter) ([]models.UsageSummary, error) {
+	ctx, span := tracing.Start(ctx, "UsageService.Summarize")
+	defer span.End()
+
+	switch groupBy {
+	case UsageByDay, UsageByUser, UsageByPersona:
+	default:
+		return nil, fmt.Errorf("unknown usage grouping %q", groupBy)
+	}
+
+	to := filter.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	from := filter.From
+	if from.IsZero() {
+		from = to.Add(-defaultUsagePeriod)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > maxUsageSummaries {
+		limit = maxUsageSummaries
+	}
+
+	return s.usage.Summarize(ctx, groupBy, repository.UsageFilter{
+		From:    from,
+		To:      to,
+		UserID:  filter.UserID,
+		Persona: filter.Persona,
+	}, limit)
+}
+
+// Budget returns the user's monthly budget and what they spent of it this
+// month.
+func (s *UsageService) Budget(ctx context.Context, userID primitive.ObjectID) (*models.BudgetStatus, error) {
+	ctx, span := tracing.Start(ctx, "UsageService.Budget")
+	defer span.End()
+
+	if _, err := s.authService.GetUserByID(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.budgetStatus(ctx, userID)
+}
+
+func (s *UsageService) budgetStatus(ctx context.Context, userID primitive.ObjectID) (*models.BudgetStatus, error) {
+	monthlyUSD, custom, err := s.monthlyBudget(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	status := &models.BudgetStatus{UserID: userID, MonthlyUSD: monthlyUSD, Custom: custom}
+
+	monthStart, nextMonth := currentMonth(time.Now())
+	status.ResetsAt = nextMonth
+	if status.SpentUSD, err = s.usage.TotalCost(ctx, userID, monthStart); err != nil {
+		return nil, err
+	}
+	if status.MonthlyUSD > 0 {
+		status.RemainingUSD = max(0, status.MonthlyUSD-status.SpentUSD)
+	}
+	return status, nil
+}
+
+// SetBudget gives the user a monthly budget of their own; zero exempts
+// them from the default.
+func (s *UsageService) SetBudget(ctx context.Context, userID primitive.ObjectID, monthlyUSD float64) (*models.BudgetStatus, error) {
+	ctx, span := tracing.Start(ctx, "UsageService.SetBudget")
+	defer span.End()
+
+	if _, err := s.authService.GetUserByID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	err := s.budgets.Save(ctx, &models.UsageBudget{UserID: userID, MonthlyUSD: monthlyUSD, UpdatedAt: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+	return s.budgetStatus(ctx, userID)
+}
+
+// ResetBudget puts the user back on the default budget.
+func (s *UsageService) ResetBudget(ctx context.Context, userID primitive.ObjectID) (*models.BudgetStatus, error) {
+	ctx, span := tracing.Start(ctx, "UsageService.ResetBudget")
+	defer span.End()
+
+	if _, err := s.authService.GetUserByID(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	if err := s.budgets.DeleteByUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.budgetStatus(ctx, userID)
+}
+
+// Forget removes the budget set for the user, for deleted accounts. The
+// recorded usage is kept for cost reports; it only names the user by an ID
+// nothing refers to any more.
+func (s *UsageService) Forget(ctx context.Context, userID primitive.ObjectID) error {
+	ctx, span := tracing.Start(ctx, "UsageService.Forget")
+	defer span.End()
+
+	return s.budgets.DeleteByUser(ctx, userID)
+}
+
+// CheckBudget returns an error with code budget_exceeded once the user has
+// spent their monthly budget.
+func (s *UsageService) CheckBudget(ctx context.Context, userID primitive.ObjectID) error {
+	ctx, span := tracing.Start(ctx, "UsageService.CheckBudget")
+	defer span.End()
+
+	monthlyUSD, _, err := s.monthlyBudget(ctx, userID)
+	if err != nil || monthlyUSD == 0 {
+		return err
+	}
+
+	monthStart, nextMonth := currentMonth(time.Now())
+	spent, err := s.usage.TotalCost(ctx, userID, monthStart)
+	if err != nil {
+		return err
+	}
+	if spent >= monthlyUSD {
+		return RateLimited("budget_exceeded", "Your monthly AI consultation budget is used up",
+			time.Until(nextMonth))
+	}
+	return nil
+}
+
+// monthlyBudget returns the user's own budget, or the default when they
+// have none.
+func (s *UsageService) monthlyBudget(ctx context.Context, userID primitive.ObjectID) (float64, bool, error) {
+	budget, err := s.budgets.FindByUser(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return s.cfg.MonthlyBudget, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return budget.MonthlyUSD, true, nil
+}
+
+// currentMonth returns the start of the calendar month (UTC) containing now
+// and of the next one.
+func currentMonth(now time.Time) (time.Time, time.Time) {
+	now = now.UTC()
+	start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0)
+}
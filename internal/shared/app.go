@@ -0,0 +1,61 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/subhammahanty235/medai/internal/config"
+	"github.com/subhammahanty235/medai/internal/db"
+	"github.com/subhammahanty235/medai/internal/health"
+	"github.com/subhammahanty235/medai/internal/service"
+	"github.com/subhammahanty235/medai/internal/storage"
+	"github.com/subhammahanty235/medai/internal/utils"
+)
+
+// App is the HTTP API together with the clients and background workers it
+// owns.
+type App struct {
+	Router *gin.Engine
+	Health *health.Health
+
+	database      *db.Database
+	storage       storage.Storage
+	cfg           *config.Config
+	geminiClient  *utils.GeminiClient
+	imageVariants *service.ImageVariantService
+	logger        *slog.Logger
+}
+
+// Start runs the startup work that has to finish before the instance takes
+// traffic, then opens the readiness gate. Liveness is served meanwhile, so
+// slow migrations do not get the instance restarted.
+func (a *App) Start(ctx context.Context) error {
+	if a.cfg.MigrateOnStartup {
+		if err := Migrate(ctx, a.database, a.storage, a.cfg, a.logger); err != nil {
+			return fmt.Errorf("migrating database: %w", err)
+		}
+	}
+
+	// Pick up images left pending by a previous run
+	if err := a.imageVariants.ResumePending(ctx); err != nil {
+		return fmt.Errorf("resuming image processing: %w", err)
+	}
+
+	a.Health.MarkStarted()
+	return nil
+}
+
+// Close stops background work, waiting for it until ctx is done, and then
+// closes the LLM client. The database is left open for the caller to close
+// last.
+func (a *App) Close(ctx context.Context) {
+	if err := a.imageVariants.Shutdown(ctx); err != nil {
+		a.logger.WarnContext(ctx, "Image processing did not finish before shutdown", "error", err)
+	}
+
+	if err := a.geminiClient.Close(); err != nil {
+		a.logger.ErrorContext(ctx, "Error closing Gemini client", "error", err)
+	}
+}
@@ -1,72 +1,227 @@
 package shared
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/subhammahanty235/medai/internal/config"
 	"github.com/subhammahanty235/medai/internal/db"
 	"github.com/subhammahanty235/medai/internal/handlers"
+	"github.com/subhammahanty235/medai/internal/health"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 
+	"github.com/subhammahanty235/medai/internal/llm"
+	"github.com/subhammahanty235/medai/internal/metrics"
 	"github.com/subhammahanty235/medai/internal/middleware"
+	"github.com/subhammahanty235/medai/internal/migrations"
+	"github.com/subhammahanty235/medai/internal/ratelimit"
 
 	// "github.com/subhammahanty235/medai/internal/handlers"
+	"github.com/subhammahanty235/medai/internal/repository"
 	"github.com/subhammahanty235/medai/internal/service"
+	"github.com/subhammahanty235/medai/internal/storage"
+	"github.com/subhammahanty235/medai/internal/tracing"
+	"github.com/subhammahanty235/medai/internal/upload"
 	"github.com/subhammahanty235/medai/internal/utils"
 )
 
-func SetupRouter(database *db.Database, cfg *config.Config) *gin.Engine {
-	r := gin.Default()
+// SetupRouter creates the services and routes of the API. Call Start on
+// the returned App once the HTTP server is listening, and Close once it has
+// stopped.
+func SetupRouter(database *db.Database, cfg *config.Config, logger *slog.Logger) (*App, error) {
+	if cfg.GeminiAPIKey == "" {
+		return nil, errors.New("GEMINI_API_KEY is not set")
+	}
+
+	// gin's own logger writes full URLs as text, so requests are logged by
+	// RequestLogMiddleware instead
+	r := gin.New()
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("invalid TRUSTED_PROXIES: %w", err)
+	}
+	limits, err := parseRateLimits(cfg)
+	if err != nil {
+		return nil, err
+	}
+	llmSettings, err := parseLLMSettings(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := service.CheckReplyLease(cfg.ReplyLease); err != nil {
+		return nil, fmt.Errorf("invalid REPLY_LEASE: %w", err)
+	}
+
+	r.Use(middleware.RecoveryMiddleware(logger))
+	r.Use(otelgin.Middleware(cfg.TracingServiceName, otelgin.WithFilter(untracedPath)))
+	r.Use(middleware.RequestIDMiddleware())
+	r.Use(middleware.RequestLogMiddleware(logger, "/healthz", "/readyz", "/metrics"))
+	r.Use(middleware.ErrorMiddleware(logger))
+	handlers.UseJSONFieldNames()
 
 	// CORS middleware
 	r.Use(middleware.CORSMiddleware())
+	r.Use(middleware.MetricsMiddleware())
 
 	// Initialize services
-	authService := service.NewAuthService(database, cfg.JWTSecret)
-	doctorService := service.NewDoctorService(database)
-	appointmentService := service.NewAppointmentService(database)
+	repos := repository.NewMongoRepositories(database)
 
-	// Initialize Gemini client
-	geminiClient, err := utils.NewGeminiClient(cfg.GeminiAPIKey)
+	auditService := service.NewAuditService(repos.AuditEvents, logger)
+	loginGuard := service.NewLoginGuard(repos.LoginAttempts, service.LoginGuardConfig{
+		MaxAttempts:     cfg.LoginMaxAttempts,
+		LockoutDuration: cfg.LoginLockoutDuration,
+		BackoffBase:     cfg.LoginBackoffBase,
+		BackoffMax:      cfg.LoginBackoffMax,
+		IPMaxAttempts:   cfg.LoginIPMaxAttempts,
+		IPWindow:        cfg.LoginIPWindow,
+	}, auditService)
+	quotaService := service.NewQuotaService(repos.DailyUsage, limits.quotas)
+	authService := service.NewAuthService(repos.Users, cfg.JWTSecret, loginGuard, service.MFAConfig{
+		Issuer:        cfg.MFAIssuer,
+		RequiredRoles: cfg.MFARequiredRoles,
+	})
+	oidcProviders, err := setupOIDCProviders(cfg)
 	if err != nil {
-		panic("Failed to initialize Gemini client: " + err.Error())
+		return nil, fmt.Errorf("initializing OIDC providers: %w", err)
 	}
+	oidcService := service.NewOIDCService(repos.OIDCLoginStates, authService, oidcProviders...)
+	doctorService := service.NewDoctorService(repos.Doctors, repos.RealDoctors)
+	appointmentService := service.NewAppointmentService(repos.Appointments)
+
+	// Initialize blob storage
+	blobStorage, err := NewStorage(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("initializing storage: %w", err)
+	}
+
+	// Initialize Gemini client, the last step that can fail so it needs no
+	// cleanup on the error paths
+	geminiClient, err := utils.NewGeminiClient(cfg.GeminiAPIKey, cfg.LLMTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("initializing Gemini client: %w", err)
+	}
+
+	llmClient, err := llm.NewClient(llm.Config{
+		TextChain:       llmSettings.textModels,
+		VisionChain:     llmSettings.visionModels,
+		MaxRetries:      cfg.LLMMaxRetries,
+		RetryBaseDelay:  cfg.LLMRetryBaseDelay,
+		RetryMaxDelay:   cfg.LLMRetryMaxDelay,
+		BreakerFailures: cfg.LLMBreakerFailures,
+		BreakerCooldown: cfg.LLMBreakerCooldown,
+		OnBreakerChange: metrics.SetLLMCircuitState,
+	}, map[string]llm.Provider{"gemini": geminiClient})
+	if err != nil {
+		return nil, fmt.Errorf("initializing LLM client: %w", err)
+	}
+
+	imageVariantService := service.NewImageVariantService(repos.Messages, blobStorage, cfg.ImageWorkers, logger)
+
+	usageService := service.NewUsageService(repos.LLMUsage, repos.UsageBudgets, authService, service.UsageConfig{
+		Prices:        llmSettings.prices,
+		MonthlyBudget: cfg.LLMMonthlyBudget,
+	}, logger)
 
-	chatService := service.NewChatService(database, geminiClient, doctorService)
+	healthProfileService := service.NewHealthProfileService(repos.HealthProfiles)
+	chatService := service.NewChatService(repos.ChatSessions, repos.Messages, llmClient, doctorService, healthProfileService, blobStorage, imageVariantService, usageService, cfg.SignedURLTTL, cfg.ReplyLease)
 
-	// Initialize S3 client
-	s3Client, err := utils.NewS3Client(cfg.AWSRegion, cfg.AWSAccessKey, cfg.AWSSecretKey, cfg.S3Bucket)
+	mailer, err := newMailer(cfg, logger)
 	if err != nil {
-		panic("Failed to initialize S3 client: " + err.Error())
+		return nil, err
 	}
+	accountService := service.NewAccountService(repos.Users, repos.ChatSessions, repos.Messages, repos.Appointments, authService, healthProfileService, usageService, quotaService, blobStorage, mailer, auditService,
+		service.RetentionPolicy{Appointments: cfg.AppointmentRetention}, cfg.AppBaseURL, logger)
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService)
+	accountHandler := handlers.NewAccountHandler(accountService)
+	oidcHandler := handlers.NewOIDCHandler(oidcService)
 	doctorHandler := handlers.NewDoctorHandler(doctorService)
-	chatHandler := handlers.NewChatHandler(chatService, s3Client)
+	chatHandler := handlers.NewChatHandler(chatService, upload.ChatPipeline(cfg.UploadMaxSize), cfg.UploadMaxFiles)
 	appointmentHandler := handlers.NewAppointmentHandler(appointmentService)
+	healthProfileHandler := handlers.NewHealthProfileHandler(healthProfileService)
+	usageHandler := handlers.NewUsageHandler(usageService)
+
+	// In-memory buckets of the rate limits of every route group
+	limiter := ratelimit.NewLimiter()
 
 	// Public routes
 	public := r.Group("/api")
+	public.Use(middleware.RateLimitMiddleware(limiter, "public", limits.public))
 	{
-		public.POST("/auth/register", authHandler.Register)
-		public.POST("/auth/login", authHandler.Login)
+		public.GET("/auth/oidc/providers", oidcHandler.GetProviders)
 		public.GET("/doctors", doctorHandler.GetAllDoctors)
 		public.GET("/doctors/real", doctorHandler.GetRealDoctors)
 		public.GET("/doctors/:id", doctorHandler.GetDoctorByID)
 	}
 
+	// Sign-in routes, limited more tightly against credential stuffing
+	signIn := r.Group("/api/auth")
+	signIn.Use(middleware.RateLimitMiddleware(limiter, "auth", limits.auth))
+	{
+		signIn.POST("/register", authHandler.Register)
+		signIn.POST("/login", authHandler.Login)
+		signIn.POST("/mfa/verify", authHandler.VerifyMFA)
+		signIn.POST("/email/verify", accountHandler.VerifyEmailChange)
+		signIn.GET("/oidc/:provider/start", oidcHandler.StartLogin)
+		signIn.POST("/oidc/:provider/callback", oidcHandler.Callback)
+	}
+
+	// Files of the local storage backend, authorized by signed links
+	if localStorage, ok := blobStorage.(*storage.LocalStorage); ok {
+		fileHandler := handlers.NewFileHandler(localStorage)
+		r.GET(storage.LocalFilesRoute+"*key", fileHandler.GetFile)
+	}
+
+	// MFA enrollment routes, also reachable with an enrollment-only token
+	enrollment := r.Group("/api/auth/mfa")
+	enrollment.Use(middleware.MFAEnrollmentAuthMiddleware(cfg.JWTSecret, authService.GetTokenVersion))
+	enrollment.Use(middleware.RateLimitMiddleware(limiter, "auth", limits.auth))
+	{
+		enrollment.POST("/enroll", authHandler.BeginMFAEnrollment)
+		enrollment.POST("/enroll/verify", authHandler.ConfirmMFAEnrollment)
+	}
+
 	// Protected routes
 	protected := r.Group("/api")
-	protected.Use(middleware.AuthMiddleware(cfg.JWTSecret))
+	protected.Use(middleware.AuthMiddleware(cfg.JWTSecret, authService.GetTokenVersion))
+	protected.Use(middleware.RateLimitMiddleware(limiter, "api", limits.api))
 	{
 		// Auth routes
 		protected.GET("/auth/profile", authHandler.GetProfile)
+		protected.PUT("/auth/profile", accountHandler.UpdateProfile)
+		protected.PUT("/auth/password", accountHandler.ChangePassword)
+		protected.POST("/auth/email", accountHandler.RequestEmailChange)
+		protected.DELETE("/auth/account", accountHandler.DeleteAccount)
+		protected.POST("/auth/mfa/disable", authHandler.DisableMFA)
+		protected.POST("/auth/mfa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+		protected.GET("/auth/oidc/:provider/link", oidcHandler.StartLink)
 
-		// Chat routes
+		// Health profile routes
+		protected.GET("/health-profile", healthProfileHandler.GetProfile)
+		protected.PUT("/health-profile", healthProfileHandler.UpdateProfile)
+		protected.DELETE("/health-profile", healthProfileHandler.DeleteProfile)
+		protected.POST("/health-profile/dependents", healthProfileHandler.AddDependent)
+		protected.PUT("/health-profile/dependents/:id", healthProfileHandler.UpdateDependent)
+		protected.DELETE("/health-profile/dependents/:id", healthProfileHandler.DeleteDependent)
+
+		// Chat routes. Both message routes ask the AI doctor for a reply,
+		// so uploads count against the message quota too
+		chatLimit := middleware.RateLimitMiddleware(limiter, "chat", limits.chat)
+		messageQuota := middleware.QuotaMiddleware(quotaService, service.QuotaMessages, logger)
+		uploadQuota := middleware.QuotaMiddleware(quotaService, service.QuotaUploads, logger)
 		protected.POST("/chat/start/:doctorId", chatHandler.StartChat)
-		protected.POST("/chat/:sessionId/message", chatHandler.SendMessage)
-		protected.POST("/chat/:sessionId/upload", chatHandler.UploadImage)
+		protected.POST("/chat/:sessionId/message", chatLimit, messageQuota, chatHandler.SendMessage)
+		protected.POST("/chat/:sessionId/upload", chatLimit, messageQuota, uploadQuota, chatHandler.UploadFiles)
+		protected.POST("/chat/:sessionId/reply", chatLimit, messageQuota, chatHandler.RetryReply)
 		protected.GET("/chat/history", chatHandler.GetChatHistory)
 		protected.GET("/chat/:sessionId", chatHandler.GetChatSession)
+		protected.GET("/chat/:sessionId/messages", chatHandler.GetMessages)
 
 		// Appointment routes
 		protected.POST("/appointments", appointmentHandler.BookAppointment)
@@ -75,5 +230,215 @@ func SetupRouter(database *db.Database, cfg *config.Config) *gin.Engine {
 		protected.GET("/appointments/:id", appointmentHandler.GetAppointment)
 	}
 
-	return r
+	// Admin routes
+	admin := r.Group("/api/admin")
+	admin.Use(middleware.AuthMiddleware(cfg.JWTSecret, authService.GetTokenVersion))
+	admin.Use(middleware.RequireRole("admin"))
+	admin.Use(middleware.RateLimitMiddleware(limiter, "api", limits.api))
+	{
+		admin.GET("/usage/days", usageHandler.UsageByDay)
+		admin.GET("/usage/users", usageHandler.UsageByUser)
+		admin.GET("/usage/personas", usageHandler.UsageByPersona)
+		admin.GET("/usage/users/:id/budget", usageHandler.GetBudget)
+		admin.PUT("/usage/users/:id/budget", usageHandler.SetBudget)
+		admin.DELETE("/usage/users/:id/budget", usageHandler.ResetBudget)
+	}
+
+	// Health checks, outside /api so they are not subject to its middleware
+	healthChecks := health.New(cfg.HealthCheckTimeout)
+	healthChecks.Add("database", database.Ping)
+	healthChecks.Add("storage", blobStorage.Ping)
+	healthChecks.Add("llm", func(ctx context.Context) error {
+		return geminiClient.CheckConfig()
+	})
+	healthChecks.AddInfo("llm_circuits", func() any {
+		return llmClient.Breakers()
+	})
+
+	healthHandler := handlers.NewHealthHandler(healthChecks)
+	r.GET("/healthz", healthHandler.Liveness)
+	r.GET("/readyz", healthHandler.Readiness)
+
+	// Prometheus metrics, also outside /api
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	return &App{
+		Router:        r,
+		Health:        healthChecks,
+		database:      database,
+		storage:       blobStorage,
+		cfg:           cfg,
+		geminiClient:  geminiClient,
+		imageVariants: imageVariantService,
+		logger:        logger,
+	}, nil
+}
+
+// untracedPath keeps probes and metric scrapes out of traces.
+func untracedPath(r *http.Request) bool {
+	switch r.URL.Path {
+	case "/healthz", "/readyz", "/metrics":
+		return false
+	}
+	return true
+}
+
+// rateLimits are the parsed rate limits of the route groups and the daily
+// quotas.
+type rateLimits struct {
+	public, auth, api, chat ratelimit.Policy
+	quotas                  service.QuotaConfig
+}
+
+func parseRateLimits(cfg *config.Config) (*rateLimits, error) {
+	var limits rateLimits
+
+	policies := []struct {
+		env    string
+		spec   string
+		policy *ratelimit.Policy
+	}{
+		{"RATE_LIMIT_PUBLIC", cfg.RateLimitPublic, &limits.public},
+		{"RATE_LIMIT_AUTH", cfg.RateLimitAuth, &limits.auth},
+		{"RATE_LIMIT_API", cfg.RateLimitAPI, &limits.api},
+		{"RATE_LIMIT_CHAT", cfg.RateLimitChat, &limits.chat},
+	}
+	for _, p := range policies {
+		policy, err := ratelimit.ParsePolicy(p.spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", p.env, err)
+		}
+		*p.policy = policy
+	}
+
+	var err error
+	if limits.quotas.Messages, err = ratelimit.ParseQuota(cfg.AIMessageQuota); err != nil {
+		return nil, fmt.Errorf("invalid AI_MESSAGE_QUOTA: %w", err)
+	}
+	if limits.quotas.Uploads, err = ratelimit.ParseQuota(cfg.UploadQuota); err != nil {
+		return nil, fmt.Errorf("invalid UPLOAD_QUOTA: %w", err)
+	}
+
+	return &limits, nil
+}
+
+// llmSettings are the parsed LLM fallback chains and the prices budgets
+// are counted in.
+type llmSettings struct {
+	textModels, visionModels []llm.Target
+	prices                   service.PriceTable
+}
+
+func parseLLMSettings(cfg *config.Config) (*llmSettings, error) {
+	var settings llmSettings
+
+	var err error
+	if settings.textModels, err = llm.ParseChain(cfg.LLMModels); err != nil {
+		return nil, fmt.Errorf("invalid LLM_MODELS: %w", err)
+	}
+	if settings.visionModels, err = llm.ParseChain(cfg.LLMVisionModels); err != nil {
+		return nil, fmt.Errorf("invalid LLM_VISION_MODELS: %w", err)
+	}
+	if settings.prices, err = service.ParsePriceTable(cfg.LLMPrices); err != nil {
+		return nil, fmt.Errorf("invalid LLM_PRICES: %w", err)
+	}
+
+	// Calls to a model without a price would cost nothing and so escape
+	// the monthly budgets
+	for _, target := range slices.Concat(settings.textModels, settings.visionModels) {
+		if _, ok := settings.prices[target.Model]; !ok {
+			return nil, fmt.Errorf("LLM_PRICES has no price for model %q", target.Model)
+		}
+	}
+
+	return &settings, nil
+}
+
+// SetupTracing installs the tracer provider configured in cfg.
+func SetupTracing(ctx context.Context, cfg *config.Config) (tracing.Shutdown, error) {
+	return tracing.Setup(ctx, tracing.Config{
+		Exporter:    cfg.TracingExporter,
+		ServiceName: cfg.TracingServiceName,
+		Endpoint:    cfg.OTLPEndpoint,
+		Insecure:    cfg.OTLPInsecure,
+		SampleRatio: cfg.TracingSampleRatio,
+	})
+}
+
+// setupOIDCProviders runs discovery for every sign-in provider that has a
+// client ID configured.
+func setupOIDCProviders(cfg *config.Config) ([]*utils.OIDCProvider, error) {
+	ctx := context.Background()
+	var providers []*utils.OIDCProvider
+
+	if cfg.GoogleClientID != "" {
+		google, err := utils.NewOIDCProvider(ctx, "google", "https://accounts.google.com",
+			cfg.GoogleClientID, cfg.GoogleClientSecret, cfg.OIDCRedirectBaseURL+"/google")
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, google)
+	}
+
+	if cfg.OIDCIssuer != "" && cfg.OIDCClientID != "" {
+		generic, err := utils.NewOIDCProvider(ctx, cfg.OIDCProviderName, cfg.OIDCIssuer,
+			cfg.OIDCClientID, cfg.OIDCClientSecret, cfg.OIDCRedirectBaseURL+"/"+cfg.OIDCProviderName)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, generic)
+	}
+
+	return providers, nil
+}
+
+// NewStorage creates the configured blob storage backend.
+func NewStorage(cfg *config.Config) (storage.Storage, error) {
+	return storage.New(storage.Config{
+		Backend:      cfg.StorageBackend,
+		S3Region:     cfg.AWSRegion,
+		S3AccessKey:  cfg.AWSAccessKey,
+		S3SecretKey:  cfg.AWSSecretKey,
+		S3Bucket:     cfg.S3Bucket,
+		S3Endpoint:   cfg.S3Endpoint,
+		S3PathStyle:  cfg.S3ForcePathStyle,
+		S3Timeout:    cfg.StorageTimeout,
+		LocalDir:     cfg.LocalStorageDir,
+		LocalBaseURL: cfg.PublicBaseURL,
+		SigningKey:   cfg.StorageSigningKey,
+	})
+}
+
+// newMailer returns the mailer of MAIL_BACKEND, or nil when mail is off.
+func newMailer(cfg *config.Config, logger *slog.Logger) (utils.Mailer, error) {
+	switch cfg.MailBackend {
+	case "none":
+		return nil, nil
+	case "log":
+		logger.Warn("Outgoing mail is only logged, MAIL_BACKEND=log is meant for development")
+		return utils.NewLogMailer(logger), nil
+	default:
+		return nil, fmt.Errorf("invalid MAIL_BACKEND %q, want none or log", cfg.MailBackend)
+	}
+}
+
+// NewMigrator returns a migrator for every migration of the application.
+func NewMigrator(database *db.Database, blobStorage storage.Storage, cfg *config.Config, logger *slog.Logger) (*migrations.Migrator, error) {
+	env := migrations.Env{DB: database, Storage: blobStorage, Logger: logger}
+	return migrations.NewMigrator(env, migrations.All, cfg.MigrationLockTimeout)
+}
+
+// Migrate applies pending migrations, waiting for any other instance that is
+// already applying them.
+func Migrate(ctx context.Context, database *db.Database, blobStorage storage.Storage, cfg *config.Config, logger *slog.Logger) error {
+	migrator, err := NewMigrator(database, blobStorage, cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.Apply(ctx)
+	if len(applied) > 0 {
+		logger.InfoContext(ctx, "Applied migrations", "migrations", len(applied))
+	}
+	return err
 }
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// LocalFilesRoute is where the server exposes objects of LocalStorage.
+const LocalFilesRoute = "/api/files/"
+
+// LocalStorage keeps objects on the local disk for development and CI. Files
+// are served by the API under LocalFilesRoute and are only readable through
+// HMAC-signed links that expire.
+type LocalStorage struct {
+	dir        string
+	baseURL    string
+	signingKey []byte
+}
+
+func NewLocalStorage(dir, baseURL, signingKey string) (*LocalStorage, error) {
+	if signingKey == "" {
+		return nil, errors.New("local storage requires a signing key")
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+
+	return &LocalStorage{
+		dir:        dir,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		signingKey: []byte(signingKey),
+	}, nil
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) (err error) {
+	_, span := startSpan(ctx, "local", "Put", attribute.Int64("storage.size", size))
+	defer func() { endSpan(span, err) }()
+
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see partial objects
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path+".content-type", []byte(contentType), 0o640); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *LocalStorage) Get(ctx context.Context, key string) (_ io.ReadCloser, _ *ObjectInfo, err error) {
+	_, span := startSpan(ctx, "local", "Get")
+	defer func() { endSpan(span, err) }()
+
+	path, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+
+	contentType, err := os.ReadFile(path + ".content-type")
+	if err != nil {
+		contentType = []byte("application/octet-stream")
+	}
+
+	return file, &ObjectInfo{
+		ContentType: string(contentType),
+		Size:        stat.Size(),
+	}, nil
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) (err error) {
+	_, span := startSpan(ctx, "local", "Delete")
+	defer func() { endSpan(span, err) }()
+
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range []string{path, path + ".content-type"} {
+		if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *LocalStorage) SignedURL(key string, ttl time.Duration) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	params := url.Values{}
+	params.Set("expires", expires)
+	params.Set("signature", s.sign(key, expires))
+
+	return fmt.Sprintf("%s%s%s?%s", s.baseURL, LocalFilesRoute, key, params.Encode()), nil
+}
+
+// Ping checks that the storage directory still exists.
+func (s *LocalStorage) Ping(ctx context.Context) error {
+	info, err := os.Stat(s.dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", s.dir)
+	}
+	return nil
+}
+
+// Verify checks the expiry and signature of a link made by SignedURL.
+func (s *LocalStorage) Verify(key, expires, signature string) error {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return errors.New("invalid link")
+	}
+	if time.Now().Unix() > expiresAt {
+		return errors.New("link has expired")
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(key, expires))) {
+		return errors.New("invalid link")
+	}
+	return nil
+}
+
+func (s *LocalStorage) KeyFromURL(link string) (string, bool) {
+	parsed, err := url.Parse(link)
+	if err != nil || !strings.HasPrefix(parsed.Path, LocalFilesRoute) {
+		return "", false
+	}
+	return strings.TrimPrefix(parsed.Path, LocalFilesRoute), true
+}
+
+func (s *LocalStorage) sign(key, expires string) string {
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// path maps a key to a file inside the storage directory, rejecting keys
+// that would escape it.
+func (s *LocalStorage) path(key string) (string, error) {
+	cleaned := filepath.Clean("/" + key)
+	if key == "" || cleaned != "/"+key || strings.HasSuffix(key, ".content-type") {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(cleaned)), nil
+}
@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// S3Storage stores objects in Amazon S3 or an S3-compatible service such as
+// MinIO when an endpoint is configured.
+type S3Storage struct {
+	svc       *s3.S3
+	uploader  *s3manager.Uploader
+	bucket    string
+	publicURL string // base URL of legacy public links, ending in "/"
+	timeout   time.Duration
+}
+
+// NewS3Storage returns S3 storage whose requests are abandoned after
+// timeout, including reading the body of a Get; zero means no limit.
+func NewS3Storage(region, accessKey, secretKey, bucket, endpoint string, pathStyle bool, timeout time.Duration) (*S3Storage, error) {
+	awsConfig := &aws.Config{
+		Region: aws.String(region),
+		Credentials: credentials.NewStaticCredentials(
+			accessKey,
+			secretKey,
+			"",
+		),
+		S3ForcePathStyle: aws.Bool(pathStyle),
+	}
+	if endpoint != "" {
+		awsConfig.Endpoint = aws.String(endpoint)
+	}
+
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var publicURL string
+	switch {
+	case endpoint != "" && pathStyle:
+		publicURL = fmt.Sprintf("%s/%s/", strings.TrimSuffix(endpoint, "/"), bucket)
+	case endpoint != "":
+		endpointURL, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		publicURL = fmt.Sprintf("%s://%s.%s/", endpointURL.Scheme, bucket, endpointURL.Host)
+	case pathStyle:
+		publicURL = fmt.Sprintf("https://s3.%s.amazonaws.com/%s/", region, bucket)
+	default:
+		publicURL = fmt.Sprintf("https://%s.s3.amazonaws.com/", bucket)
+	}
+
+	return &S3Storage{
+		svc:       s3.New(sess),
+		uploader:  s3manager.NewUploader(sess),
+		bucket:    bucket,
+		publicURL: publicURL,
+		timeout:   timeout,
+	}, nil
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) (err error) {
+	ctx, span := startSpan(ctx, "s3", "Put", attribute.Int64("storage.size", size))
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err = s.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		Body:        body,
+		ContentType: aws.String(contentType),
+		ACL:         aws.String(s3.ObjectCannedACLPrivate),
+	})
+	return requestError(ctx, err)
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (_ io.ReadCloser, _ *ObjectInfo, err error) {
+	ctx, span := startSpan(ctx, "s3", "Get")
+	defer func() { endSpan(span, err) }()
+
+	// The deadline also covers reading the body, so it ends when the
+	// caller closes it
+	ctx, cancel := s.withTimeout(ctx)
+
+	output, err := s.svc.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		err = requestError(ctx, err)
+		cancel()
+
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, err
+	}
+
+	return &cancelOnClose{output.Body, cancel}, &ObjectInfo{
+		ContentType: aws.StringValue(output.ContentType),
+		Size:        aws.Int64Value(output.ContentLength),
+	}, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) (err error) {
+	ctx, span := startSpan(ctx, "s3", "Delete")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err = s.svc.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return requestError(ctx, err)
+}
+
+func (s *S3Storage) SignedURL(key string, ttl time.Duration) (string, error) {
+	req, _ := s.svc.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+
+	return req.Presign(ttl)
+}
+
+func (s *S3Storage) Ping(ctx context.Context) error {
+	_, err := s.svc.HeadBucketWithContext(ctx, &s3.HeadBucketInput{Bucket: aws.String(s.bucket)})
+	return err
+}
+
+// MakePrivate revokes public access from an object uploaded with the
+// public-read ACL that was used before uploads became private.
+func (s *S3Storage) MakePrivate(ctx context.Context, key string) error {
+	ctx, cancel := s.withTimeout(ctx)
+	defer cancel()
+
+	_, err := s.svc.PutObjectAclWithContext(ctx, &s3.PutObjectAclInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		ACL:    aws.String(s3.ObjectCannedACLPrivate),
+	})
+	return requestError(ctx, err)
+}
+
+func (s *S3Storage) KeyFromURL(url string) (string, bool) {
+	if !strings.HasPrefix(url, s.publicURL) {
+		return "", false
+	}
+	return strings.TrimPrefix(url, s.publicURL), true
+}
+
+// withTimeout bounds a request by the configured timeout, if any.
+func (s *S3Storage) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.timeout)
+}
+
+// requestError returns err wrapped in the context's error if the deadline
+// passed or the caller went away, which the SDK reports as a canceled
+// request.
+func requestError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil && !errors.Is(err, ctxErr) {
+		return fmt.Errorf("%w: %v", ctxErr, err)
+	}
+	return err
+}
+
+// cancelOnClose releases the context of a Get once the body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
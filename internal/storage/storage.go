@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/subhammahanty235/medai/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	ContentType string
+	Size        int64
+}
+
+// Storage is a blob store for uploaded files, addressed by key.
+type Storage interface {
+	// Put stores body under key, replacing any existing object.
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Get opens the object for reading. It returns ErrNotFound if the key
+	// does not exist. The caller must close the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	// Delete removes the object. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// SignedURL returns a link that grants read access to the object until
+	// ttl has passed. Objects are private otherwise.
+	SignedURL(key string, ttl time.Duration) (string, error)
+	// KeyFromURL maps a link to an object of this store back to its key. It
+	// exists to migrate links stored before messages kept keys.
+	KeyFromURL(url string) (string, bool)
+	// Ping checks that the store can be reached, for readiness checks.
+	Ping(ctx context.Context) error
+}
+
+// Privatizer is implemented by backends whose objects may have been stored
+// with public access, so that existing objects can be locked down.
+type Privatizer interface {
+	MakePrivate(ctx context.Context, key string) error
+}
+
+// startSpan traces a storage operation; finish it with endSpan.
+func startSpan(ctx context.Context, backend, operation string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("storage.backend", backend))
+	return tracing.Start(ctx, "storage."+operation, attrs...)
+}
+
+func endSpan(span trace.Span, err error) {
+	if !errors.Is(err, ErrNotFound) {
+		tracing.RecordError(span, err)
+	}
+	span.End()
+}
+
+type Config struct {
+	Backend string // s3 or local
+
+	// S3 and S3-compatible backends
+	S3Region    string
+	S3AccessKey string
+	S3SecretKey string
+	S3Bucket    string
+	S3Endpoint  string // custom endpoint, e.g. http://localhost:9000 for MinIO
+	S3PathStyle bool
+	S3Timeout   time.Duration // deadline of each request
+
+	// Local disk backend
+	LocalDir     string
+	LocalBaseURL string // public base URL of this server, used in signed links
+	SigningKey   string
+}
+
+// New returns the storage backend selected by cfg.Backend.
+func New(cfg Config) (Storage, error) {
+	switch cfg.Backend {
+	case "s3":
+		return NewS3Storage(cfg.S3Region, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3Bucket, cfg.S3Endpoint, cfg.S3PathStyle, cfg.S3Timeout)
+	case "local":
+		return NewLocalStorage(cfg.LocalDir, cfg.LocalBaseURL, cfg.SigningKey)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
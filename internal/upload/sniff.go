@@ -0,0 +1,75 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unicode/utf8"
+)
+
+var heifBrands = [][]byte{
+	[]byte("heic"), []byte("heix"), []byte("hevc"), []byte("hevx"),
+	[]byte("heim"), []byte("heis"), []byte("mif1"), []byte("msf1"),
+}
+
+// Sniff identifies a supported file type from its leading bytes, returning
+// "" when the content is not one of them.
+func Sniff(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return TypeJPEG
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return TypePNG
+	case len(data) >= 12 && bytes.Equal(data[0:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP")):
+		return TypeWebP
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		return TypePDF
+	case isHEIF(data):
+		return TypeHEIC
+	case isText(data):
+		return TypeText
+	}
+	return ""
+}
+
+// isText accepts UTF-8 text without control characters other than
+// whitespace, which rules out binary formats that happen to be valid UTF-8.
+func isText(data []byte) bool {
+	data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+	if len(bytes.TrimSpace(data)) == 0 || !utf8.Valid(data) {
+		return false
+	}
+
+	for _, b := range data {
+		if b < 0x20 && b != '\n' && b != '\r' && b != '\t' && b != '\f' {
+			return false
+		}
+	}
+	return true
+}
+
+// isHEIF checks for an ISO BMFF ftyp box naming a HEIF brand.
+func isHEIF(data []byte) bool {
+	if len(data) < 16 || !bytes.Equal(data[4:8], []byte("ftyp")) {
+		return false
+	}
+
+	size := int(binary.BigEndian.Uint32(data[0:4]))
+	if size < 16 || size > len(data) {
+		return false
+	}
+
+	// major brand, minor version, then compatible brands
+	brands := [][]byte{data[8:12]}
+	for i := 16; i+4 <= size; i += 4 {
+		brands = append(brands, data[i:i+4])
+	}
+
+	for _, brand := range brands {
+		for _, heif := range heifBrands {
+			if bytes.Equal(brand, heif) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,480 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var errMalformed = errors.New("malformed file structure")
+
+// stripMetadata removes EXIF, XMP, IPTC and text metadata without
+// re-encoding the image data.
+func stripMetadata(contentType string, data []byte) ([]byte, error) {
+	switch contentType {
+	case TypeJPEG:
+		return stripJPEG(data)
+	case TypePNG:
+		return stripPNG(data)
+	case TypeWebP:
+		return stripWebP(data)
+	case TypeHEIC:
+		return stripHEIF(data)
+	}
+	return data, nil
+}
+
+// stripJPEG drops APP1 (EXIF, XMP), APP13 (IPTC) and comment segments. The
+// EXIF orientation is carried over in a minimal EXIF segment so that photos
+// still display upright.
+func stripJPEG(data []byte) ([]byte, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2]) // SOI
+
+	orientation := uint16(0)
+	pos := 2
+	for {
+		if pos+4 > len(data) || data[pos] != 0xFF {
+			return nil, errMalformed
+		}
+		marker := data[pos+1]
+
+		// Fill bytes between segments
+		if marker == 0xFF {
+			pos++
+			continue
+		}
+
+		// Start of scan: the rest is entropy-coded data
+		if marker == 0xDA {
+			if orientation > 1 {
+				out.Write(orientationSegment(orientation))
+			}
+			out.Write(data[pos:])
+			return out.Bytes(), nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, errMalformed
+		}
+		segment := data[pos:end]
+
+		switch {
+		case marker == 0xE1:
+			if o := exifOrientation(segment[4:]); o != 0 && orientation == 0 {
+				orientation = o
+			}
+		case marker == 0xED, marker == 0xFE:
+			// IPTC and comments are dropped
+		default:
+			out.Write(segment)
+		}
+
+		pos = end
+	}
+}
+
+// exifOrientation reads the Orientation tag from IFD0 of an APP1 payload.
+func exifOrientation(payload []byte) uint16 {
+	if !bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+		return 0
+	}
+	tiff := payload[6:]
+	if len(tiff) < 8 {
+		return 0
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:8]))
+	if ifd+2 > len(tiff) {
+		return 0
+	}
+
+	count := int(order.Uint16(tiff[ifd : ifd+2]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			value := order.Uint16(tiff[entry+8 : entry+10])
+			if value >= 1 && value <= 8 {
+				return value
+			}
+			return 0
+		}
+	}
+
+	return 0
+}
+
+// orientationSegment builds an APP1 segment whose EXIF data holds nothing
+// but the Orientation tag.
+func orientationSegment(orientation uint16) []byte {
+	tiff := []byte{
+		'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, // header, IFD0 at offset 8
+		0x00, 0x01, // one entry
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, // Orientation, SHORT, count 1
+		byte(orientation >> 8), byte(orientation), 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, // no next IFD
+	}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// stripPNG drops the eXIf, text and timestamp chunks.
+func stripPNG(data []byte) ([]byte, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:8])
+
+	pos := 8
+	for pos < len(data) {
+		if pos+12 > len(data) {
+			return nil, errMalformed
+		}
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, errMalformed
+		}
+
+		switch string(data[pos+4 : pos+8]) {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			out.Write(data[pos:end])
+		}
+
+		pos = end
+	}
+
+	return out.Bytes(), nil
+}
+
+// stripWebP drops the EXIF and XMP chunks and clears their flags in the
+// VP8X header.
+func stripWebP(data []byte) ([]byte, error) {
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+
+	pos := 12
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, errMalformed
+		}
+		fourCC := string(data[pos : pos+4])
+		length := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		end := pos + 8 + length + length%2
+		if length < 0 || end > len(data) {
+			return nil, errMalformed
+		}
+
+		switch fourCC {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := append([]byte(nil), data[pos:end]...)
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04 // EXIF and XMP present flags
+			}
+			out.Write(chunk)
+		default:
+			out.Write(data[pos:end])
+		}
+
+		pos = end
+	}
+
+	result := out.Bytes()
+	binary.LittleEndian.PutUint32(result[4:8], uint32(len(result)-8))
+	return result, nil
+}
+
+// stripHEIF overwrites the EXIF and XMP items of a HEIF image with zeros.
+// Removing them would require rewriting every offset in the file, while
+// blanking keeps the container valid.
+func stripHEIF(data []byte) ([]byte, error) {
+	extents, err := findHEIFMetadataExtents(data)
+	if err != nil {
+		return nil, err
+	}
+
+	out := append([]byte(nil), data...)
+	for _, extent := range extents {
+		clear(out[extent[0]:extent[1]])
+	}
+
+	return out, nil
+}
+
+type box struct {
+	boxType string
+	body    []byte
+	offset  int // of body within the parsed buffer
+}
+
+// parseBoxes splits an ISO BMFF buffer into its boxes.
+func parseBoxes(data []byte) ([]box, error) {
+	var boxes []box
+	pos := 0
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, errMalformed
+		}
+		size := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		boxType := string(data[pos+4 : pos+8])
+		header := 8
+
+		switch size {
+		case 0:
+			size = len(data) - pos
+		case 1:
+			if pos+16 > len(data) {
+				return nil, errMalformed
+			}
+			large := binary.BigEndian.Uint64(data[pos+8 : pos+16])
+			if large > uint64(len(data)-pos) {
+				return nil, errMalformed
+			}
+			size = int(large)
+			header = 16
+		}
+		if size < header || pos+size > len(data) {
+			return nil, errMalformed
+		}
+
+		boxes = append(boxes, box{boxType: boxType, body: data[pos+header : pos+size], offset: pos + header})
+		pos += size
+	}
+	return boxes, nil
+}
+
+func findBox(boxes []box, boxType string) *box {
+	for i := range boxes {
+		if boxes[i].boxType == boxType {
+			return &boxes[i]
+		}
+	}
+	return nil
+}
+
+// findHEIFMetadataExtents returns the [start, end) file ranges holding EXIF
+// and XMP items, located through the iinf and iloc boxes of meta.
+func findHEIFMetadataExtents(data []byte) ([][2]int, error) {
+	top, err := parseBoxes(data)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := findBox(top, "meta")
+	if meta == nil || len(meta.body) < 4 {
+		return nil, errMalformed
+	}
+	children, err := parseBoxes(meta.body[4:]) // skip version and flags
+	if err != nil {
+		return nil, err
+	}
+
+	metadataItems, err := heifMetadataItems(findBox(children, "iinf"))
+	if err != nil {
+		return nil, err
+	}
+	if len(metadataItems) == 0 {
+		return nil, nil
+	}
+
+	iloc := findBox(children, "iloc")
+	if iloc == nil {
+		return nil, errMalformed
+	}
+	return heifItemExtents(iloc.body, metadataItems, len(data))
+}
+
+// heifMetadataItems returns the IDs of Exif items and XMP mime items.
+func heifMetadataItems(iinf *box) (map[uint32]bool, error) {
+	items := map[uint32]bool{}
+	if iinf == nil {
+		return items, nil
+	}
+
+	r := &reader{data: iinf.body}
+	version := r.u8()
+	r.skip(3)
+	if version == 0 {
+		r.u16()
+	} else {
+		r.u32()
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	entries, err := parseBoxes(iinf.body[r.pos:])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.boxType != "infe" {
+			continue
+		}
+		e := &reader{data: entry.body}
+		version := e.u8()
+		e.skip(3)
+		if version < 2 {
+			continue
+		}
+
+		var itemID uint32
+		if version == 2 {
+			itemID = uint32(e.u16())
+		} else {
+			itemID = e.u32()
+		}
+		e.u16() // protection index
+		itemType := string(e.bytes(4))
+		if e.err != nil {
+			return nil, e.err
+		}
+
+		switch itemType {
+		case "Exif":
+			items[itemID] = true
+		case "mime":
+			e.cstring() // item name
+			if bytes.Contains([]byte(e.cstring()), []byte("rdf+xml")) {
+				items[itemID] = true
+			}
+		}
+	}
+
+	return items, nil
+}
+
+// heifItemExtents resolves item IDs to file ranges using the iloc box. Only
+// items stored by file offset (construction method 0) are returned.
+func heifItemExtents(iloc []byte, items map[uint32]bool, fileSize int) ([][2]int, error) {
+	r := &reader{data: iloc}
+	version := r.u8()
+	r.skip(3)
+
+	sizes := r.u16()
+	offsetSize := int(sizes >> 12)
+	lengthSize := int(sizes >> 8 & 0xF)
+	baseOffsetSize := int(sizes >> 4 & 0xF)
+	indexSize := 0
+	if version == 1 || version == 2 {
+		indexSize = int(sizes & 0xF)
+	}
+
+	var count uint32
+	if version < 2 {
+		count = uint32(r.u16())
+	} else {
+		count = r.u32()
+	}
+
+	var extents [][2]int
+	for i := uint32(0); i < count && r.err == nil; i++ {
+		var itemID uint32
+		if version < 2 {
+			itemID = uint32(r.u16())
+		} else {
+			itemID = r.u32()
+		}
+
+		constructionMethod := uint16(0)
+		if version == 1 || version == 2 {
+			constructionMethod = r.u16() & 0xF
+		}
+		r.u16() // data reference index
+		baseOffset := r.uint(baseOffsetSize)
+		extentCount := int(r.u16())
+
+		for j := 0; j < extentCount && r.err == nil; j++ {
+			r.uint(indexSize)
+			offset := r.uint(offsetSize)
+			length := r.uint(lengthSize)
+
+			if !items[itemID] || constructionMethod != 0 {
+				continue
+			}
+			start := baseOffset + offset
+			end := start + length
+			if length == 0 || end > uint64(fileSize) || end < start {
+				return nil, errMalformed
+			}
+			extents = append(extents, [2]int{int(start), int(end)})
+		}
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	return extents, nil
+}
+
+// reader reads big-endian fields and remembers the first out-of-bounds read.
+type reader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil || r.pos+n > len(r.data) {
+		r.err = errMalformed
+		return make([]byte, n)
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b
+}
+
+func (r *reader) skip(n int) { r.bytes(n) }
+func (r *reader) u8() uint8  { return r.bytes(1)[0] }
+func (r *reader) u16() uint16 {
+	return binary.BigEndian.Uint16(r.bytes(2))
+}
+func (r *reader) u32() uint32 {
+	return binary.BigEndian.Uint32(r.bytes(4))
+}
+
+func (r *reader) uint(size int) uint64 {
+	switch size {
+	case 0:
+		return 0
+	case 4:
+		return uint64(r.u32())
+	case 8:
+		return binary.BigEndian.Uint64(r.bytes(8))
+	}
+	r.err = errMalformed
+	return 0
+}
+
+func (r *reader) cstring() string {
+	if r.err != nil {
+		return ""
+	}
+	end := bytes.IndexByte(r.data[r.pos:], 0)
+	if end < 0 {
+		r.err = errMalformed
+		return ""
+	}
+	s := string(r.data[r.pos : r.pos+end])
+	r.pos += end + 1
+	return s
+}
@@ -0,0 +1,86 @@
+package upload
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// Page is the text of one page of a document. Numbers start at 1.
+type Page struct {
+	Number int
+	Text   string
+}
+
+// ExtractText returns the text of a PDF per page, or of a text file as a
+// single page. Scanned PDFs without a text layer yield pages with no text.
+func ExtractText(contentType string, data []byte) ([]Page, error) {
+	switch contentType {
+	case TypeText:
+		text := string(bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF")))
+		return []Page{{Number: 1, Text: normalizeText(text)}}, nil
+	case TypePDF:
+		return extractPDFText(data)
+	}
+	return nil, ErrUnsupportedType
+}
+
+func extractPDFText(data []byte) (pages []Page, err error) {
+	// The PDF reader panics on some malformed files
+	defer func() {
+		if r := recover(); r != nil {
+			pages, err = nil, fmt.Errorf("reading PDF: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 1; i <= reader.NumPage(); i++ {
+		page := reader.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+
+		// Font names are only unique within a page
+		fonts := map[string]*pdf.Font{}
+		for _, name := range page.Fonts() {
+			font := page.Font(name)
+			fonts[name] = &font
+		}
+
+		text, err := page.GetPlainText(fonts)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, Page{Number: i, Text: normalizeText(text)})
+	}
+
+	return pages, nil
+}
+
+// normalizeText trims each line and collapses runs of blank lines.
+func normalizeText(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	var out []string
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		out = append(out, line)
+		blank = false
+	}
+
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
@@ -0,0 +1,133 @@
+// Package upload validates and sanitizes user uploads before they reach
+// storage. A Pipeline enforces a size limit, identifies the file type from
+// its magic bytes rather than the client's claims, verifies that the content
+// really is what it claims to be and strips privacy-sensitive metadata such
+// as EXIF GPS coordinates from images.
+package upload
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Content types recognised by the pipeline.
+const (
+	TypeJPEG = "image/jpeg"
+	TypePNG  = "image/png"
+	TypeHEIC = "image/heic"
+	TypeWebP = "image/webp"
+	TypePDF  = "application/pdf"
+	TypeText = "text/plain"
+)
+
+var extensions = map[string]string{
+	TypeJPEG: ".jpg",
+	TypePNG:  ".png",
+	TypeHEIC: ".heic",
+	TypeWebP: ".webp",
+	TypePDF:  ".pdf",
+	TypeText: ".txt",
+}
+
+// Error is a rejected upload. Status is the HTTP status to answer with and
+// Code identifies the reason for programs.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+var (
+	ErrTooLarge        = &Error{Status: http.StatusRequestEntityTooLarge, Code: "file_too_large", Message: "file is too large"}
+	ErrUnsupportedType = &Error{Status: http.StatusUnsupportedMediaType, Code: "unsupported_file_type", Message: "file type is not supported"}
+	ErrCorrupt         = &Error{Status: http.StatusUnsupportedMediaType, Code: "corrupt_file", Message: "file content does not match its type or is corrupt"}
+)
+
+// File is an upload that passed validation.
+type File struct {
+	Data        []byte
+	ContentType string
+	Extension   string
+	// Width and Height are set for images that could be decoded
+	Width  int
+	Height int
+}
+
+func (f *File) Size() int64 {
+	return int64(len(f.Data))
+}
+
+func (f *File) Reader() io.Reader {
+	return bytes.NewReader(f.Data)
+}
+
+func (f *File) IsImage() bool {
+	return strings.HasPrefix(f.ContentType, "image/")
+}
+
+type Pipeline struct {
+	MaxSize      int64
+	MaxPixels    int
+	AllowedTypes []string
+}
+
+// ChatPipeline accepts the image and document formats supported in chat.
+// Images of up to 24 megapixels, enough for phone cameras, take up to about
+// 100 MB each while decoded.
+func ChatPipeline(maxSize int64) *Pipeline {
+	return &Pipeline{
+		MaxSize:      maxSize,
+		MaxPixels:    24_000_000,
+		AllowedTypes: []string{TypeJPEG, TypePNG, TypeHEIC, TypeWebP, TypePDF, TypeText},
+	}
+}
+
+// Process reads r up to the size limit and returns the validated file with
+// metadata stripped. Rejections are returned as *Error.
+func (p *Pipeline) Process(r io.Reader) (*File, error) {
+	data, err := io.ReadAll(io.LimitReader(r, p.MaxSize+1))
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return nil, ErrTooLarge
+	}
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > p.MaxSize {
+		return nil, ErrTooLarge
+	}
+
+	contentType := Sniff(data)
+	if contentType == "" || !slices.Contains(p.AllowedTypes, contentType) {
+		return nil, ErrUnsupportedType
+	}
+
+	file := &File{
+		ContentType: contentType,
+		Extension:   extensions[contentType],
+	}
+
+	if file.Width, file.Height, err = p.verify(contentType, data); err != nil {
+		return nil, err
+	}
+
+	if file.Data, err = stripMetadata(contentType, data); err != nil {
+		return nil, ErrCorrupt
+	}
+
+	return file, nil
+}
+
+// MaxSizeMessage is a client-facing description of the size limit.
+func (p *Pipeline) MaxSizeMessage() string {
+	return fmt.Sprintf("file is too large, the limit is %d MB", p.MaxSize/(1<<20))
+}
@@ -0,0 +1,178 @@
+package upload
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// ErrNoDecoder means the image format cannot be decoded in this build, as is
+// the case for HEIC unless a decoder has been registered with the image
+// package.
+var ErrNoDecoder = errors.New("no decoder for image format")
+
+// VariantSpec describes a resized rendition of an uploaded image.
+type VariantSpec struct {
+	Name string
+	// MaxDimension bounds the longer side; smaller images are not upscaled
+	MaxDimension int
+	Quality      int
+}
+
+// ImageVariants are the renditions generated for chat images: a thumbnail
+// for history views and a normalized JPEG that every browser can display.
+var ImageVariants = []VariantSpec{
+	{Name: "thumbnail", MaxDimension: 320, Quality: 75},
+	{Name: "web", MaxDimension: 2048, Quality: 85},
+}
+
+// Variant is a generated rendition, always encoded as JPEG.
+type Variant struct {
+	Name   string
+	Data   []byte
+	Width  int
+	Height int
+}
+
+func (v *Variant) Size() int64 {
+	return int64(len(v.Data))
+}
+
+func (v *Variant) Reader() *bytes.Reader {
+	return bytes.NewReader(v.Data)
+}
+
+// GenerateVariants decodes an image, applies its EXIF orientation and renders
+// each spec as a JPEG without metadata.
+func GenerateVariants(data []byte, specs []VariantSpec) ([]Variant, error) {
+	release := decodeSlot()
+	defer release()
+
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if errors.Is(err, image.ErrFormat) {
+		return nil, ErrNoDecoder
+	}
+	if err != nil {
+		return nil, err
+	}
+	if format == "jpeg" {
+		src = applyOrientation(src, jpegOrientation(data))
+	}
+
+	variants := make([]Variant, 0, len(specs))
+	for _, spec := range specs {
+		resized := resize(src, spec.MaxDimension)
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: spec.Quality}); err != nil {
+			return nil, err
+		}
+
+		bounds := resized.Bounds()
+		variants = append(variants, Variant{
+			Name:   spec.Name,
+			Data:   buf.Bytes(),
+			Width:  bounds.Dx(),
+			Height: bounds.Dy(),
+		})
+	}
+
+	return variants, nil
+}
+
+// resize scales src to fit within maxDimension, flattening any transparency
+// onto white since JPEG has no alpha channel.
+func resize(src image.Image, maxDimension int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if longer := max(width, height); longer > maxDimension {
+		width = max(1, width*maxDimension/longer)
+		height = max(1, height*maxDimension/longer)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG, or 1 if it has
+// none.
+func jpegOrientation(data []byte) uint16 {
+	pos := 2
+	for pos+4 <= len(data) && data[pos] == 0xFF {
+		marker := data[pos+1]
+		if marker == 0xDA {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			break
+		}
+		if marker == 0xE1 {
+			if orientation := exifOrientation(data[pos+4 : end]); orientation != 0 {
+				return orientation
+			}
+		}
+		pos = end
+	}
+	return 1
+}
+
+// applyOrientation rotates and flips src so that it displays upright without
+// an orientation tag. Orientations are numbered as in the EXIF standard.
+func applyOrientation(src image.Image, orientation uint16) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	transposed := orientation >= 5
+	if transposed {
+		width, height = height, width
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			dx, dy := x, y
+			switch orientation {
+			case 2:
+				dx = width - 1 - x
+			case 3:
+				dx, dy = width-1-x, height-1-y
+			case 4:
+				dy = height - 1 - y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = width-1-y, x
+			case 7:
+				dx, dy = width-1-y, height-1-x
+			case 8:
+				dx, dy = y, height-1-x
+			}
+			dst.Set(dx, dy, src.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// ImageDimensions returns the size of an image in a format that can be
+// decoded, or zeros.
+func ImageDimensions(data []byte) (int, int) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0
+	}
+	return config.Width, config.Height
+}
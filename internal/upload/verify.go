@@ -0,0 +1,78 @@
+package upload
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/webp"
+)
+
+// maxConcurrentDecodes bounds the images decoded at once, for validation
+// and variant generation together, so that the memory taken by decoded
+// images does not grow with the number of files and concurrent requests.
+const maxConcurrentDecodes = 4
+
+var decodeSlots = make(chan struct{}, maxConcurrentDecodes)
+
+// decodeSlot waits until fewer than maxConcurrentDecodes images are being
+// decoded and returns the function that frees the slot once the decoded
+// image is no longer needed.
+func decodeSlot() (release func()) {
+	decodeSlots <- struct{}{}
+	return func() { <-decodeSlots }
+}
+
+// verify decodes the file far enough to be sure it is intact and returns
+// image dimensions where available.
+func (p *Pipeline) verify(contentType string, data []byte) (int, int, error) {
+	switch contentType {
+	case TypeJPEG:
+		return p.decode(data, jpeg.DecodeConfig, jpeg.Decode)
+	case TypePNG:
+		return p.decode(data, png.DecodeConfig, png.Decode)
+	case TypeWebP:
+		return p.decode(data, webp.DecodeConfig, webp.Decode)
+	case TypeHEIC:
+		// There is no pure Go HEVC decoder, so check the container instead
+		if _, err := parseBoxes(data); err != nil {
+			return 0, 0, ErrCorrupt
+		}
+		if _, err := findHEIFMetadataExtents(data); err != nil {
+			return 0, 0, ErrCorrupt
+		}
+		return 0, 0, nil
+	case TypePDF:
+		tail := data[max(0, len(data)-1024):]
+		if !bytes.Contains(tail, []byte("%%EOF")) {
+			return 0, 0, ErrCorrupt
+		}
+		return 0, 0, nil
+	case TypeText:
+		// Sniffing already checked that the content is text
+		return 0, 0, nil
+	}
+	return 0, 0, ErrUnsupportedType
+}
+
+// decode checks the dimensions before decoding the full image so that a
+// small file claiming enormous dimensions cannot exhaust memory.
+func (p *Pipeline) decode(data []byte, decodeConfig func(r io.Reader) (image.Config, error), decode func(r io.Reader) (image.Image, error)) (int, int, error) {
+	config, err := decodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, ErrCorrupt
+	}
+	if config.Width <= 0 || config.Height <= 0 || (p.MaxPixels > 0 && config.Width*config.Height > p.MaxPixels) {
+		return 0, 0, ErrCorrupt
+	}
+
+	release := decodeSlot()
+	defer release()
+	if _, err := decode(bytes.NewReader(data)); err != nil {
+		return 0, 0, ErrCorrupt
+	}
+
+	return config.Width, config.Height, nil
+}
@@ -2,17 +2,43 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/google/generative-ai-go/genai"
+	"github.com/googleapis/gax-go/v2/apierror"
+	"github.com/subhammahanty235/medai/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
+var (
+	ErrNoResponse         = errors.New("no response generated")
+	ErrUnexpectedResponse = errors.New("unexpected response format")
+)
+
+// Completion is a generated reply with the model that produced it and the
+// tokens it used, when the API reports them.
+type Completion struct {
+	Text             string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+}
+
 type GeminiClient struct {
-	client *genai.Client
+	client  *genai.Client
+	timeout time.Duration
 }
 
-func NewGeminiClient(apiKey string) (*GeminiClient, error) {
+// NewGeminiClient returns a client whose generations are abandoned after
+// timeout; zero means no limit.
+func NewGeminiClient(apiKey string, timeout time.Duration) (*GeminiClient, error) {
 	ctx := context.Background()
 	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
 	if err != nil {
@@ -20,13 +46,21 @@ func NewGeminiClient(apiKey string) (*GeminiClient, error) {
 	}
 
 	return &GeminiClient{
-		client: client,
+		client:  client,
+		timeout: timeout,
 	}, nil
 }
 
-func (g *GeminiClient) GenerateResponse(systemPrompt, userMessage string) (string, error) {
-	ctx := context.Background()
-	model := g.client.GenerativeModel("gemini-pro")
+// Generate asks model for a reply to prompt. With imageURLs the prompt
+// tells the model how many images the user shared.
+func (g *GeminiClient) Generate(ctx context.Context, modelName, systemPrompt, prompt string, imageURLs []string) (*Completion, error) {
+	ctx, span := startGeneration(ctx, modelName, attribute.Int("medai.images", len(imageURLs)))
+	defer span.End()
+
+	ctx, cancel := g.withTimeout(ctx)
+	defer cancel()
+
+	model := g.client.GenerativeModel(modelName)
 
 	// Set system instruction
 	model.SystemInstruction = &genai.Content{
@@ -35,52 +69,107 @@ func (g *GeminiClient) GenerateResponse(systemPrompt, userMessage string) (strin
 		},
 	}
 
+	// Create image part (simplified - in production you'd fetch the image)
+	if len(imageURLs) > 0 {
+		prompt = fmt.Sprintf("%s\n\nUser has shared %d image(s). Please analyze them in the context of their message: %s", systemPrompt, len(imageURLs), prompt)
+	}
+
 	// Generate response
-	resp, err := model.GenerateContent(ctx, genai.Text(userMessage))
+	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
 	if err != nil {
-		return "", err
+		err = callError(ctx, err)
+		tracing.RecordError(span, err)
+		return nil, err
 	}
 
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return "", fmt.Errorf("no response generated")
+	return completion(span, modelName, resp)
+}
+
+// Retryable reports whether err is worth another try: rate limiting, server
+// errors and attempts that ran out of time.
+func (g *GeminiClient) Retryable(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
 	}
 
-	// Extract text from response
-	if textPart, ok := resp.Candidates[0].Content.Parts[0].(genai.Text); ok {
-		return string(textPart), nil
+	var apiErr *apierror.APIError
+	if errors.As(err, &apiErr) && apiErr.HTTPCode() > 0 {
+		code := apiErr.HTTPCode()
+		return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
 	}
 
-	return "", fmt.Errorf("unexpected response format")
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Internal, codes.Aborted, codes.DeadlineExceeded:
+		return true
+	}
+	return false
 }
 
-func (g *GeminiClient) GenerateResponseWithImage(systemPrompt, userMessage, imageURL string) (string, error) {
-	ctx := context.Background()
-	model := g.client.GenerativeModel("gemini-pro-vision")
+// withTimeout bounds a generation by the configured timeout, if any.
+func (g *GeminiClient) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if g.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, g.timeout)
+}
 
-	// Set system instruction
-	model.SystemInstruction = &genai.Content{
-		Parts: []genai.Part{
-			genai.Text(systemPrompt),
-		},
+// callError returns err wrapped in the context's error if the deadline
+// passed or the caller went away, which the API client reports in errors of
+// its own.
+func callError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+		return fmt.Errorf("%w: %v", ctxErr, err)
 	}
+	return err
+}
 
-	// Create image part (simplified - in production you'd fetch the image)
-	prompt := fmt.Sprintf("%s\n\nUser has shared an image. Please analyze it in the context of their message: %s", systemPrompt, userMessage)
+// startGeneration traces an LLM call. Prompts and replies are not recorded
+// on spans, as they hold patient data.
+func startGeneration(ctx context.Context, model string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs,
+		attribute.String("gen_ai.system", "gemini"),
+		attribute.String("gen_ai.request.model", model),
+	)
+	return tracing.Start(ctx, "gemini.GenerateContent", attrs...)
+}
 
-	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
-	if err != nil {
-		return "", err
+// completion extracts the text of the first candidate and the token usage,
+// which is added to the span. Token counts are returned alongside
+// ErrNoResponse too, since the prompt was still billed.
+func completion(span trace.Span, model string, resp *genai.GenerateContentResponse) (*Completion, error) {
+	result := &Completion{Model: model}
+	if resp.UsageMetadata != nil {
+		result.PromptTokens = int(resp.UsageMetadata.PromptTokenCount)
+		result.CompletionTokens = int(resp.UsageMetadata.CandidatesTokenCount)
 	}
-
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return "", fmt.Errorf("no response generated")
+	span.SetAttributes(
+		attribute.Int("gen_ai.usage.input_tokens", result.PromptTokens),
+		attribute.Int("gen_ai.usage.output_tokens", result.CompletionTokens),
+	)
+
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
+		tracing.RecordError(span, ErrNoResponse)
+		return result, ErrNoResponse
 	}
 
-	if textPart, ok := resp.Candidates[0].Content.Parts[0].(genai.Text); ok {
-		return string(textPart), nil
+	// Extract text from response
+	textPart, ok := resp.Candidates[0].Content.Parts[0].(genai.Text)
+	if !ok {
+		tracing.RecordError(span, ErrUnexpectedResponse)
+		return result, ErrUnexpectedResponse
 	}
 
-	return "", fmt.Errorf("unexpected response format")
+	result.Text = string(textPart)
+	return result, nil
+}
+
+// CheckConfig reports whether the client is set up to make requests. It
+// does not call the API, so readiness probes cost no quota.
+func (g *GeminiClient) CheckConfig() error {
+	if g.client == nil {
+		return errors.New("Gemini client is not initialized")
+	}
+	return nil
 }
 
 func (g *GeminiClient) Close() error {
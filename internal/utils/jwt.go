@@ -8,18 +8,50 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Token purposes. Access tokens carry no purpose; the others are only
+// accepted by the endpoints that complete the corresponding step.
+const (
+	TokenPurposeAccess        = ""
+	TokenPurposeMFAChallenge  = "mfa_challenge"
+	TokenPurposeMFAEnrollment = "mfa_enrollment"
+)
+
 type Claims struct {
-	UserID primitive.ObjectID `json:"user_id"`
-	Email  string             `json:"email"`
+	UserID  primitive.ObjectID `json:"user_id"`
+	Email   string             `json:"email"`
+	Role    string             `json:"role,omitempty"`
+	Purpose string             `json:"purpose,omitempty"`
+	// TokenVersion must match the user's current version; bumping it
+	// revokes every token issued before.
+	TokenVersion int `json:"ver,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateToken(userID primitive.ObjectID, email, secretKey string) (string, error) {
+func GenerateToken(userID primitive.ObjectID, email, role string, tokenVersion int, secretKey string) (string, error) {
+	return generateToken(userID, email, role, TokenPurposeAccess, tokenVersion, 24*time.Hour, secretKey)
+}
+
+// GenerateMFAChallengeToken issues the short-lived token returned by the
+// first login step when the account has two-factor authentication enabled.
+func GenerateMFAChallengeToken(userID primitive.ObjectID, email, secretKey string) (string, error) {
+	return generateToken(userID, email, "", TokenPurposeMFAChallenge, 0, 5*time.Minute, secretKey)
+}
+
+// GenerateMFAEnrollmentToken issues a token that only grants access to the
+// enrollment endpoints, for roles that must enroll before using the API.
+func GenerateMFAEnrollmentToken(userID primitive.ObjectID, email, role string, tokenVersion int, secretKey string) (string, error) {
+	return generateToken(userID, email, role, TokenPurposeMFAEnrollment, tokenVersion, 15*time.Minute, secretKey)
+}
+
+func generateToken(userID primitive.ObjectID, email, role, purpose string, tokenVersion int, ttl time.Duration, secretKey string) (string, error) {
 	claims := &Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:       userID,
+		Email:        email,
+		Role:         role,
+		Purpose:      purpose,
+		TokenVersion: tokenVersion,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -33,7 +65,7 @@ func ValidateToken(tokenString, secretKey string) (*Claims, error) {
 
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		return []byte(secretKey), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 
 	if err != nil {
 		return nil, err
@@ -45,3 +77,18 @@ func ValidateToken(tokenString, secretKey string) (*Claims, error) {
 
 	return claims, nil
 }
+
+// ValidateTokenWithPurpose validates the token and additionally requires it
+// to have been issued for purpose.
+func ValidateTokenWithPurpose(tokenString, secretKey, purpose string) (*Claims, error) {
+	claims, err := ValidateToken(tokenString, secretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Purpose != purpose {
+		return nil, errors.New("invalid token")
+	}
+
+	return claims, nil
+}
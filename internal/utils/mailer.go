@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Mailer sends transactional email such as verification links.
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// LogMailer logs that mail would have been sent instead of delivering it,
+// for development. Only the recipient, redacted like every email address in
+// the logs, and the subject are logged: bodies carry verification links.
+type LogMailer struct {
+	logger *slog.Logger
+}
+
+func NewLogMailer(logger *slog.Logger) *LogMailer {
+	return &LogMailer{
+		logger: logger,
+	}
+}
+
+func (m *LogMailer) Send(ctx context.Context, to, subject, body string) error {
+	m.logger.InfoContext(ctx, "Email not delivered, no provider configured", "to", to, "subject", subject)
+	return nil
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+
+	"golang.org/x/oauth2"
+)
+
+// RandomToken returns a URL-safe random string carrying 256 bits of entropy.
+func RandomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// NewPKCEVerifier returns a code verifier for the OAuth2 PKCE extension.
+func NewPKCEVerifier() string {
+	return oauth2.GenerateVerifier()
+}
+
+// HashToken hashes a high-entropy random token for storage and lookup.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}